@@ -16,6 +16,8 @@ require (
 	github.com/hashicorp/golang-lru v0.5.0 // indirect
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/klauspost/cpuid v1.2.0
+	github.com/lib/pq v1.0.0
+	github.com/mattn/go-sqlite3 v1.9.0
 	github.com/pkg/errors v0.8.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/satori/go.uuid v1.2.0
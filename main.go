@@ -3,7 +3,12 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
 	"github.com/PoC-Consortium/Nogrod/pkg/modelx"
 	"github.com/PoC-Consortium/Nogrod/pkg/pool"
 	"github.com/PoC-Consortium/Nogrod/pkg/wallethandler"
@@ -16,7 +21,12 @@ func main() {
 
 	walletHandler := wallethandler.NewWalletHandler(Cfg.WalletUrls, Cfg.SecretPhrase, Cfg.WalletTimeoutDur,
 		Cfg.TrustAllWalletCerts)
+	if Cfg.UpstreamPoolURL != "" {
+		walletHandler = wallethandler.NewUpstreamHandler(walletHandler, Cfg.UpstreamPoolURL,
+			Cfg.WalletTimeoutDur, Cfg.TrustAllWalletCerts)
+	}
 	modelx := modelx.NewModelX(walletHandler, true)
+	events.NewNotifier(modelx.Events(), Cfg.NotificationURL, Cfg.WalletTimeoutDur)
 
 	webServer := webserver.NewWebServer(modelx)
 	webServer.Run()
@@ -24,5 +34,8 @@ func main() {
 	pool := pool.NewPool(modelx, walletHandler)
 	pool.Run()
 
-	select {}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	modelx.Shutdown()
 }
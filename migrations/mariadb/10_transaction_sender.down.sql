@@ -0,0 +1,6 @@
+START TRANSACTION;
+
+ALTER TABLE `transaction` DROP COLUMN `sender`;
+ALTER TABLE `transaction_archive` DROP COLUMN `sender`;
+
+COMMIT;
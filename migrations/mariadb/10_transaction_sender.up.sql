@@ -0,0 +1,8 @@
+START TRANSACTION;
+
+-- the instance that claimed a transaction for sending it, so that no other one sends
+-- it as well
+ALTER TABLE `transaction` ADD COLUMN `sender` VARCHAR(255) NULL;
+ALTER TABLE `transaction_archive` ADD COLUMN `sender` VARCHAR(255) NULL;
+
+COMMIT;
@@ -0,0 +1,9 @@
+START TRANSACTION;
+
+ALTER TABLE `block`
+  DROP COLUMN `nonce_submitted`,
+  DROP COLUMN `submit_wallet`,
+  DROP COLUMN `submit_latency`,
+  DROP COLUMN `deadline_mismatch`;
+
+COMMIT;
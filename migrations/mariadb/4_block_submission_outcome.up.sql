@@ -0,0 +1,9 @@
+START TRANSACTION;
+
+ALTER TABLE `block`
+  ADD COLUMN `nonce_submitted` TINYINT NOT NULL DEFAULT 0,
+  ADD COLUMN `submit_wallet` VARCHAR(255) NULL,
+  ADD COLUMN `submit_latency` INT NULL,
+  ADD COLUMN `deadline_mismatch` TINYINT NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,15 @@
+START TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS `ban` (
+  `id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `type` ENUM('account', 'ip', 'cidr', 'user_agent') NOT NULL,
+  `value` VARCHAR(255) NOT NULL,
+  `reason` VARCHAR(255) NOT NULL DEFAULT '',
+  `expires` DATETIME NULL,
+  `created` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  INDEX `ban_expires_idx` (`expires` ASC)
+)
+ENGINE = InnoDB;
+
+COMMIT;
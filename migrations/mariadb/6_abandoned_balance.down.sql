@@ -0,0 +1,8 @@
+START TRANSACTION;
+
+DROP TABLE IF EXISTS `swept_balance`;
+
+ALTER TABLE `account`
+  DROP COLUMN `abandoned_notified`;
+
+COMMIT;
@@ -0,0 +1,17 @@
+START TRANSACTION;
+
+ALTER TABLE `account`
+  ADD COLUMN `abandoned_notified` DATETIME NULL;
+
+CREATE TABLE IF NOT EXISTS `swept_balance` (
+  `id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `account_id` BIGINT(20) unsigned NOT NULL,
+  `amount` BIGINT(20) NOT NULL,
+  `swept` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `restored` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX `swept_balance_account_idx` (`account_id` ASC)
+)
+ENGINE = InnoDB;
+
+COMMIT;
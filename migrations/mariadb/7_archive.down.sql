@@ -0,0 +1,8 @@
+START TRANSACTION;
+
+DROP TABLE IF EXISTS `transaction_recipient_archive`;
+DROP TABLE IF EXISTS `transaction_archive`;
+DROP TABLE IF EXISTS `nonce_submission_archive`;
+DROP TABLE IF EXISTS `block_archive`;
+
+COMMIT;
@@ -0,0 +1,10 @@
+START TRANSACTION;
+
+-- rows are copied with SELECT *, so schema changes of the archived tables
+-- need to be applied to their archive tables as well
+CREATE TABLE IF NOT EXISTS `block_archive` LIKE `block`;
+CREATE TABLE IF NOT EXISTS `nonce_submission_archive` LIKE `nonce_submission`;
+CREATE TABLE IF NOT EXISTS `transaction_archive` LIKE `transaction`;
+CREATE TABLE IF NOT EXISTS `transaction_recipient_archive` LIKE `transaction_recipient`;
+
+COMMIT;
@@ -0,0 +1,18 @@
+START TRANSACTION;
+
+-- the EEPS of the miners taken from the cache when a block is closed, kept until
+-- the block is rewarded
+CREATE TABLE IF NOT EXISTS `block_share` (
+  `block_height` BIGINT(20) unsigned NOT NULL,
+  `miner_id` BIGINT(20) unsigned NOT NULL,
+  `eeps` DOUBLE NOT NULL,
+  PRIMARY KEY (`block_height`, `miner_id`),
+  CONSTRAINT `block_share_block_fk`
+    FOREIGN KEY (`block_height`)
+    REFERENCES `block` (`height`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION
+)
+ENGINE = InnoDB;
+
+COMMIT;
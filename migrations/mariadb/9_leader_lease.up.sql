@@ -0,0 +1,13 @@
+START TRANSACTION;
+
+-- the lease of the instance that runs the jobs, submits nonces and sends payments,
+-- expires is in unix ms
+CREATE TABLE IF NOT EXISTS `leader_lease` (
+  `name` VARCHAR(32) NOT NULL,
+  `holder` VARCHAR(255) NOT NULL,
+  `expires` BIGINT(20) NOT NULL,
+  PRIMARY KEY (`name`)
+)
+ENGINE = InnoDB;
+
+COMMIT;
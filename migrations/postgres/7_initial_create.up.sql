@@ -0,0 +1,116 @@
+-- the schema of the MariaDB migrations 1 to 7, later migrations share their numbers
+-- with the MariaDB ones
+-- account ids, nonces and deadlines are unsigned 64 bit integers
+START TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS account (
+  id NUMERIC(20) NOT NULL,
+  address VARCHAR(20) NOT NULL,
+  name VARCHAR(100),
+  pending BIGINT NOT NULL DEFAULT 0,
+  min_payout_value BIGINT,
+  payout_interval VARCHAR(20),
+  next_payout_date TIMESTAMP WITH TIME ZONE,
+  abandoned_notified TIMESTAMP WITH TIME ZONE NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT address_unique UNIQUE (address)
+);
+
+CREATE TABLE IF NOT EXISTS block (
+  height BIGINT NOT NULL,
+  base_target BIGINT NOT NULL,
+  scoop INTEGER NOT NULL,
+  generation_signature VARCHAR(64) NOT NULL,
+  winner_verified SMALLINT NOT NULL DEFAULT 0,
+  reward BIGINT NULL,
+  winner_id NUMERIC(20) NULL,
+  best_nonce_submission_id BIGINT NULL,
+  created TIMESTAMP WITH TIME ZONE NOT NULL,
+  generation_time INTEGER NOT NULL DEFAULT 240,
+  nonce_submitted SMALLINT NOT NULL DEFAULT 0,
+  submit_wallet VARCHAR(255) NULL,
+  submit_latency INTEGER NULL,
+  deadline_mismatch SMALLINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (height),
+  CONSTRAINT winner_account_fk FOREIGN KEY (winner_id) REFERENCES account (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS winner_account_fk_idx ON block (winner_id);
+CREATE INDEX IF NOT EXISTS nonce_submission_fk_idx ON block (best_nonce_submission_id);
+
+CREATE TABLE IF NOT EXISTS nonce_submission (
+  id BIGSERIAL NOT NULL,
+  miner_id NUMERIC(20) NOT NULL,
+  block_height BIGINT NOT NULL,
+  deadline NUMERIC(20) NOT NULL,
+  nonce NUMERIC(20) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT block_miner_unique UNIQUE (block_height, miner_id),
+  CONSTRAINT miner_fk FOREIGN KEY (miner_id) REFERENCES account (id) ON DELETE CASCADE,
+  CONSTRAINT miner_block_fk FOREIGN KEY (block_height) REFERENCES block (height) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS miner_fk_idx ON nonce_submission (miner_id);
+
+ALTER TABLE block
+  ADD CONSTRAINT nonce_submission_fk FOREIGN KEY (best_nonce_submission_id)
+    REFERENCES nonce_submission (id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS miner (
+  id NUMERIC(20) NOT NULL,
+  capacity BIGINT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT miner_account_fk FOREIGN KEY (id) REFERENCES account (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "transaction" (
+  id BIGSERIAL NOT NULL,
+  transaction_id NUMERIC(20),
+  block_height BIGINT,
+  created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  CONSTRAINT transaction_block_fk FOREIGN KEY (block_height) REFERENCES block (height) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS transaction_block_fk_idx ON "transaction" (block_height);
+
+CREATE TABLE IF NOT EXISTS transaction_recipient (
+  id BIGSERIAL NOT NULL,
+  transaction_id BIGINT NOT NULL,
+  recipient_id NUMERIC(20) NOT NULL,
+  amount BIGINT NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT transaction_recipient_account_fk FOREIGN KEY (recipient_id)
+    REFERENCES account (id) ON DELETE CASCADE,
+  CONSTRAINT transaction_recipient_transaction_fk FOREIGN KEY (transaction_id)
+    REFERENCES "transaction" (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS account_fk_idx ON transaction_recipient (recipient_id);
+CREATE INDEX IF NOT EXISTS transaction_fk_idx ON transaction_recipient (transaction_id);
+
+CREATE TABLE IF NOT EXISTS ban (
+  id BIGSERIAL NOT NULL,
+  type VARCHAR(16) NOT NULL CHECK (type IN ('account', 'ip', 'cidr', 'user_agent')),
+  value VARCHAR(255) NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  expires TIMESTAMP WITH TIME ZONE NULL,
+  created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS ban_expires_idx ON ban (expires);
+
+CREATE TABLE IF NOT EXISTS swept_balance (
+  id BIGSERIAL NOT NULL,
+  account_id NUMERIC(20) NOT NULL,
+  amount BIGINT NOT NULL,
+  swept TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  restored TIMESTAMP WITH TIME ZONE NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS swept_balance_account_idx ON swept_balance (account_id);
+
+-- rows are copied with SELECT *, so schema changes of the archived tables
+-- need to be applied to their archive tables as well
+CREATE TABLE IF NOT EXISTS block_archive (LIKE block INCLUDING INDEXES);
+CREATE TABLE IF NOT EXISTS nonce_submission_archive (LIKE nonce_submission INCLUDING INDEXES);
+CREATE TABLE IF NOT EXISTS transaction_archive (LIKE "transaction" INCLUDING INDEXES);
+CREATE TABLE IF NOT EXISTS transaction_recipient_archive (LIKE transaction_recipient INCLUDING INDEXES);
+
+COMMIT;
@@ -0,0 +1,2 @@
+ALTER TABLE `transaction` DROP COLUMN sender;
+ALTER TABLE transaction_archive DROP COLUMN sender;
@@ -0,0 +1,4 @@
+-- the instance that claimed a transaction for sending it, so that no other one sends
+-- it as well
+ALTER TABLE `transaction` ADD COLUMN sender TEXT NULL;
+ALTER TABLE transaction_archive ADD COLUMN sender TEXT NULL;
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS transaction_recipient_archive;
+DROP TABLE IF EXISTS transaction_archive;
+DROP TABLE IF EXISTS nonce_submission_archive;
+DROP TABLE IF EXISTS block_archive;
+DROP TABLE IF EXISTS swept_balance;
+DROP TABLE IF EXISTS ban;
+DROP TABLE IF EXISTS transaction_recipient;
+DROP TABLE IF EXISTS `transaction`;
+DROP TABLE IF EXISTS miner;
+DROP TABLE IF EXISTS nonce_submission;
+DROP TABLE IF EXISTS block;
+DROP TABLE IF EXISTS account;
@@ -0,0 +1,134 @@
+-- the schema of the MariaDB migrations 1 to 7, later migrations share their numbers
+-- with the MariaDB ones
+-- account ids and nonces are unsigned 64 bit integers and kept as TEXT, migrations
+-- run inside of a transaction already
+
+CREATE TABLE IF NOT EXISTS account (
+  id TEXT NOT NULL,
+  address VARCHAR(20) NOT NULL,
+  name VARCHAR(100),
+  pending INTEGER NOT NULL DEFAULT 0,
+  min_payout_value INTEGER,
+  payout_interval VARCHAR(20),
+  next_payout_date DATETIME,
+  abandoned_notified DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE (address)
+);
+
+CREATE TABLE IF NOT EXISTS block (
+  height INTEGER NOT NULL,
+  base_target INTEGER NOT NULL,
+  scoop INTEGER NOT NULL,
+  generation_signature VARCHAR(64) NOT NULL,
+  winner_verified TINYINT NOT NULL DEFAULT 0,
+  reward INTEGER NULL,
+  winner_id TEXT NULL REFERENCES account (id) ON DELETE CASCADE,
+  best_nonce_submission_id INTEGER NULL REFERENCES nonce_submission (id) ON DELETE CASCADE,
+  created DATETIME NOT NULL,
+  generation_time INTEGER NOT NULL DEFAULT 240,
+  nonce_submitted TINYINT NOT NULL DEFAULT 0,
+  submit_wallet VARCHAR(255) NULL,
+  submit_latency INTEGER NULL,
+  deadline_mismatch TINYINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (height)
+);
+CREATE INDEX IF NOT EXISTS winner_account_fk_idx ON block (winner_id);
+CREATE INDEX IF NOT EXISTS nonce_submission_fk_idx ON block (best_nonce_submission_id);
+
+CREATE TABLE IF NOT EXISTS nonce_submission (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  miner_id TEXT NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+  block_height INTEGER NOT NULL REFERENCES block (height) ON DELETE CASCADE,
+  deadline INTEGER NOT NULL,
+  nonce TEXT NOT NULL,
+  UNIQUE (block_height, miner_id)
+);
+CREATE INDEX IF NOT EXISTS miner_fk_idx ON nonce_submission (miner_id);
+
+CREATE TABLE IF NOT EXISTS miner (
+  id TEXT NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+  capacity INTEGER NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS `transaction` (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  transaction_id TEXT,
+  block_height INTEGER REFERENCES block (height) ON DELETE CASCADE,
+  created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS transaction_block_fk_idx ON `transaction` (block_height);
+
+CREATE TABLE IF NOT EXISTS transaction_recipient (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  transaction_id INTEGER NOT NULL REFERENCES `transaction` (id) ON DELETE CASCADE,
+  recipient_id TEXT NOT NULL REFERENCES account (id) ON DELETE CASCADE,
+  amount INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS account_fk_idx ON transaction_recipient (recipient_id);
+CREATE INDEX IF NOT EXISTS transaction_fk_idx ON transaction_recipient (transaction_id);
+
+CREATE TABLE IF NOT EXISTS ban (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type VARCHAR(16) NOT NULL CHECK (type IN ('account', 'ip', 'cidr', 'user_agent')),
+  value VARCHAR(255) NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  expires DATETIME NULL,
+  created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS ban_expires_idx ON ban (expires);
+
+CREATE TABLE IF NOT EXISTS swept_balance (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  account_id TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  swept DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  restored DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS swept_balance_account_idx ON swept_balance (account_id);
+
+-- rows are copied with SELECT *, so schema changes of the archived tables
+-- need to be applied to their archive tables as well
+CREATE TABLE IF NOT EXISTS block_archive (
+  height INTEGER NOT NULL,
+  base_target INTEGER NOT NULL,
+  scoop INTEGER NOT NULL,
+  generation_signature VARCHAR(64) NOT NULL,
+  winner_verified TINYINT NOT NULL DEFAULT 0,
+  reward INTEGER NULL,
+  winner_id TEXT NULL,
+  best_nonce_submission_id INTEGER NULL,
+  created DATETIME NOT NULL,
+  generation_time INTEGER NOT NULL DEFAULT 240,
+  nonce_submitted TINYINT NOT NULL DEFAULT 0,
+  submit_wallet VARCHAR(255) NULL,
+  submit_latency INTEGER NULL,
+  deadline_mismatch TINYINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (height)
+);
+
+CREATE TABLE IF NOT EXISTS nonce_submission_archive (
+  id INTEGER NOT NULL,
+  miner_id TEXT NOT NULL,
+  block_height INTEGER NOT NULL,
+  deadline INTEGER NOT NULL,
+  nonce TEXT NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_archive (
+  id INTEGER NOT NULL,
+  transaction_id TEXT,
+  block_height INTEGER,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_recipient_archive (
+  id INTEGER NOT NULL,
+  transaction_id INTEGER NOT NULL,
+  recipient_id TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  PRIMARY KEY (id)
+);
@@ -0,0 +1,8 @@
+-- the EEPS of the miners taken from the cache when a block is closed, kept until
+-- the block is rewarded
+CREATE TABLE IF NOT EXISTS block_share (
+  block_height INTEGER NOT NULL REFERENCES block (height) ON DELETE CASCADE,
+  miner_id TEXT NOT NULL,
+  eeps REAL NOT NULL,
+  PRIMARY KEY (block_height, miner_id)
+);
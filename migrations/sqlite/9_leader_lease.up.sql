@@ -0,0 +1,8 @@
+-- the lease of the instance that runs the jobs, submits nonces and sends payments,
+-- expires is in unix ms
+CREATE TABLE IF NOT EXISTS leader_lease (
+  name TEXT NOT NULL,
+  holder TEXT NOT NULL,
+  expires INTEGER NOT NULL,
+  PRIMARY KEY (name)
+);
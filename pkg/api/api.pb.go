@@ -14,6 +14,8 @@ It has these top-level messages:
 	PoolStatsInfo
 	BlockInfo
 	PoolConfigInfo
+	AccountCheckRequest
+	AccountCheck
 */
 package api
 
@@ -373,6 +375,150 @@ func (m *PoolConfigInfo) GetPoolPublicID() uint64 {
 	return 0
 }
 
+type AccountCheckRequest struct {
+	Account string `protobuf:"bytes,1,opt,name=account" json:"account,omitempty"`
+}
+
+func (m *AccountCheckRequest) Reset()                    { *m = AccountCheckRequest{} }
+func (m *AccountCheckRequest) String() string            { return proto.CompactTextString(m) }
+func (*AccountCheckRequest) ProtoMessage()               {}
+func (*AccountCheckRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *AccountCheckRequest) GetAccount() string {
+	if m != nil {
+		return m.Account
+	}
+	return ""
+}
+
+type AccountCheck struct {
+	ID                     uint64 `protobuf:"varint,1,opt,name=ID" json:"ID,omitempty"`
+	Address                string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	RewardRecipient        uint64 `protobuf:"varint,3,opt,name=rewardRecipient" json:"rewardRecipient,omitempty"`
+	RewardRecipientAddress string `protobuf:"bytes,4,opt,name=rewardRecipientAddress" json:"rewardRecipientAddress,omitempty"`
+	AssignedToPool         bool   `protobuf:"varint,5,opt,name=assignedToPool" json:"assignedToPool,omitempty"`
+	Effective              bool   `protobuf:"varint,6,opt,name=effective" json:"effective,omitempty"`
+	Banned                 bool   `protobuf:"varint,7,opt,name=banned" json:"banned,omitempty"`
+	BanReason              string `protobuf:"bytes,8,opt,name=banReason" json:"banReason,omitempty"`
+	LastSubmitHeight       uint64 `protobuf:"varint,9,opt,name=lastSubmitHeight" json:"lastSubmitHeight,omitempty"`
+	LastSubmit             string `protobuf:"bytes,10,opt,name=lastSubmit" json:"lastSubmit,omitempty"`
+	LastRejectReason       string `protobuf:"bytes,11,opt,name=lastRejectReason" json:"lastRejectReason,omitempty"`
+	LastReject             string `protobuf:"bytes,12,opt,name=lastReject" json:"lastReject,omitempty"`
+	NConf                  int32  `protobuf:"varint,13,opt,name=nConf" json:"nConf,omitempty"`
+	NMin                   int32  `protobuf:"varint,14,opt,name=nMin" json:"nMin,omitempty"`
+	SecondsUntilNMin       int64  `protobuf:"varint,15,opt,name=secondsUntilNMin" json:"secondsUntilNMin,omitempty"`
+}
+
+func (m *AccountCheck) Reset()                    { *m = AccountCheck{} }
+func (m *AccountCheck) String() string            { return proto.CompactTextString(m) }
+func (*AccountCheck) ProtoMessage()               {}
+func (*AccountCheck) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *AccountCheck) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *AccountCheck) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetRewardRecipient() uint64 {
+	if m != nil {
+		return m.RewardRecipient
+	}
+	return 0
+}
+
+func (m *AccountCheck) GetRewardRecipientAddress() string {
+	if m != nil {
+		return m.RewardRecipientAddress
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetAssignedToPool() bool {
+	if m != nil {
+		return m.AssignedToPool
+	}
+	return false
+}
+
+func (m *AccountCheck) GetEffective() bool {
+	if m != nil {
+		return m.Effective
+	}
+	return false
+}
+
+func (m *AccountCheck) GetBanned() bool {
+	if m != nil {
+		return m.Banned
+	}
+	return false
+}
+
+func (m *AccountCheck) GetBanReason() string {
+	if m != nil {
+		return m.BanReason
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetLastSubmitHeight() uint64 {
+	if m != nil {
+		return m.LastSubmitHeight
+	}
+	return 0
+}
+
+func (m *AccountCheck) GetLastSubmit() string {
+	if m != nil {
+		return m.LastSubmit
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetLastRejectReason() string {
+	if m != nil {
+		return m.LastRejectReason
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetLastReject() string {
+	if m != nil {
+		return m.LastReject
+	}
+	return ""
+}
+
+func (m *AccountCheck) GetNConf() int32 {
+	if m != nil {
+		return m.NConf
+	}
+	return 0
+}
+
+func (m *AccountCheck) GetNMin() int32 {
+	if m != nil {
+		return m.NMin
+	}
+	return 0
+}
+
+func (m *AccountCheck) GetSecondsUntilNMin() int64 {
+	if m != nil {
+		return m.SecondsUntilNMin
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Void)(nil), "api.Void")
 	proto.RegisterType((*MinerRequest)(nil), "api.MinerRequest")
@@ -380,6 +526,8 @@ func init() {
 	proto.RegisterType((*PoolStatsInfo)(nil), "api.PoolStatsInfo")
 	proto.RegisterType((*BlockInfo)(nil), "api.BlockInfo")
 	proto.RegisterType((*PoolConfigInfo)(nil), "api.PoolConfigInfo")
+	proto.RegisterType((*AccountCheckRequest)(nil), "api.AccountCheckRequest")
+	proto.RegisterType((*AccountCheck)(nil), "api.AccountCheck")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -397,6 +545,7 @@ type ApiClient interface {
 	GetPoolStatsInfo(ctx context.Context, in *Void, opts ...grpc.CallOption) (*PoolStatsInfo, error)
 	GetBlockInfo(ctx context.Context, in *Void, opts ...grpc.CallOption) (*BlockInfo, error)
 	GetPoolConfigInfo(ctx context.Context, in *Void, opts ...grpc.CallOption) (*PoolConfigInfo, error)
+	CheckAccount(ctx context.Context, in *AccountCheckRequest, opts ...grpc.CallOption) (*AccountCheck, error)
 }
 
 type apiClient struct {
@@ -443,6 +592,15 @@ func (c *apiClient) GetPoolConfigInfo(ctx context.Context, in *Void, opts ...grp
 	return out, nil
 }
 
+func (c *apiClient) CheckAccount(ctx context.Context, in *AccountCheckRequest, opts ...grpc.CallOption) (*AccountCheck, error) {
+	out := new(AccountCheck)
+	err := grpc.Invoke(ctx, "/api.Api/CheckAccount", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Api service
 
 type ApiServer interface {
@@ -450,6 +608,7 @@ type ApiServer interface {
 	GetPoolStatsInfo(context.Context, *Void) (*PoolStatsInfo, error)
 	GetBlockInfo(context.Context, *Void) (*BlockInfo, error)
 	GetPoolConfigInfo(context.Context, *Void) (*PoolConfigInfo, error)
+	CheckAccount(context.Context, *AccountCheckRequest) (*AccountCheck, error)
 }
 
 func RegisterApiServer(s *grpc.Server, srv ApiServer) {
@@ -528,6 +687,24 @@ func _Api_GetPoolConfigInfo_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Api_CheckAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).CheckAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/CheckAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).CheckAccount(ctx, req.(*AccountCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Api_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Api",
 	HandlerType: (*ApiServer)(nil),
@@ -548,6 +725,10 @@ var _Api_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetPoolConfigInfo",
 			Handler:    _Api_GetPoolConfigInfo_Handler,
 		},
+		{
+			MethodName: "CheckAccount",
+			Handler:    _Api_CheckAccount_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/api.proto",
@@ -556,49 +737,63 @@ var _Api_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("protos/api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 698 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x54, 0xdd, 0x6e, 0x13, 0x3d,
-	0x10, 0xcd, 0xe6, 0xb7, 0x3b, 0x4d, 0xf2, 0xb5, 0xee, 0x07, 0x5a, 0x55, 0xa8, 0x8a, 0x56, 0x5c,
-	0x44, 0x02, 0x15, 0x44, 0xe1, 0x01, 0x4a, 0xa3, 0x96, 0x48, 0xa4, 0xaa, 0x9c, 0xaa, 0xbd, 0x76,
-	0x93, 0x49, 0x62, 0x75, 0x63, 0x2f, 0xbb, 0x0e, 0xd0, 0x2b, 0x5e, 0x8d, 0x3b, 0x1e, 0x80, 0x27,
-	0xe1, 0x0d, 0xd0, 0xcc, 0x6e, 0x92, 0xdd, 0xd2, 0x3b, 0xcf, 0x99, 0x63, 0xcf, 0xcc, 0x39, 0xb6,
-	0x61, 0x2f, 0x4e, 0xac, 0xb3, 0xe9, 0x1b, 0x15, 0xeb, 0x63, 0x5e, 0x8a, 0x9a, 0x8a, 0x75, 0xd8,
-	0x84, 0xfa, 0x8d, 0xd5, 0xd3, 0xf0, 0x08, 0xda, 0x23, 0x6d, 0x30, 0x91, 0xf8, 0x65, 0x85, 0xa9,
-	0x13, 0x5d, 0xa8, 0x0e, 0x07, 0x81, 0xd7, 0xf3, 0xfa, 0x75, 0x59, 0x1d, 0x0e, 0xc2, 0x5f, 0x55,
-	0xf0, 0x99, 0x30, 0x34, 0x33, 0x2b, 0x02, 0x68, 0xa9, 0xe9, 0x34, 0xc1, 0x34, 0x65, 0x8a, 0x2f,
-	0xd7, 0xa1, 0x10, 0x50, 0x37, 0x6a, 0x89, 0x41, 0x95, 0x61, 0x5e, 0x13, 0x3b, 0x46, 0x33, 0xd5,
-	0x66, 0x1e, 0xd4, 0x7a, 0x5e, 0xbf, 0x26, 0xd7, 0xa1, 0xe8, 0xc3, 0x7f, 0x0b, 0x9d, 0x3a, 0x9b,
-	0xe8, 0x89, 0x8a, 0xc6, 0x0b, 0x95, 0x60, 0x50, 0xef, 0x79, 0x7d, 0x4f, 0x3e, 0x86, 0xc5, 0x6b,
-	0xd8, 0xc7, 0xd9, 0x0c, 0x27, 0x4e, 0x7f, 0xc5, 0x33, 0x15, 0xab, 0x89, 0x76, 0x0f, 0x41, 0x83,
-	0xb9, 0xff, 0x26, 0xc4, 0x21, 0xec, 0x4c, 0x51, 0x4d, 0x23, 0x6d, 0x30, 0x68, 0xf2, 0x0c, 0x9b,
-	0x58, 0xbc, 0x87, 0x67, 0x91, 0x4a, 0xdd, 0x29, 0xef, 0xf8, 0x18, 0xd9, 0xc9, 0xfd, 0x27, 0xd4,
-	0xf3, 0x85, 0x0b, 0x5a, 0x4c, 0x7c, 0x3a, 0x29, 0xfe, 0x87, 0x86, 0x39, 0xb3, 0x66, 0x16, 0xec,
-	0xf4, 0xbc, 0x7e, 0x43, 0x66, 0x81, 0x08, 0xa1, 0x1d, 0xab, 0x07, 0xbb, 0x72, 0x03, 0x74, 0x4a,
-	0x47, 0x81, 0xcf, 0x53, 0x97, 0xb0, 0x5c, 0x49, 0xd8, 0x28, 0xf9, 0x03, 0x3a, 0x57, 0xd6, 0x46,
-	0x63, 0xa7, 0x5c, 0xca, 0x62, 0x1e, 0x01, 0x2c, 0x49, 0xd9, 0x33, 0xbb, 0x32, 0x8e, 0xf5, 0x6c,
-	0xc8, 0x02, 0x42, 0x0d, 0x6f, 0x26, 0xa4, 0x9d, 0x9b, 0xf1, 0xab, 0x3c, 0xfe, 0xd3, 0x49, 0x12,
-	0xdd, 0xa0, 0x1b, 0xe8, 0xd9, 0x8c, 0x45, 0xf7, 0xe4, 0x3a, 0x0c, 0xff, 0x78, 0xe0, 0xf3, 0x68,
-	0x5c, 0xfd, 0x39, 0x34, 0x17, 0xd9, 0xfc, 0x99, 0xd9, 0x79, 0x44, 0x5d, 0xdd, 0xa9, 0x14, 0xaf,
-	0x55, 0x32, 0x47, 0xc7, 0xa5, 0xea, 0xb2, 0x80, 0x90, 0x20, 0xe9, 0xc4, 0xda, 0x98, 0x4f, 0xef,
-	0xc8, 0x2c, 0x10, 0x6f, 0xe1, 0x60, 0x8e, 0x06, 0x13, 0xe5, 0xb4, 0x35, 0x63, 0x3d, 0x37, 0xca,
-	0xad, 0x72, 0x53, 0x7d, 0xf9, 0x54, 0x8a, 0xfa, 0xe4, 0x59, 0x87, 0x03, 0xb6, 0xb3, 0x2e, 0xd7,
-	0x21, 0x55, 0xe0, 0x25, 0x3b, 0xe8, 0xcb, 0x2c, 0x28, 0x59, 0xdb, 0x7a, 0x64, 0x6d, 0x00, 0xad,
-	0x49, 0x82, 0xca, 0xe1, 0x94, 0x6d, 0xf2, 0xe5, 0x3a, 0x0c, 0x7f, 0xd6, 0xa0, 0xcb, 0xf2, 0x58,
-	0x33, 0xd3, 0x73, 0x1e, 0x9c, 0xbc, 0xb3, 0x36, 0x3a, 0x47, 0xcc, 0x2e, 0x9e, 0xc7, 0x2a, 0x95,
-	0x30, 0xf1, 0x12, 0x3a, 0xeb, 0xc3, 0x3f, 0xeb, 0xa5, 0x5e, 0xeb, 0x50, 0x06, 0x89, 0x35, 0xd2,
-	0x46, 0x2f, 0x57, 0xcb, 0x2b, 0x36, 0x3e, 0xbf, 0xe5, 0x65, 0x90, 0xc6, 0xb9, 0xfe, 0x7e, 0x8e,
-	0x99, 0x18, 0x35, 0x99, 0x05, 0xa2, 0x07, 0xbb, 0xb7, 0xda, 0x18, 0x4c, 0xb2, 0x26, 0xb2, 0x1b,
-	0x5d, 0x84, 0xe8, 0x45, 0x5d, 0x8f, 0xb4, 0x61, 0x15, 0x1a, 0x92, 0xd7, 0x84, 0x5d, 0x9e, 0xde,
-	0x5c, 0xb0, 0x00, 0x0d, 0xc9, 0x6b, 0xc6, 0x88, 0xb7, 0x93, 0x63, 0xc4, 0x7b, 0x01, 0xfe, 0x18,
-	0xdd, 0xa5, 0xfd, 0x46, 0x75, 0x7d, 0xae, 0xbb, 0x05, 0x48, 0x81, 0x31, 0xba, 0x5b, 0xc4, 0xfb,
-	0xe8, 0x81, 0x08, 0xc0, 0x84, 0x12, 0x46, 0xfd, 0x8d, 0xd1, 0x0d, 0x94, 0xce, 0x28, 0xbb, 0x4c,
-	0x29, 0x42, 0xf4, 0x86, 0xc7, 0xe8, 0x46, 0xda, 0x64, 0x73, 0x12, 0xab, 0xcd, 0xac, 0xc7, 0x30,
-	0xd9, 0x73, 0x83, 0x49, 0xaa, 0xad, 0x09, 0x3a, 0x99, 0x3d, 0x79, 0x48, 0x9d, 0x90, 0x3b, 0x57,
-	0xab, 0xbb, 0x48, 0x4f, 0x86, 0x83, 0xa0, 0xcb, 0x32, 0x97, 0xb0, 0x77, 0xbf, 0x3d, 0xa8, 0x9d,
-	0xc6, 0x5a, 0x9c, 0x40, 0xfb, 0x82, 0x0f, 0xce, 0xff, 0xa2, 0xfd, 0x63, 0xfa, 0xd2, 0x8a, 0x9f,
-	0xd7, 0x61, 0x77, 0x0b, 0x11, 0x25, 0xac, 0x88, 0x13, 0xd8, 0xbb, 0x40, 0x57, 0x7e, 0x77, 0x3e,
-	0xb3, 0xe8, 0xf7, 0x3b, 0x14, 0xbc, 0x2c, 0xa5, 0xc3, 0x8a, 0x78, 0xc5, 0x95, 0xb6, 0x4f, 0xa5,
-	0xb0, 0x21, 0xab, 0xb0, 0x49, 0x85, 0x15, 0xf1, 0x01, 0xf6, 0xf3, 0x0a, 0x85, 0x3b, 0x56, 0xd8,
-	0x71, 0xb0, 0x29, 0xb1, 0xcd, 0x87, 0x95, 0xbb, 0x26, 0xff, 0xc5, 0x27, 0x7f, 0x03, 0x00, 0x00,
-	0xff, 0xff, 0xdc, 0x6c, 0x87, 0x89, 0x9f, 0x05, 0x00, 0x00,
+	// 917 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x56, 0xdd, 0x6e, 0xeb, 0x44,
+	0x10, 0xce, 0x6f, 0x1b, 0x6f, 0x93, 0xb4, 0xdd, 0xc2, 0x91, 0x55, 0xa1, 0xa3, 0xc8, 0x42, 0x28,
+	0x02, 0x74, 0x0e, 0xa2, 0xc0, 0x1d, 0x17, 0xa1, 0xd1, 0x29, 0x91, 0xe8, 0x51, 0xb5, 0x29, 0x3d,
+	0xd7, 0x1b, 0x7b, 0x92, 0x2c, 0x75, 0x76, 0x8d, 0xbd, 0xa1, 0xf4, 0x8a, 0x1b, 0x9e, 0x80, 0x27,
+	0xe2, 0x8e, 0xe7, 0xe1, 0x0d, 0xd0, 0xcc, 0xda, 0xb1, 0x9d, 0x94, 0xbb, 0x9d, 0x6f, 0xbe, 0xdd,
+	0xd9, 0xf9, 0x66, 0x76, 0x6c, 0x76, 0x96, 0xa4, 0xc6, 0x9a, 0xec, 0xad, 0x4c, 0xd4, 0x1b, 0x5a,
+	0xf2, 0xb6, 0x4c, 0x54, 0x70, 0xc4, 0x3a, 0x0f, 0x46, 0x45, 0xc1, 0x6b, 0xd6, 0xbf, 0x55, 0x1a,
+	0x52, 0x01, 0xbf, 0x6e, 0x21, 0xb3, 0x7c, 0xc8, 0x5a, 0xb3, 0xa9, 0xdf, 0x1c, 0x35, 0xc7, 0x1d,
+	0xd1, 0x9a, 0x4d, 0x83, 0x7f, 0x5a, 0xcc, 0x23, 0xc2, 0x4c, 0x2f, 0x0d, 0xf7, 0xd9, 0xb1, 0x8c,
+	0xa2, 0x14, 0xb2, 0x8c, 0x28, 0x9e, 0x28, 0x4c, 0xce, 0x59, 0x47, 0xcb, 0x0d, 0xf8, 0x2d, 0x82,
+	0x69, 0x8d, 0xec, 0x04, 0x74, 0xa4, 0xf4, 0xca, 0x6f, 0x8f, 0x9a, 0xe3, 0xb6, 0x28, 0x4c, 0x3e,
+	0x66, 0xa7, 0x6b, 0x95, 0x59, 0x93, 0xaa, 0x50, 0xc6, 0xf3, 0xb5, 0x4c, 0xc1, 0xef, 0x8c, 0x9a,
+	0xe3, 0xa6, 0xd8, 0x87, 0xf9, 0x97, 0xec, 0x1c, 0x96, 0x4b, 0x08, 0xad, 0xfa, 0x0d, 0xae, 0x65,
+	0x22, 0x43, 0x65, 0x9f, 0xfd, 0x2e, 0x71, 0x0f, 0x1d, 0xfc, 0x92, 0xf5, 0x22, 0x90, 0x51, 0xac,
+	0x34, 0xf8, 0x47, 0x94, 0xc3, 0xce, 0xe6, 0xdf, 0xb0, 0x8f, 0x63, 0x99, 0xd9, 0x09, 0xed, 0xf8,
+	0x21, 0x36, 0xe1, 0xe3, 0x8f, 0xa0, 0x56, 0x6b, 0xeb, 0x1f, 0x13, 0xf1, 0x65, 0x27, 0xff, 0x88,
+	0x75, 0xf5, 0xb5, 0xd1, 0x4b, 0xbf, 0x37, 0x6a, 0x8e, 0xbb, 0xc2, 0x19, 0x3c, 0x60, 0xfd, 0x44,
+	0x3e, 0x9b, 0xad, 0x9d, 0x82, 0x95, 0x2a, 0xf6, 0x3d, 0xca, 0xba, 0x86, 0xe5, 0x4a, 0xb2, 0x9d,
+	0x92, 0x7f, 0xb0, 0xc1, 0x9d, 0x31, 0xf1, 0xdc, 0x4a, 0x9b, 0x91, 0x98, 0xaf, 0x19, 0xdb, 0xa0,
+	0xb2, 0xd7, 0x66, 0xab, 0x2d, 0xe9, 0xd9, 0x15, 0x15, 0x04, 0x2f, 0xbc, 0xcb, 0x10, 0x77, 0xee,
+	0xd2, 0x6f, 0x51, 0xfa, 0x2f, 0x3b, 0x51, 0x74, 0x0d, 0x76, 0xaa, 0x96, 0x4b, 0x12, 0xbd, 0x29,
+	0x0a, 0x33, 0xf8, 0xb7, 0xc9, 0x3c, 0x4a, 0x8d, 0xa2, 0xbf, 0x62, 0x47, 0x6b, 0x97, 0xbf, 0x2b,
+	0x76, 0x6e, 0xe1, 0xad, 0x16, 0x32, 0x83, 0x7b, 0x99, 0xae, 0xc0, 0x52, 0xa8, 0x8e, 0xa8, 0x20,
+	0x28, 0x48, 0x16, 0x1a, 0x93, 0xd0, 0xe9, 0x03, 0xe1, 0x0c, 0xfe, 0x15, 0xbb, 0x58, 0x81, 0x86,
+	0x54, 0x5a, 0x65, 0xf4, 0x5c, 0xad, 0xb4, 0xb4, 0xdb, 0xbc, 0xa8, 0x9e, 0x78, 0xc9, 0x85, 0xf7,
+	0xa4, 0x5c, 0x67, 0x53, 0x2a, 0x67, 0x47, 0x14, 0x26, 0x46, 0xa0, 0x25, 0x55, 0xd0, 0x13, 0xce,
+	0xa8, 0x95, 0xf6, 0x78, 0xaf, 0xb4, 0x3e, 0x3b, 0x0e, 0x53, 0x90, 0x16, 0x22, 0x2a, 0x93, 0x27,
+	0x0a, 0x33, 0xf8, 0xbb, 0xcd, 0x86, 0x24, 0x8f, 0xd1, 0x4b, 0xb5, 0xa2, 0xc4, 0xb1, 0x76, 0xc6,
+	0xc4, 0xef, 0x00, 0x5c, 0xe3, 0x35, 0x49, 0xa5, 0x1a, 0xc6, 0x3f, 0x65, 0x83, 0xe2, 0xf0, 0x9f,
+	0xd4, 0x46, 0x15, 0x3a, 0xd4, 0x41, 0x64, 0xdd, 0x2a, 0xad, 0x36, 0xdb, 0xcd, 0x1d, 0x15, 0x3e,
+	0xef, 0xf2, 0x3a, 0x88, 0xe9, 0xdc, 0xff, 0xfe, 0x0e, 0x9c, 0x18, 0x6d, 0xe1, 0x0c, 0x3e, 0x62,
+	0x27, 0x1f, 0x94, 0xd6, 0x90, 0xba, 0x4b, 0xb8, 0x8e, 0xae, 0x42, 0xf8, 0xa2, 0xee, 0x6f, 0x95,
+	0x26, 0x15, 0xba, 0x82, 0xd6, 0x88, 0xbd, 0x9f, 0x3c, 0xdc, 0x90, 0x00, 0x5d, 0x41, 0x6b, 0xc2,
+	0x90, 0xd7, 0xcb, 0x31, 0xe4, 0x7d, 0xc2, 0xbc, 0x39, 0xd8, 0xf7, 0xe6, 0x09, 0xe3, 0x7a, 0x14,
+	0xb7, 0x04, 0x50, 0x81, 0x39, 0xd8, 0x0f, 0x00, 0x8f, 0xf1, 0x33, 0x12, 0x18, 0x11, 0x6a, 0x18,
+	0xde, 0x6f, 0x0e, 0x76, 0x2a, 0x95, 0xa3, 0x9c, 0x10, 0xa5, 0x0a, 0xe1, 0x1b, 0x9e, 0x83, 0xbd,
+	0x55, 0xda, 0xe5, 0x89, 0xac, 0x3e, 0xb1, 0xf6, 0x61, 0x2c, 0xcf, 0x03, 0xa4, 0x99, 0x32, 0xda,
+	0x1f, 0xb8, 0xf2, 0xe4, 0x26, 0xde, 0x04, 0xab, 0x73, 0xb7, 0x5d, 0xc4, 0x2a, 0x9c, 0x4d, 0xfd,
+	0x21, 0xc9, 0x5c, 0xc3, 0x82, 0xb7, 0xec, 0x62, 0x12, 0x86, 0xf8, 0x22, 0xae, 0xd7, 0x10, 0x3e,
+	0x16, 0x83, 0x0a, 0x47, 0x91, 0x83, 0x77, 0xa3, 0xc8, 0x99, 0xc1, 0x9f, 0x1d, 0xd6, 0xaf, 0xee,
+	0xd8, 0x9f, 0x69, 0xd5, 0x29, 0xd6, 0xaa, 0x4f, 0xb1, 0x31, 0x3b, 0x4d, 0xe1, 0x49, 0xa6, 0x91,
+	0x80, 0x50, 0x25, 0x0a, 0xb4, 0xab, 0x69, 0x47, 0xec, 0xc3, 0xfc, 0x3b, 0xf6, 0x6a, 0x0f, 0x9a,
+	0xe4, 0x47, 0xba, 0x9e, 0xff, 0x1f, 0x2f, 0xff, 0x8c, 0x0d, 0x65, 0x96, 0xa9, 0x95, 0x86, 0xe8,
+	0xde, 0x60, 0x9e, 0x54, 0xfa, 0x9e, 0xd8, 0x43, 0xb1, 0x82, 0xbb, 0xf7, 0x4d, 0x2d, 0xd0, 0x13,
+	0x25, 0x80, 0x8f, 0x77, 0x21, 0xb5, 0x86, 0x88, 0x3a, 0xa1, 0x27, 0x72, 0x0b, 0x77, 0x2d, 0xa4,
+	0x16, 0x20, 0x33, 0xa3, 0xf3, 0xa7, 0x50, 0x02, 0xfc, 0x73, 0x76, 0x86, 0x43, 0x6e, 0xbe, 0x5d,
+	0x6c, 0x94, 0xcd, 0x87, 0x9f, 0x47, 0xe9, 0x1d, 0xe0, 0x38, 0x06, 0x4a, 0x8c, 0x3a, 0xc4, 0x13,
+	0x15, 0xa4, 0x38, 0x4b, 0xc0, 0x2f, 0x10, 0xda, 0x3c, 0xe0, 0x09, 0xb1, 0x0e, 0xf0, 0xe2, 0x2c,
+	0x87, 0xf9, 0xfd, 0xf2, 0x2c, 0x87, 0x94, 0x33, 0x76, 0x50, 0x9d, 0xb1, 0xf8, 0x45, 0xc1, 0xbe,
+	0x1e, 0xba, 0xbe, 0xc6, 0x35, 0x46, 0xcd, 0x20, 0x34, 0x3a, 0xca, 0x7e, 0xd6, 0x56, 0xc5, 0xd4,
+	0xf7, 0xa7, 0xd4, 0x74, 0x07, 0xf8, 0xd7, 0x7f, 0xb5, 0x58, 0x7b, 0x92, 0x28, 0x7e, 0xc5, 0xfa,
+	0x37, 0x60, 0xcb, 0x6f, 0xd8, 0xf9, 0x1b, 0xfc, 0x14, 0x56, 0x3f, 0x7a, 0x97, 0xc3, 0x12, 0x42,
+	0x4a, 0xd0, 0xe0, 0x57, 0xec, 0xec, 0x06, 0x6c, 0x7d, 0x5e, 0x7b, 0xc4, 0xc2, 0xaf, 0xe6, 0x25,
+	0xa7, 0x65, 0xcd, 0x1d, 0x34, 0xf8, 0x17, 0x14, 0xa9, 0x1c, 0xb1, 0x95, 0x0d, 0x2e, 0xc2, 0xce,
+	0x15, 0x34, 0xf8, 0xb7, 0xec, 0x3c, 0x8f, 0x50, 0x99, 0x4d, 0x95, 0x1d, 0x17, 0xbb, 0x10, 0xa5,
+	0x3f, 0x68, 0xf0, 0xef, 0x59, 0x9f, 0x9a, 0x3a, 0x6f, 0x70, 0xee, 0x13, 0xed, 0x85, 0x07, 0x72,
+	0x79, 0x7e, 0xe0, 0x09, 0x1a, 0x8b, 0x23, 0xfa, 0x05, 0xb8, 0xfa, 0x6f, 0x00, 0x56, 0x13, 0xf7,
+	0xfe, 0x16, 0x08, 0x00, 0x00,
 }
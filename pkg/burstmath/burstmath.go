@@ -294,3 +294,8 @@ func DateToTimeStamp(date time.Time) int64 {
 	}
 	return ts
 }
+
+// TimeStampToDate converts a timestamp counted since block chain start into a date
+func TimeStampToDate(ts int64) time.Time {
+	return time.Unix(ts+blockChainStart, 0)
+}
@@ -6,19 +6,100 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"net"
+	"os"
 	"time"
 
 	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/proxyproto"
 
 	"go.uber.org/zap"
 )
 
+// Database drivers of the pool's db
+const (
+	DriverMariaDB  = "mariadb"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type DBConfig struct {
+	Driver   string `yaml:"driver"`
 	Host     string `yaml:"host"`
 	Port     uint32 `yaml:"port"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslMode"`
+
+	// limits of the connection pool, 0 keeps the defaults of database/sql
+	MaxOpenConns       int   `yaml:"maxOpenConns"`
+	MaxIdleConns       int   `yaml:"maxIdleConns"`
+	ConnMaxLifetime    int64 `yaml:"connMaxLifetime"`
+	ConnMaxLifetimeDur time.Duration
+}
+
+// MiningListener is a port miners can connect to with its own policies
+type MiningListener struct {
+	ListenAddress          string   `yaml:"listenAddress"`
+	Port                   uint     `yaml:"port"`
+	TLSCert                string   `yaml:"tlsCert"`
+	TLSKey                 string   `yaml:"tlsKey"`
+	DeadlineLimit          uint64   `yaml:"deadlineLimit"`
+	AllowRequestsPerSecond int      `yaml:"allowRequestsPerSecond"`
+	AllowSubmitsPerSecond  int      `yaml:"allowSubmitsPerSecond"`
+	MaxSubmitsPerRound     int      `yaml:"maxSubmitsPerRound"`
+	AllowedUserAgents      []string `yaml:"allowedUserAgents"`
+	ProxyProtocol          bool     `yaml:"proxyProtocol"`
+}
+
+// Archive modes for rows removed by the retention
+const (
+	ArchiveNone  = ""
+	ArchiveTable = "table"
+	ArchiveFile  = "file"
+)
+
+// RetentionConfig defines how many blocks of history are kept in the db and where the
+// removed rows end up
+type RetentionConfig struct {
+	Blocks           uint64 `yaml:"blocks"`
+	NonceSubmissions uint64 `yaml:"nonceSubmissions"`
+	Transactions     uint64 `yaml:"transactions"`
+	BatchSize        int    `yaml:"batchSize"`
+	Archive          string `yaml:"archive"`
+	ArchiveDir       string `yaml:"archiveDir"`
+}
+
+// NonceWriterConfig defines how nonce submissions are queued and written to the db in
+// batches. Queued submissions are logged to files in WALDir until they are written.
+type NonceWriterConfig struct {
+	BatchSize        int    `yaml:"batchSize"`
+	QueueSize        int    `yaml:"queueSize"`
+	QueueTimeout     int64  `yaml:"queueTimeout"`
+	FlushInterval    int64  `yaml:"flushInterval"`
+	WALDir           string `yaml:"walDir"`
+	WALMaxSize       int64  `yaml:"walMaxSize"`
+	WALSync          bool   `yaml:"walSync"`
+	FlushIntervalDur time.Duration
+	QueueTimeoutDur  time.Duration
+	WALMaxSizeBytes  int64
+}
+
+// SnapshotConfig defines where and how often the cache is saved for a fast restart
+type SnapshotConfig struct {
+	File        string `yaml:"file"`
+	Interval    int64  `yaml:"interval"`
+	IntervalDur time.Duration
+}
+
+// LeaderElectionConfig lets several instances share a db, only the one holding the lease
+// runs the jobs, submits nonces and sends payments
+type LeaderElectionConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	ID       string `yaml:"id"`
+	Lease    int64  `yaml:"lease"`
+	LeaseDur time.Duration
 }
 
 type Config struct {
@@ -35,6 +116,7 @@ type Config struct {
 	WinnerShare            float64  `yaml:"winnerShare"`
 	DB                     DBConfig `yaml:"db"`
 	WalletDB               DBConfig `yaml:"walletDB"`
+	ReadDB                 DBConfig `yaml:"readDB"`
 	FeeAccountID           uint64   `yaml:"feeAccountId"`
 	PoolTxFee              int64    `yaml:"poolTxFee"`
 	MinerTxFee             int64    `yaml:"minerTxFee"`
@@ -64,6 +146,39 @@ type Config struct {
 	NodeComCert            string   `yaml:"nodeComCert"`
 	BlacklistedAccountIDs  []uint64 `yaml:"blacklistedAccountIds"`
 	AccountIDBlacklist     map[uint64]struct{}
+	UpstreamPoolURL        string `yaml:"upstreamPoolUrl"`
+	NotificationURL        string `yaml:"notificationUrl"`
+	SubmitBefore           int64  `yaml:"submitBefore"`
+	SubmitBeforeDur        time.Duration
+	LateSubmissionGrace    int64 `yaml:"lateSubmissionGrace"`
+	LateSubmissionGraceDur time.Duration
+	TargetDeadlineMissRate float64          `yaml:"targetDeadlineMissRate"`
+	SubmissionsPerRound    float64          `yaml:"submissionsPerRound"`
+	MiningListeners        []MiningListener `yaml:"miningListeners"`
+	WebServerProxyProtocol bool             `yaml:"webServerProxyProtocol"`
+	TrustedProxies         []string         `yaml:"trustedProxies"`
+	TrustedProxyNets       []*net.IPNet
+	AllowSubmitsPerSecond  int            `yaml:"allowSubmitsPerSecond"`
+	MaxSubmitsPerRound     int            `yaml:"maxSubmitsPerRound"`
+	AutoBanThreshold       int            `yaml:"autoBanThreshold"`
+	AutoBanThresholds      map[string]int `yaml:"autoBanThresholds"`
+	AutoBanWindow          int64          `yaml:"autoBanWindow"`
+	AutoBanWindowDur       time.Duration
+	AutoBanDuration        int64 `yaml:"autoBanDuration"`
+	AutoBanDurationDur     time.Duration
+	DiagnosticsAllowedFrom []string `yaml:"diagnosticsAllowedFrom"`
+	DiagnosticsNets        []*net.IPNet
+	AdminToken             string `yaml:"adminToken"`
+	AbandonedPayoutMin     int64  `yaml:"abandonedPayoutMin"`
+	AbandonedNoticeDays    int    `yaml:"abandonedNoticeDays"`
+	AbandonedNoticeDur     time.Duration
+	AbandonedRestoreDays   int `yaml:"abandonedRestoreDays"`
+	AbandonedRestoreDur    time.Duration
+	Retention              RetentionConfig      `yaml:"retention"`
+	NonceWriter            NonceWriterConfig    `yaml:"nonceWriter"`
+	Snapshot               SnapshotConfig       `yaml:"snapshot"`
+	VerifyShares           bool                 `yaml:"verifyShares"`
+	LeaderElection         LeaderElectionConfig `yaml:"leaderElection"`
 }
 
 var Cfg Config
@@ -85,7 +200,7 @@ func LoadConfig() {
 }
 
 func validateConfig() {
-	if Cfg.SecretPhrase == "" {
+	if Cfg.SecretPhrase == "" && Cfg.UpstreamPoolURL == "" {
 		Logger.Fatal("'secretPhrase' can't be empty")
 	}
 
@@ -101,20 +216,33 @@ func validateConfig() {
 		Logger.Fatal("'poolFeeShare' must be between 0.0 and 1.0")
 	}
 
-	if Cfg.DB.Host == "" {
-		Cfg.DB.Host = "127.0.0.1"
+	if Cfg.DB.Driver == "" {
+		Cfg.DB.Driver = DriverMariaDB
 	}
 
-	if Cfg.DB.Port == 0 {
-		Cfg.DB.Port = 3306
+	if Cfg.DB.Host == "" {
+		Cfg.DB.Host = "127.0.0.1"
 	}
 
 	if Cfg.DB.Name == "" {
 		Logger.Fatal("'dbName' can't be empty")
 	}
 
-	if Cfg.DB.User == "" {
-		Logger.Fatal("'dbUser' can't be empty")
+	switch Cfg.DB.Driver {
+	case DriverMariaDB, DriverPostgres:
+		if Cfg.DB.Port == 0 && Cfg.DB.Driver == DriverPostgres {
+			Cfg.DB.Port = 5432
+		} else if Cfg.DB.Port == 0 {
+			Cfg.DB.Port = 3306
+		}
+
+		if Cfg.DB.User == "" {
+			Logger.Fatal("'dbUser' can't be empty")
+		}
+	case DriverSQLite:
+		// the name is the path of the db file
+	default:
+		Logger.Fatal("'db.driver' needs to be 'mariadb', 'postgres' or 'sqlite'")
 	}
 
 	if Cfg.WalletDB.Host == "" {
@@ -125,6 +253,11 @@ func validateConfig() {
 		Cfg.WalletDB.Port = 3306
 	}
 
+	validateReadDB()
+	validatePoolLimits("db", &Cfg.DB)
+	validatePoolLimits("walletDB", &Cfg.WalletDB)
+	validatePoolLimits("readDB", &Cfg.ReadDB)
+
 	if Cfg.FeeAccountID == 0 && Cfg.PoolFeeShare > 0.0 {
 		Logger.Fatal("'feeAccountId' can't be empty if PoolFee is over 0.0")
 	}
@@ -137,7 +270,7 @@ func validateConfig() {
 		Logger.Fatal("'InactiveAfterXBlocks' must be bigger than 0")
 	}
 
-	if Cfg.PoolPort == 0 {
+	if Cfg.PoolPort == 0 && len(Cfg.MiningListeners) == 0 {
 		Logger.Fatal("'poolPort' can't be empty or 0")
 	}
 
@@ -158,6 +291,111 @@ func validateConfig() {
 		Logger.Info("Using default 4 for allowRequestsPerSecond")
 	}
 
+	if Cfg.AllowSubmitsPerSecond < 0 {
+		Logger.Fatal("'allowSubmitsPerSecond' can't be negativ")
+	}
+
+	if Cfg.AllowSubmitsPerSecond == 0 {
+		Cfg.AllowSubmitsPerSecond = Cfg.AllowRequestsPerSecond
+	}
+
+	if Cfg.MaxSubmitsPerRound < 0 {
+		Logger.Fatal("'maxSubmitsPerRound' can't be negativ")
+	}
+
+	if Cfg.AutoBanThreshold < 0 || Cfg.AutoBanWindow < 0 || Cfg.AutoBanDuration < 0 {
+		Logger.Fatal("'autoBanThreshold', 'autoBanWindow' and 'autoBanDuration' can't be negativ")
+	}
+	for reason, threshold := range Cfg.AutoBanThresholds {
+		if threshold < 0 {
+			Logger.Fatal("'autoBanThresholds' can't be negativ", zap.String("reason", reason))
+		}
+	}
+	if Cfg.AutoBanWindow == 0 {
+		Cfg.AutoBanWindow = 600
+	}
+	Cfg.AutoBanWindowDur = time.Duration(Cfg.AutoBanWindow) * time.Second
+	if Cfg.AutoBanDuration == 0 {
+		Cfg.AutoBanDuration = 600
+	}
+	Cfg.AutoBanDurationDur = time.Duration(Cfg.AutoBanDuration) * time.Second
+
+	if len(Cfg.MiningListeners) == 0 {
+		Cfg.MiningListeners = []MiningListener{{
+			ListenAddress: Cfg.PoolListenAddress,
+			Port:          Cfg.PoolPort}}
+	}
+	for i := range Cfg.MiningListeners {
+		l := &Cfg.MiningListeners[i]
+		if l.Port == 0 {
+			Logger.Fatal("'port' of mining listener can't be empty or 0")
+		}
+		if (l.TLSCert == "") != (l.TLSKey == "") {
+			Logger.Fatal("'tlsCert' and 'tlsKey' of mining listener need to be set together",
+				zap.Uint("port", l.Port))
+		}
+		if l.AllowRequestsPerSecond < 0 {
+			Logger.Fatal("'allowRequestsPerSecond' of mining listener can't be negativ",
+				zap.Uint("port", l.Port))
+		}
+		if l.AllowRequestsPerSecond == 0 {
+			l.AllowRequestsPerSecond = Cfg.AllowRequestsPerSecond
+		}
+		if l.AllowSubmitsPerSecond < 0 || l.MaxSubmitsPerRound < 0 {
+			Logger.Fatal("submission limits of mining listener can't be negativ", zap.Uint("port", l.Port))
+		}
+		if l.AllowSubmitsPerSecond == 0 {
+			l.AllowSubmitsPerSecond = Cfg.AllowSubmitsPerSecond
+		}
+		if l.MaxSubmitsPerRound == 0 {
+			l.MaxSubmitsPerRound = Cfg.MaxSubmitsPerRound
+		}
+	}
+
+	var err error
+	Cfg.TrustedProxyNets, err = proxyproto.ParseNets(Cfg.TrustedProxies)
+	if err != nil {
+		Logger.Fatal("'trustedProxies' contains an invalid CIDR", zap.Error(err))
+	}
+	if len(Cfg.TrustedProxyNets) == 0 {
+		if Cfg.WebServerProxyProtocol {
+			Logger.Fatal("'webServerProxyProtocol' requires 'trustedProxies'")
+		}
+		for _, l := range Cfg.MiningListeners {
+			if l.ProxyProtocol {
+				Logger.Fatal("'proxyProtocol' of mining listener requires 'trustedProxies'", zap.Uint("port", l.Port))
+			}
+		}
+	}
+
+	if len(Cfg.DiagnosticsAllowedFrom) == 0 {
+		Cfg.DiagnosticsAllowedFrom = []string{"127.0.0.1", "::1"}
+	}
+	Cfg.DiagnosticsNets, err = proxyproto.ParseNets(Cfg.DiagnosticsAllowedFrom)
+	if err != nil {
+		Logger.Fatal("'diagnosticsAllowedFrom' contains an invalid CIDR", zap.Error(err))
+	}
+
+	if Cfg.AbandonedPayoutMin < 0 || Cfg.AbandonedNoticeDays < 0 || Cfg.AbandonedRestoreDays < 0 {
+		Logger.Fatal("'abandonedPayoutMin', 'abandonedNoticeDays' and 'abandonedRestoreDays' can't be negativ")
+	}
+	if Cfg.AbandonedPayoutMin == 0 {
+		Cfg.AbandonedPayoutMin = Cfg.MinimumPayout
+		if Cfg.AbandonedPayoutMin <= Cfg.MinerTxFee {
+			Cfg.AbandonedPayoutMin = Cfg.MinerTxFee + 1
+		}
+	} else if Cfg.AbandonedPayoutMin <= Cfg.MinerTxFee {
+		Logger.Fatal("'abandonedPayoutMin' needs to be bigger than 'minerTxFee'")
+	}
+	if Cfg.AbandonedNoticeDays == 0 {
+		Cfg.AbandonedNoticeDays = 7
+	}
+	Cfg.AbandonedNoticeDur = time.Duration(Cfg.AbandonedNoticeDays) * 24 * time.Hour
+	if Cfg.AbandonedRestoreDays == 0 {
+		Cfg.AbandonedRestoreDays = 30
+	}
+	Cfg.AbandonedRestoreDur = time.Duration(Cfg.AbandonedRestoreDays) * 24 * time.Hour
+
 	if Cfg.NAVG < 0 {
 		Logger.Fatal("'nAvg' can't be negativ")
 	}
@@ -184,6 +422,21 @@ func validateConfig() {
 		Logger.Fatal("'tMin' can't be negativ")
 	}
 
+	validateRetention()
+	validateNonceWriter()
+
+	if Cfg.Snapshot.File == "" {
+		Cfg.Snapshot.File = "cache.snapshot"
+	}
+	if Cfg.Snapshot.Interval < 0 {
+		Logger.Fatal("'snapshot.interval' can't be negativ")
+	} else if Cfg.Snapshot.Interval == 0 {
+		Cfg.Snapshot.Interval = 5
+	}
+	Cfg.Snapshot.IntervalDur = time.Duration(Cfg.Snapshot.Interval) * time.Minute
+
+	validateLeaderElection()
+
 	if Cfg.PoolTxFee == 0 {
 		Cfg.PoolTxFee = 10000000
 		Logger.Info("Using default 10000000 for Cfg.PoolTxFee")
@@ -203,12 +456,160 @@ func validateConfig() {
 		Cfg.PayoutIntervalDur = time.Duration(Cfg.PayoutInterval) * time.Minute
 	}
 
+	if Cfg.SubmitBefore < 0 {
+		Logger.Fatal("'submitBefore' can't be negativ")
+	} else if Cfg.SubmitBefore == 0 {
+		Cfg.SubmitBeforeDur = 30 * time.Second
+		Logger.Info("Using default 30s for Cfg.SubmitBefore")
+	} else {
+		Cfg.SubmitBeforeDur = time.Duration(Cfg.SubmitBefore) * time.Second
+	}
+
+	if Cfg.LateSubmissionGrace < 0 {
+		Logger.Fatal("'lateSubmissionGrace' can't be negativ")
+	}
+	Cfg.LateSubmissionGraceDur = time.Duration(Cfg.LateSubmissionGrace) * time.Second
+
+	if Cfg.TargetDeadlineMissRate < 0 || Cfg.TargetDeadlineMissRate >= 1 {
+		Logger.Fatal("'targetDeadlineMissRate' needs to be in [0, 1)")
+	}
+
+	if Cfg.SubmissionsPerRound < 0 {
+		Logger.Fatal("'submissionsPerRound' can't be negativ")
+	}
+
 	Cfg.AccountIDBlacklist = make(map[uint64]struct{}, len(Cfg.AccountIDBlacklist))
 	for _, id := range Cfg.BlacklistedAccountIDs {
 		Cfg.AccountIDBlacklist[id] = struct{}{}
 	}
 }
 
+func validateRetention() {
+	retention := &Cfg.Retention
+	if retention.Blocks == 0 {
+		retention.Blocks = 5000
+	}
+	if retention.NonceSubmissions == 0 {
+		retention.NonceSubmissions = retention.Blocks
+	}
+	if retention.Transactions == 0 {
+		retention.Transactions = retention.Blocks
+	}
+	// nonce submissions and transactions get deleted along with their block
+	if retention.NonceSubmissions > retention.Blocks || retention.Transactions > retention.Blocks {
+		Logger.Fatal("'retention.nonceSubmissions' and 'retention.transactions' can't exceed 'retention.blocks'")
+	}
+	if retention.NonceSubmissions < uint64(Cfg.NAVG) {
+		Logger.Fatal("'retention.nonceSubmissions' needs to cover at least 'nAvg' blocks")
+	}
+
+	if retention.BatchSize < 0 {
+		Logger.Fatal("'retention.batchSize' can't be negativ")
+	} else if retention.BatchSize == 0 {
+		retention.BatchSize = 1000
+	}
+
+	switch retention.Archive {
+	case ArchiveNone, ArchiveTable:
+	case ArchiveFile:
+		if retention.ArchiveDir == "" {
+			retention.ArchiveDir = "archive"
+		}
+	default:
+		Logger.Fatal("'retention.archive' needs to be empty, 'table' or 'file'")
+	}
+}
+
+func validateNonceWriter() {
+	nonceWriter := &Cfg.NonceWriter
+	if nonceWriter.BatchSize < 0 || nonceWriter.QueueSize < 0 || nonceWriter.QueueTimeout < 0 ||
+		nonceWriter.FlushInterval < 0 || nonceWriter.WALMaxSize < 0 {
+		Logger.Fatal("'nonceWriter' settings can't be negativ")
+	}
+	if nonceWriter.BatchSize == 0 {
+		nonceWriter.BatchSize = 500
+	}
+	if nonceWriter.QueueSize == 0 {
+		nonceWriter.QueueSize = 20 * nonceWriter.BatchSize
+	}
+	if nonceWriter.QueueSize < nonceWriter.BatchSize {
+		Logger.Fatal("'nonceWriter.queueSize' can't be smaller than 'nonceWriter.batchSize'")
+	}
+	if nonceWriter.QueueTimeout == 0 {
+		nonceWriter.QueueTimeout = 1000
+	}
+	nonceWriter.QueueTimeoutDur = time.Duration(nonceWriter.QueueTimeout) * time.Millisecond
+	if nonceWriter.FlushInterval == 0 {
+		nonceWriter.FlushInterval = 200
+	}
+	nonceWriter.FlushIntervalDur = time.Duration(nonceWriter.FlushInterval) * time.Millisecond
+	if nonceWriter.WALDir == "" {
+		nonceWriter.WALDir = "wal"
+	}
+	if nonceWriter.WALMaxSize == 0 {
+		nonceWriter.WALMaxSize = 64
+	}
+	nonceWriter.WALMaxSizeBytes = nonceWriter.WALMaxSize * 1024 * 1024
+}
+
+// validateReadDB completes the read replica from the primary db, it's the same kind of db
+// and usually differs only in its host. Setting any of its connection settings enables it.
+func validateReadDB() {
+	readDB := &Cfg.ReadDB
+	if readDB.Driver == "" && readDB.Host == "" && readDB.Port == 0 && readDB.User == "" &&
+		readDB.Password == "" && readDB.Name == "" && readDB.SSLMode == "" {
+		return
+	}
+	if readDB.Driver == "" {
+		readDB.Driver = Cfg.DB.Driver
+	} else if readDB.Driver != Cfg.DB.Driver {
+		Logger.Fatal("'readDB.driver' must be the driver of 'db'")
+	}
+	if readDB.Host == "" {
+		readDB.Host = Cfg.DB.Host
+	}
+	if readDB.Port == 0 {
+		readDB.Port = Cfg.DB.Port
+	}
+	if readDB.User == "" {
+		readDB.User = Cfg.DB.User
+		readDB.Password = Cfg.DB.Password
+	}
+	if readDB.Name == "" {
+		readDB.Name = Cfg.DB.Name
+	}
+	if readDB.SSLMode == "" {
+		readDB.SSLMode = Cfg.DB.SSLMode
+	}
+}
+
+func validatePoolLimits(name string, config *DBConfig) {
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 || config.ConnMaxLifetime < 0 {
+		Logger.Fatal("'" + name + "' connection pool limits can't be negativ")
+	}
+	if config.MaxOpenConns > 0 && config.MaxIdleConns > config.MaxOpenConns {
+		Logger.Fatal("'" + name + ".maxIdleConns' can't be bigger than '" + name + ".maxOpenConns'")
+	}
+	config.ConnMaxLifetimeDur = time.Duration(config.ConnMaxLifetime) * time.Second
+}
+
+func validateLeaderElection() {
+	leaderElection := &Cfg.LeaderElection
+	if leaderElection.ID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			Logger.Fatal("'leaderElection.id' is needed, the hostname is unknown", zap.Error(err))
+		}
+		leaderElection.ID = hostname
+	}
+	if leaderElection.Lease < 0 {
+		Logger.Fatal("'leaderElection.lease' can't be negativ")
+	} else if leaderElection.Lease == 0 {
+		leaderElection.Lease = 15
+	}
+	leaderElection.LeaseDur = time.Duration(leaderElection.Lease) * time.Second
+}
+
 func (config DBConfig) DataSourceName(includeDatabase bool) string {
 	dataSourceName := config.User + ":" + config.Password +
 		"@tcp(" + config.Host + ":" + fmt.Sprint(config.Port) + ")/"
@@ -0,0 +1,195 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+// Package events lets the pool tell the web server, metrics and notifications what
+// happened as soon as it happened instead of having them poll the cache.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is something that happened in the pool
+type Event interface {
+	// Type identifies the kind of the event
+	Type() string
+}
+
+// NewBlock is published when mining moves on to the next block
+type NewBlock struct {
+	Height              uint64
+	BaseTarget          uint64
+	Scoop               uint32
+	GenerationSignature string
+	Created             time.Time
+}
+
+// BlockSwitched is published when the current block is replaced by one of another
+// fork at the same height
+type BlockSwitched NewBlock
+
+// NewBestDeadline is published when the pool got a better deadline for the current
+// block
+type NewBestDeadline struct {
+	Height   uint64
+	MinerID  uint64
+	Name     string
+	Address  string
+	Deadline uint64
+}
+
+// SubmissionAccepted is published for every deadline a miner submitted that was
+// accepted
+type SubmissionAccepted struct {
+	Height   uint64
+	MinerID  uint64
+	Deadline uint64
+}
+
+// BlockWon is published when a block was verified to be forged by the pool
+type BlockWon struct {
+	Height   uint64
+	WinnerID uint64
+	Reward   int64
+}
+
+// BlockLost is published when a block the pool submitted a deadline for was forged by
+// someone else
+type BlockLost struct {
+	Height uint64
+}
+
+// PayoutCreated is published when a payout transaction was sent to the wallet
+type PayoutCreated struct {
+	TransactionID uint64
+	Recipients    int
+	Amount        int64
+}
+
+// PayoutConfirmed is published when a payout transaction made it into the chain
+type PayoutConfirmed struct {
+	TransactionID uint64
+	Height        uint64
+}
+
+func (NewBlock) Type() string           { return "NewBlock" }
+func (BlockSwitched) Type() string      { return "BlockSwitched" }
+func (NewBestDeadline) Type() string    { return "NewBestDeadline" }
+func (SubmissionAccepted) Type() string { return "SubmissionAccepted" }
+func (BlockWon) Type() string           { return "BlockWon" }
+func (BlockLost) Type() string          { return "BlockLost" }
+func (PayoutCreated) Type() string      { return "PayoutCreated" }
+func (PayoutConfirmed) Type() string    { return "PayoutConfirmed" }
+
+// Subscription receives the published events on C
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	types   map[string]bool
+	mu      sync.Mutex
+	dropped uint64
+}
+
+// Dropped yields the number of events that were dropped because the subscriber didn't
+// keep up
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Bus hands the published events to all subscribers. Publishing never blocks, events a
+// subscriber has no room for are dropped, so subscribers should keep polling as a
+// safety net.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subscriptions: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber that can hold size events it didn't receive yet, it
+// gets the events of the given types only or all of them if there are none
+func (bus *Bus) Subscribe(size int, types ...Event) *Subscription {
+	c := make(chan Event, size)
+	s := &Subscription{C: c, c: c}
+	if len(types) > 0 {
+		s.types = make(map[string]bool, len(types))
+		for _, event := range types {
+			s.types[event.Type()] = true
+		}
+	}
+	bus.mu.Lock()
+	bus.subscriptions[s] = struct{}{}
+	bus.mu.Unlock()
+	return s
+}
+
+// Unsubscribe stops handing events to s and closes its channel
+func (bus *Bus) Unsubscribe(s *Subscription) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if _, exists := bus.subscriptions[s]; exists {
+		delete(bus.subscriptions, s)
+		close(s.c)
+	}
+}
+
+// Publish hands event to all subscribers
+func (bus *Bus) Publish(event Event) {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	for s := range bus.subscriptions {
+		if s.types != nil && !s.types[event.Type()] {
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			s.mu.Lock()
+			s.dropped++
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Metrics counts the published events by their type
+type Metrics struct {
+	subscription *Subscription
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewMetrics subscribes to bus and counts its events
+func NewMetrics(bus *Bus) *Metrics {
+	metrics := &Metrics{
+		subscription: bus.Subscribe(1024),
+		counts:       make(map[string]uint64)}
+	go metrics.run()
+	return metrics
+}
+
+func (metrics *Metrics) run() {
+	for event := range metrics.subscription.C {
+		metrics.mu.Lock()
+		metrics.counts[event.Type()]++
+		metrics.mu.Unlock()
+	}
+}
+
+// Counts yields how many events of each type were published, dropped ones are counted
+// as "Dropped"
+func (metrics *Metrics) Counts() map[string]uint64 {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	counts := make(map[string]uint64, len(metrics.counts)+1)
+	for name, count := range metrics.counts {
+		counts[name] = count
+	}
+	counts["Dropped"] = metrics.subscription.Dropped()
+	return counts
+}
@@ -0,0 +1,83 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(2)
+	blocks := bus.Subscribe(2, NewBlock{}, BlockSwitched{})
+
+	bus.Publish(NewBlock{Height: 1})
+	bus.Publish(SubmissionAccepted{Height: 1, MinerID: 2, Deadline: 3})
+	bus.Publish(BlockSwitched{Height: 1})
+
+	assert.Equal(t, NewBlock{Height: 1}, <-all.C)
+	assert.Equal(t, SubmissionAccepted{Height: 1, MinerID: 2, Deadline: 3}, <-all.C)
+	assert.Equal(t, uint64(1), all.Dropped(), "publishing to a full subscriber must not block")
+
+	assert.Equal(t, NewBlock{Height: 1}, <-blocks.C)
+	assert.Equal(t, BlockSwitched{Height: 1}, <-blocks.C)
+	assert.Equal(t, uint64(0), blocks.Dropped(), "filtered event counted as dropped")
+
+	bus.Unsubscribe(blocks)
+	bus.Publish(NewBlock{Height: 2})
+	_, open := <-blocks.C
+	assert.False(t, open, "channel of unsubscribed subscriber still open")
+	assert.Equal(t, NewBlock{Height: 2}, <-all.C)
+}
+
+func TestMetrics(t *testing.T) {
+	bus := NewBus()
+	metrics := NewMetrics(bus)
+
+	bus.Publish(NewBlock{Height: 1})
+	bus.Publish(NewBestDeadline{Height: 1, Deadline: 42})
+	bus.Publish(NewBestDeadline{Height: 1, Deadline: 21})
+
+	// the events are counted in the background
+	var counts map[string]uint64
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if counts = metrics.Counts(); counts["NewBlock"]+counts["NewBestDeadline"] == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(1), counts["NewBlock"])
+	assert.Equal(t, uint64(2), counts["NewBestDeadline"])
+	assert.Equal(t, uint64(0), counts["Dropped"])
+}
+
+func TestNotifier(t *testing.T) {
+	posted := make(chan notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var n struct {
+			Type  string
+			Event BlockWon
+		}
+		json.NewDecoder(req.Body).Decode(&n)
+		posted <- notification{Type: n.Type, Event: n.Event}
+	}))
+	defer server.Close()
+
+	bus := NewBus()
+	NewNotifier(bus, server.URL, time.Second)
+	bus.Publish(NewBlock{Height: 1})
+	bus.Publish(BlockWon{Height: 1, WinnerID: 2, Reward: 3})
+
+	select {
+	case n := <-posted:
+		assert.Equal(t, notification{Type: "BlockWon", Event: BlockWon{Height: 1, WinnerID: 2, Reward: 3}}, n)
+	case <-time.After(time.Second):
+		t.Error("won block not posted")
+	}
+}
@@ -0,0 +1,67 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// notification is what the webhook receives
+type notification struct {
+	Type  string `json:"type"`
+	Event Event  `json:"event"`
+}
+
+// Notifier tells the operator about won blocks and sent payouts. They are logged and,
+// if a url is set, posted to it as JSON.
+type Notifier struct {
+	subscription *Subscription
+	url          string
+	client       *http.Client
+}
+
+// NewNotifier subscribes to bus and notifies about its events in the background
+func NewNotifier(bus *Bus, url string, timeout time.Duration) *Notifier {
+	notifier := &Notifier{
+		subscription: bus.Subscribe(64, BlockWon{}, PayoutCreated{}),
+		url:          url,
+		client:       &http.Client{Timeout: timeout}}
+	go notifier.run()
+	return notifier
+}
+
+func (notifier *Notifier) run() {
+	for event := range notifier.subscription.C {
+		Logger.Info("notification", zap.String("type", event.Type()), zap.Any("event", event))
+		if notifier.url == "" {
+			continue
+		}
+		if err := notifier.post(event); err != nil {
+			Logger.Error("posting notification failed", zap.String("type", event.Type()), zap.Error(err))
+		}
+	}
+}
+
+func (notifier *Notifier) post(event Event) error {
+	body, err := json.Marshal(notification{Type: event.Type(), Event: event})
+	if err != nil {
+		return err
+	}
+	res, err := notifier.client.Post(notifier.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package mocks
 import mock "github.com/stretchr/testify/mock"
 import time "time"
 import "github.com/PoC-Consortium/Nogrod/pkg/wallet"
+import "github.com/PoC-Consortium/Nogrod/pkg/wallethandler"
 
 // WalletHandler is an autogenerated mock type for the WalletHandler type
 type WalletHandler struct {
@@ -77,6 +78,27 @@ func (_m *WalletHandler) GetBlockInfo(_a0 uint64) (*wallet.GetBlockReply, error)
 	return r0, r1
 }
 
+// GetClockOffset provides a mock function with given fields:
+func (_m *WalletHandler) GetClockOffset() (time.Duration, error) {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetGenerationTime provides a mock function with given fields: height
 func (_m *WalletHandler) GetGenerationTime(height uint64) (int32, error) {
 	ret := _m.Called(height)
@@ -144,6 +166,27 @@ func (_m *WalletHandler) GetMiningInfo() (*wallet.GetMiningInfoReply, error) {
 	return r0, r1
 }
 
+// GetRewardRecipient provides a mock function with given fields: _a0
+func (_m *WalletHandler) GetRewardRecipient(_a0 uint64) (uint64, error) {
+	ret := _m.Called(_a0)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(uint64) uint64); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uint64) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRewardRecipients provides a mock function with given fields:
 func (_m *WalletHandler) GetRewardRecipients() (map[uint64]bool, error) {
 	ret := _m.Called()
@@ -197,6 +240,27 @@ func (_m *WalletHandler) GetTransaction(_a0 uint64) (*wallet.GetTransactionReply
 	return r0, r1, r2
 }
 
+// SendMessage provides a mock function with given fields: _a0, _a1
+func (_m *WalletHandler) SendMessage(_a0 uint64, _a1 string) (uint64, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(uint64, string) uint64); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uint64, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendPayment provides a mock function with given fields: _a0, _a1
 func (_m *WalletHandler) SendPayment(_a0 uint64, _a1 int64) (uint64, error) {
 	ret := _m.Called(_a0, _a1)
@@ -239,18 +303,27 @@ func (_m *WalletHandler) SendPayments(_a0 map[uint64]int64) (uint64, error) {
 	return r0, r1
 }
 
-// SubmitNonce provides a mock function with given fields: _a0, _a1, _a2
-func (_m *WalletHandler) SubmitNonce(_a0 uint64, _a1 uint64, _a2 uint64) error {
-	ret := _m.Called(_a0, _a1, _a2)
+// SubmitNonce provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *WalletHandler) SubmitNonce(_a0 uint64, _a1 uint64, _a2 uint64, _a3 uint64) ([]wallethandler.SubmitNonceResult, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(uint64, uint64, uint64) error); ok {
-		r0 = rf(_a0, _a1, _a2)
+	var r0 []wallethandler.SubmitNonceResult
+	if rf, ok := ret.Get(0).(func(uint64, uint64, uint64, uint64) []wallethandler.SubmitNonceResult); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]wallethandler.SubmitNonceResult)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uint64, uint64, uint64, uint64) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // WonBlock provides a mock function with given fields: _a0, _a1, _a2
@@ -0,0 +1,206 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+// abandonedAccount is an account without any nonce submissions left in the db
+type abandonedAccount struct {
+	ID       uint64
+	Pending  int64
+	Notified sql.NullTime `db:"abandoned_notified"`
+}
+
+type abandonedAction int
+
+const (
+	abandonedWait abandonedAction = iota
+	abandonedPayout
+	abandonedNotify
+	abandonedSweep
+)
+
+// action decides what happens to the balance of an abandoned account: balances worth
+// a payout get paid out, the rest is swept into the fee account after the miner was
+// notified, or right away if the notice costs more than the balance
+func (account *abandonedAccount) action(now time.Time) abandonedAction {
+	switch {
+	case account.Pending >= Cfg.AbandonedPayoutMin:
+		return abandonedPayout
+	case account.Pending <= Cfg.PoolTxFee:
+		return abandonedSweep
+	case !account.Notified.Valid:
+		return abandonedNotify
+	case now.Sub(account.Notified.Time) >= Cfg.AbandonedNoticeDur:
+		return abandonedSweep
+	}
+	return abandonedWait
+}
+
+func (modelx *Modelx) handleAbandonedBalances() {
+	// miners that came back need to be notified again if they leave another time
+	_, err := modelx.db.Exec(`UPDATE account SET abandoned_notified = NULL
+                                    WHERE abandoned_notified IS NOT NULL AND id IN (SELECT id FROM miner)`)
+	if err != nil {
+		Logger.Error("resetting abandoned notices failed", zap.Error(err))
+		return
+	}
+
+	var accounts []abandonedAccount
+	err = modelx.db.Select(&accounts, `SELECT id, pending, abandoned_notified FROM account
+                                             WHERE id != ? AND id NOT IN (SELECT id FROM miner)`, Cfg.FeeAccountID)
+	if err != nil {
+		Logger.Error("fetching abandoned accounts failed", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for i := range accounts {
+		account := &accounts[i]
+		switch account.action(now) {
+		case abandonedPayout:
+			err = modelx.forcePayout(account)
+		case abandonedNotify:
+			err = modelx.notifyAbandoned(account)
+		case abandonedSweep:
+			err = modelx.sweepAbandoned(account)
+		default:
+			continue
+		}
+		if err != nil {
+			Logger.Error("handling abandoned balance failed", zap.Uint64("accountID", account.ID),
+				zap.Int64("pending", account.Pending), zap.Error(err))
+		}
+	}
+}
+
+// forcePayout pays the balance out with the next payout, the account gets swept
+// once its balance is gone. The payout clears the date of accounts without interval.
+func (modelx *Modelx) forcePayout(account *abandonedAccount) error {
+	_, err := modelx.db.Exec("UPDATE account SET next_payout_date = ? WHERE id = ?", time.Now(), account.ID)
+	if err == nil {
+		Logger.Info("final payout of abandoned balance", zap.Uint64("accountID", account.ID),
+			zap.Int64("pending", account.Pending))
+	}
+	return err
+}
+
+// notifyAbandoned tells the miner on chain that its balance is going to be swept, the
+// fee of the message is taken from the balance
+func (modelx *Modelx) notifyAbandoned(account *abandonedAccount) error {
+	sweepDate := time.Now().Add(Cfg.AbandonedNoticeDur)
+	msg := fmt.Sprintf("You haven't mined in the pool for a while. Your pending balance of %.8f Burst "+
+		"will be moved to the pool's fee account after %s unless you mine again. It can still be restored "+
+		"for %d days after that by mining in the pool.", float64(account.Pending-Cfg.PoolTxFee)/1e8,
+		sweepDate.UTC().Format("2006-01-02"), Cfg.AbandonedRestoreDays)
+
+	_, err := modelx.walletHandler.SendMessage(account.ID, msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = modelx.db.Exec("UPDATE account SET abandoned_notified = ?, pending = pending - ? WHERE id = ?",
+		time.Now(), Cfg.PoolTxFee, account.ID)
+	if err == nil {
+		Logger.Info("notified abandoned account", zap.Uint64("accountID", account.ID),
+			zap.Int64("pending", account.Pending))
+	}
+	return err
+}
+
+// sweepAbandoned moves the balance into the fee account and keeps a record so that
+// it can be restored if the miner comes back
+func (modelx *Modelx) sweepAbandoned(account *abandonedAccount) error {
+	tx, err := modelx.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var pending int64
+	err = tx.Get(&pending, "SELECT pending FROM account WHERE id = ?"+modelx.db.ForUpdate(), account.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// the miner might have come back in the meantime
+	res, err := tx.Exec("DELETE FROM account WHERE id = ? AND id NOT IN (SELECT id FROM miner)", account.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil || deleted == 0 {
+		tx.Rollback()
+		return err
+	}
+
+	if pending != 0 {
+		_, err = tx.Exec("UPDATE account SET pending = pending + ? WHERE id = ?", pending, Cfg.FeeAccountID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO swept_balance (account_id, amount) VALUES (?, ?)", account.ID, pending)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	Logger.Info("swept abandoned balance", zap.Uint64("accountID", account.ID), zap.Int64("amount", pending))
+	return nil
+}
+
+// restoreSweptBalance gives a returning miner back what was swept within the grace period,
+// as far as the fee account's balance still covers it
+func (modelx *Modelx) restoreSweptBalance(tx storage.Tx, accountID uint64) error {
+	since := time.Now().Add(-Cfg.AbandonedRestoreDur)
+
+	var swept int64
+	err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM swept_balance
+                              WHERE account_id = ? AND restored IS NULL AND swept > ?`, accountID, since).Scan(&swept)
+	if err != nil || swept == 0 {
+		return err
+	}
+
+	// the fees might have been paid out already
+	var feePending int64
+	err = tx.Get(&feePending, "SELECT pending FROM account WHERE id = ?"+modelx.db.ForUpdate(), Cfg.FeeAccountID)
+	if err != nil {
+		return err
+	}
+	if feePending < swept {
+		Logger.Error("fee account can't cover swept balance, restoring less", zap.Uint64("accountID", accountID),
+			zap.Int64("swept", swept), zap.Int64("feePending", feePending))
+		if feePending < 0 {
+			feePending = 0
+		}
+		swept = feePending
+	}
+
+	if _, err := tx.Exec("UPDATE account SET pending = pending + ? WHERE id = ?", swept, accountID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE account SET pending = pending - ? WHERE id = ?", swept, Cfg.FeeAccountID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE swept_balance SET restored = ?
+                            WHERE account_id = ? AND restored IS NULL AND swept > ?`, time.Now(), accountID, since)
+	if err == nil {
+		Logger.Info("restored swept balance", zap.Uint64("accountID", accountID), zap.Int64("amount", swept))
+	}
+	return err
+}
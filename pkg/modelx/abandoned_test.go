@@ -0,0 +1,120 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAbandonedAction(t *testing.T) {
+	payoutMin, txFee, notice := Cfg.AbandonedPayoutMin, Cfg.PoolTxFee, Cfg.AbandonedNoticeDur
+	defer func() {
+		Cfg.AbandonedPayoutMin, Cfg.PoolTxFee, Cfg.AbandonedNoticeDur = payoutMin, txFee, notice
+	}()
+	Cfg.AbandonedPayoutMin = 10000
+	Cfg.PoolTxFee = 100
+	Cfg.AbandonedNoticeDur = 24 * time.Hour
+
+	now := time.Now()
+	notified := sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	noticeOver := sql.NullTime{Time: now.Add(-25 * time.Hour), Valid: true}
+
+	assert.Equal(t, abandonedPayout, (&abandonedAccount{Pending: 10000}).action(now))
+	assert.Equal(t, abandonedPayout, (&abandonedAccount{Pending: 20000, Notified: notified}).action(now))
+	assert.Equal(t, abandonedSweep, (&abandonedAccount{Pending: 100}).action(now), "notice costs the balance")
+	assert.Equal(t, abandonedSweep, (&abandonedAccount{Pending: 0}).action(now))
+	assert.Equal(t, abandonedNotify, (&abandonedAccount{Pending: 5000}).action(now))
+	assert.Equal(t, abandonedWait, (&abandonedAccount{Pending: 5000, Notified: notified}).action(now))
+	assert.Equal(t, abandonedSweep, (&abandonedAccount{Pending: 5000, Notified: noticeOver}).action(now))
+}
+
+func TestHandleAbandonedAccount(t *testing.T) {
+	payoutID, notifyID, sweepID := uint64(9000000000000000201), uint64(9000000000000000202),
+		uint64(9000000000000000203)
+	var feePending int64
+	if !assert.Nil(t, modelx.db.Get(&feePending, "SELECT pending FROM account WHERE id = ?", Cfg.FeeAccountID)) {
+		return
+	}
+	defer modelx.db.MustExec("UPDATE account SET pending = ? WHERE id = ?", feePending, Cfg.FeeAccountID)
+	defer modelx.db.MustExec("DELETE FROM swept_balance WHERE account_id = ?", sweepID)
+	for _, accountID := range []uint64{payoutID, notifyID, sweepID} {
+		modelx.db.MustExec("INSERT INTO account (id, address, pending) VALUES (?, ?, 5000)", accountID, accountID)
+		defer modelx.db.MustExec("DELETE FROM account WHERE id = ?", accountID)
+	}
+
+	walletHandlerMock.On("SendMessage", notifyID, mock.Anything).Return(uint64(1), nil)
+
+	// a forced payout is due right away and clears the date once it's paid
+	if assert.Nil(t, modelx.forcePayout(&abandonedAccount{ID: payoutID, Pending: 5000})) {
+		var nextPayoutDate *time.Time
+		modelx.db.Get(&nextPayoutDate, "SELECT next_payout_date FROM account WHERE id = ?", payoutID)
+		assert.NotNil(t, nextPayoutDate, "forced payout not due")
+	}
+
+	// balances worth a notice are kept until the notice period is over
+	if assert.Nil(t, modelx.notifyAbandoned(&abandonedAccount{ID: notifyID, Pending: 5000})) {
+		var notified abandonedAccount
+		modelx.db.Get(&notified, "SELECT id, pending, abandoned_notified FROM account WHERE id = ?", notifyID)
+		assert.Equal(t, 5000-Cfg.PoolTxFee, notified.Pending, "didn't take the notice fee")
+		assert.True(t, notified.Notified.Valid, "abandoned account didn't get notified")
+	}
+
+	// swept balances go to the fee account and can be restored
+	if assert.Nil(t, modelx.sweepAbandoned(&abandonedAccount{ID: sweepID, Pending: 5000})) {
+		var exists bool
+		modelx.db.Get(&exists, "SELECT COUNT(*) > 0 FROM account WHERE id = ?", sweepID)
+		assert.False(t, exists, "swept account not deleted")
+		var fee, swept int64
+		modelx.db.Get(&fee, "SELECT pending FROM account WHERE id = ?", Cfg.FeeAccountID)
+		assert.Equal(t, feePending+5000, fee, "balance not swept into the fee account")
+		modelx.db.Get(&swept, "SELECT amount FROM swept_balance WHERE account_id = ?", sweepID)
+		assert.Equal(t, int64(5000), swept, "swept balance not recorded")
+	}
+}
+
+func TestRestoreSweptBalance(t *testing.T) {
+	accountID := uint64(9000000000000000100)
+	defer modelx.db.MustExec("DELETE FROM swept_balance WHERE account_id = ?", accountID)
+	defer modelx.db.MustExec("DELETE FROM account WHERE id = ?", accountID)
+	var feePending int64
+	if !assert.Nil(t, modelx.db.Get(&feePending, "SELECT pending FROM account WHERE id = ?", Cfg.FeeAccountID)) {
+		return
+	}
+	defer modelx.db.MustExec("UPDATE account SET pending = ? WHERE id = ?", feePending, Cfg.FeeAccountID)
+
+	restore := func(swept, fee int64) (int64, int64) {
+		modelx.db.MustExec("DELETE FROM swept_balance WHERE account_id = ?", accountID)
+		modelx.db.MustExec("DELETE FROM account WHERE id = ?", accountID)
+		modelx.db.MustExec("INSERT INTO account (id, address, pending) VALUES (?, 'X', 0)", accountID)
+		modelx.db.MustExec("INSERT INTO swept_balance (account_id, amount, swept) VALUES (?, ?, ?)",
+			accountID, swept, time.Now())
+		modelx.db.MustExec("UPDATE account SET pending = ? WHERE id = ?", fee, Cfg.FeeAccountID)
+
+		tx, err := modelx.db.Begin()
+		if !assert.Nil(t, err) {
+			return 0, 0
+		}
+		assert.Nil(t, modelx.restoreSweptBalance(tx, accountID))
+		assert.Nil(t, tx.Commit())
+
+		var pending int64
+		modelx.db.Get(&pending, "SELECT pending FROM account WHERE id = ?", accountID)
+		modelx.db.Get(&fee, "SELECT pending FROM account WHERE id = ?", Cfg.FeeAccountID)
+		return pending, fee
+	}
+
+	pending, fee := restore(5000, 8000)
+	assert.Equal(t, int64(5000), pending)
+	assert.Equal(t, int64(3000), fee)
+
+	pending, fee = restore(5000, 2000)
+	assert.Equal(t, int64(2000), pending, "restored more than the fee account holds")
+	assert.Equal(t, int64(0), fee)
+}
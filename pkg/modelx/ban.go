@@ -0,0 +1,232 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	BanAccount   = "account"
+	BanIP        = "ip"
+	BanCIDR      = "cidr"
+	BanUserAgent = "user_agent"
+)
+
+// ErrBanNotFound is returned when lifting a ban that doesn't exist
+var ErrBanNotFound = errors.New("ban not found")
+
+type Ban struct {
+	ID      uint64
+	Type    string
+	Value   string
+	Reason  string
+	Expires sql.NullTime
+	Created time.Time
+}
+
+// Expired checks if a temporary ban is over
+func (ban *Ban) Expired(now time.Time) bool {
+	return ban.Expires.Valid && !ban.Expires.Time.After(now)
+}
+
+// Error yields the message sent to banned clients
+func (ban *Ban) Error() string {
+	msg := "banned"
+	if ban.Reason != "" {
+		msg += ": " + ban.Reason
+	}
+	if ban.Expires.Valid {
+		msg += fmt.Sprintf(" (until %s)", ban.Expires.Time.UTC().Format(time.RFC3339))
+	}
+	return msg
+}
+
+// bans are the parsed rows of the ban table, ready for lookups
+type bans struct {
+	accounts   map[uint64]*Ban
+	ips        map[string]*Ban
+	nets       []*net.IPNet
+	netBans    []*Ban
+	userAgents []*regexp.Regexp
+	uaBans     []*Ban
+}
+
+func newBans(rows []*Ban) *bans {
+	bs := &bans{
+		accounts: make(map[uint64]*Ban),
+		ips:      make(map[string]*Ban)}
+
+	// the static blacklist of the config never expires
+	for id := range Cfg.AccountIDBlacklist {
+		bs.accounts[id] = &Ban{Type: BanAccount, Value: strconv.FormatUint(id, 10), Reason: "blacklisted"}
+	}
+
+	for _, ban := range rows {
+		switch ban.Type {
+		case BanAccount:
+			id, err := strconv.ParseUint(ban.Value, 10, 64)
+			if err != nil {
+				Logger.Error("invalid account ban", zap.Uint64("id", ban.ID), zap.Error(err))
+				continue
+			}
+			bs.accounts[id] = ban
+		case BanIP:
+			ip := net.ParseIP(ban.Value)
+			if ip == nil {
+				Logger.Error("invalid ip ban", zap.Uint64("id", ban.ID), zap.String("ip", ban.Value))
+				continue
+			}
+			bs.ips[ip.String()] = ban
+		case BanCIDR:
+			_, n, err := net.ParseCIDR(ban.Value)
+			if err != nil {
+				Logger.Error("invalid cidr ban", zap.Uint64("id", ban.ID), zap.Error(err))
+				continue
+			}
+			bs.nets = append(bs.nets, n)
+			bs.netBans = append(bs.netBans, ban)
+		case BanUserAgent:
+			re, err := regexp.Compile(ban.Value)
+			if err != nil {
+				Logger.Error("invalid user agent ban", zap.Uint64("id", ban.ID), zap.Error(err))
+				continue
+			}
+			bs.userAgents = append(bs.userAgents, re)
+			bs.uaBans = append(bs.uaBans, ban)
+		}
+	}
+	return bs
+}
+
+func active(ban *Ban) *Ban {
+	if ban == nil || ban.Expired(time.Now()) {
+		return nil
+	}
+	return ban
+}
+
+func (bs *bans) account(accountID uint64) *Ban {
+	return active(bs.accounts[accountID])
+}
+
+func (bs *bans) ip(ipStr string) *Ban {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil
+	}
+	if ban := active(bs.ips[ip.String()]); ban != nil {
+		return ban
+	}
+	for i, n := range bs.nets {
+		if ban := active(bs.netBans[i]); ban != nil && n.Contains(ip) {
+			return ban
+		}
+	}
+	return nil
+}
+
+func (bs *bans) userAgent(ua string) *Ban {
+	if ua == "" {
+		return nil
+	}
+	for i, re := range bs.userAgents {
+		if ban := active(bs.uaBans[i]); ban != nil && re.MatchString(ua) {
+			return ban
+		}
+	}
+	return nil
+}
+
+// LoadBans reads all active bans into the cache
+func (modelx *Modelx) LoadBans() error {
+	var rows []*Ban
+	err := modelx.db.Select(&rows, `SELECT id, type, value, reason, expires, created FROM ban
+                                         WHERE expires IS NULL OR expires > ?`, time.Now())
+	if err != nil {
+		return err
+	}
+	Cache.StoreBans(newBans(rows))
+	return nil
+}
+
+// GetBans yields all active bans
+func (modelx *Modelx) GetBans() ([]*Ban, error) {
+	var rows []*Ban
+	err := modelx.db.Select(&rows, `SELECT id, type, value, reason, expires, created FROM ban
+                                         WHERE expires IS NULL OR expires > ? ORDER BY id`, time.Now())
+	return rows, err
+}
+
+// checkBan validates the value of a ban of a type
+func checkBan(banType, value string) error {
+	var err error
+	switch banType {
+	case BanAccount:
+		_, err = strconv.ParseUint(value, 10, 64)
+	case BanIP:
+		if net.ParseIP(value) == nil {
+			err = fmt.Errorf("invalid ip %q", value)
+		}
+	case BanCIDR:
+		_, _, err = net.ParseCIDR(value)
+	case BanUserAgent:
+		_, err = regexp.Compile(value)
+	default:
+		err = fmt.Errorf("unknown ban type %q", banType)
+	}
+	return err
+}
+
+// AddBan stores a ban and applies it right away, a zero duration bans forever
+func (modelx *Modelx) AddBan(banType, value, reason string, duration time.Duration) error {
+	if err := modelx.storeBan(banType, value, reason, duration); err != nil {
+		return err
+	}
+	return modelx.LoadBans()
+}
+
+// storeBan stores a ban, it applies once the bans are loaded again
+func (modelx *Modelx) storeBan(banType, value, reason string, duration time.Duration) error {
+	if err := checkBan(banType, value); err != nil {
+		return err
+	}
+	var expires sql.NullTime
+	if duration > 0 {
+		expires = sql.NullTime{Time: time.Now().Add(duration), Valid: true}
+	}
+	_, err := modelx.db.Exec("INSERT INTO ban (type, value, reason, expires) VALUES (?, ?, ?, ?)",
+		banType, value, reason, expires)
+	if err != nil {
+		return err
+	}
+	Logger.Info("banned", zap.String("type", banType), zap.String("value", value),
+		zap.String("reason", reason), zap.Duration("duration", duration))
+	return nil
+}
+
+// RemoveBan lifts a ban
+func (modelx *Modelx) RemoveBan(id uint64) error {
+	res, err := modelx.db.Exec("DELETE FROM ban WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrBanNotFound
+	}
+	Logger.Info("lifted ban", zap.Uint64("id", id))
+	return modelx.LoadBans()
+}
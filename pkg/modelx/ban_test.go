@@ -0,0 +1,59 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBans(t *testing.T) {
+	expired := sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+	bs := newBans([]*Ban{
+		{ID: 1, Type: BanAccount, Value: "1337", Reason: "cheating"},
+		{ID: 2, Type: BanAccount, Value: "42", Expires: expired},
+		{ID: 3, Type: BanIP, Value: "1.2.3.4"},
+		{ID: 4, Type: BanCIDR, Value: "10.0.0.0/8"},
+		{ID: 5, Type: BanUserAgent, Value: "^evil-miner"},
+		{ID: 6, Type: BanCIDR, Value: "no cidr"}})
+
+	if ban := bs.account(1337); assert.NotNil(t, ban) {
+		assert.Equal(t, "banned: cheating", ban.Error())
+	}
+	assert.Nil(t, bs.account(42), "expired ban applied")
+	assert.Nil(t, bs.account(43))
+
+	assert.NotNil(t, bs.ip("1.2.3.4"))
+	assert.NotNil(t, bs.ip("10.20.30.40"), "cidr ban not applied")
+	assert.Nil(t, bs.ip("11.0.0.1"))
+	assert.Nil(t, bs.ip("invalid"))
+
+	assert.NotNil(t, bs.userAgent("evil-miner 1.0"))
+	assert.Nil(t, bs.userAgent("scavenger 1.7.8"))
+	assert.Nil(t, bs.userAgent(""))
+}
+
+func TestAddBan(t *testing.T) {
+	defer modelx.db.MustExec("DELETE FROM ban")
+
+	assert.NotNil(t, modelx.AddBan(BanCIDR, "no cidr", "", 0), "invalid ban stored")
+	assert.NotNil(t, modelx.AddBan("planet", "earth", "", 0), "unknown ban type stored")
+
+	if !assert.Nil(t, modelx.AddBan(BanIP, "192.0.2.1", "spam", time.Hour)) {
+		return
+	}
+	assert.NotNil(t, Cache.BannedClient("192.0.2.1", ""), "ban not applied")
+
+	bans, err := modelx.GetBans()
+	if assert.Nil(t, err) && assert.Len(t, bans, 1) {
+		assert.Equal(t, "spam", bans[0].Reason)
+		assert.True(t, bans[0].Expires.Valid)
+
+		assert.Nil(t, modelx.RemoveBan(bans[0].ID))
+		assert.Nil(t, Cache.BannedClient("192.0.2.1", ""), "lifted ban still applied")
+		assert.Equal(t, ErrBanNotFound, modelx.RemoveBan(bans[0].ID), "unknown ban lifted")
+	}
+}
@@ -21,9 +21,13 @@ type cache struct {
 	currentBlock        atomic.Value
 	poolCap             atomic.Value // gb
 	minerCount          int32
+	deadlineLimit       uint64
+	clockOffset         int64 // local clock minus wallet clock in ns
 
-	rewardRecipient   map[uint64]bool
+	rewardRecipient   map[uint64]RewardRecipient
 	rewardRecipientMu sync.RWMutex
+	// accounts added by lookups since the last refresh
+	lookedUp int
 
 	slowBlocks *blocks
 	fastBlocks *blocks
@@ -32,6 +36,14 @@ type cache struct {
 
 	miningInfoJSON atomic.Value
 	roundInfo      atomic.Value
+	prevRound      atomic.Value
+	bans           atomic.Value
+	offenders      *offenders
+}
+
+type prevRound struct {
+	RoundInfo
+	ended time.Time
 }
 
 type blocks struct {
@@ -41,6 +53,36 @@ type blocks struct {
 	sync.RWMutex
 }
 
+// unknownHeight marks reward recipient assignments of which the pool doesn't know
+// when they take effect
+const unknownHeight = ^uint64(0)
+
+// RewardRecipient is what the pool knows about the latest reward recipient
+// assignment of an account
+type RewardRecipient struct {
+	Assigned   bool   // the assignment names the pool
+	PrevPool   bool   // the pool was the recipient before the assignment
+	FromHeight uint64 // height the assignment takes effect at
+
+	// result of a wallet lookup, only valid for the height it was done at
+	CheckedAt   uint64
+	CheckedPool bool
+}
+
+// IsPool checks if the pool receives the rewards of the account at height, known is
+// false if that can only be answered by asking the wallet
+func (rr *RewardRecipient) IsPool(height uint64) (isPool bool, known bool) {
+	switch {
+	case rr.FromHeight != unknownHeight && height >= rr.FromHeight:
+		return rr.Assigned, true
+	case rr.FromHeight != unknownHeight:
+		return rr.PrevPool, true
+	case rr.CheckedAt == height:
+		return rr.CheckedPool, true
+	}
+	return false, false
+}
+
 type RoundInfo struct {
 	Scoop               uint32
 	BaseTarget          uint64
@@ -55,7 +97,10 @@ func InitCache() {
 	c.StoreBestNonceSubmission(NonceSubmission{})
 	c.StoreCurrentBlock(Block{})
 	c.StorePoolCap(0.0)
-	c.rewardRecipient = make(map[uint64]bool)
+	c.StoreDeadlineLimit(Cfg.DeadlineLimit)
+	c.StoreBans(newBans(nil))
+	c.offenders = newOffenders()
+	c.rewardRecipient = make(map[uint64]RewardRecipient)
 	c.computeAlphas(Cfg.NAVG, Cfg.NMin)
 	c.slowBlocks = newBlocks(Cfg.NAVG)
 	c.fastBlocks = newBlocks(Cfg.NAVG)
@@ -102,6 +147,17 @@ func (blocks *blocks) add(height uint64) uint64 {
 	return 0
 }
 
+// list yields the heights in ascending order
+func (blocks *blocks) list() []uint64 {
+	blocks.RLock()
+	defer blocks.RUnlock()
+	heights := make([]uint64, 0, blocks.heights.Len())
+	for e := blocks.heights.Front(); e != nil; e = e.Next() {
+		heights = append(heights, e.Value.(uint64))
+	}
+	return heights
+}
+
 func (blocks *blocks) exists(height uint64) bool {
 	blocks.RLock()
 	_, exists := blocks.index[height]
@@ -117,6 +173,56 @@ func (c *cache) GetPoolCap() float64 {
 	return c.poolCap.Load().(float64)
 }
 
+func (c *cache) StoreBans(bs *bans) {
+	c.bans.Store(bs)
+}
+
+// BannedAccount yields the active ban of an account or nil
+func (c *cache) BannedAccount(accountID uint64) *Ban {
+	return c.bans.Load().(*bans).account(accountID)
+}
+
+// BannedClient yields the active ban matching the ip or user agent of a client or nil
+func (c *cache) BannedClient(ip, userAgent string) *Ban {
+	bs := c.bans.Load().(*bans)
+	if ban := bs.ip(ip); ban != nil {
+		return ban
+	}
+	return bs.userAgent(userAgent)
+}
+
+// Offenders yields the accounts and ips whose submissions got rejected recently
+func (c *cache) Offenders() []Offender {
+	return c.offenders.list()
+}
+
+// Offender yields the rejections of an account or ip
+func (c *cache) Offender(banType, value string) (Offender, bool) {
+	return c.offenders.get(banType, value)
+}
+
+// ForgetOffenders drops offenders without rejections since before
+func (c *cache) ForgetOffenders(before time.Time) {
+	c.offenders.forget(before)
+}
+
+func (c *cache) StoreDeadlineLimit(deadlineLimit uint64) {
+	atomic.StoreUint64(&c.deadlineLimit, deadlineLimit)
+}
+
+// DeadlineLimit yields the pool wide deadline limit of the current round
+func (c *cache) DeadlineLimit() uint64 {
+	return atomic.LoadUint64(&c.deadlineLimit)
+}
+
+func (c *cache) StoreClockOffset(offset time.Duration) {
+	atomic.StoreInt64(&c.clockOffset, int64(offset))
+}
+
+func (c *cache) ClockOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.clockOffset))
+}
+
 func (c *cache) StoreMinerCount(i int32) {
 	atomic.StoreInt32(&c.minerCount, i)
 }
@@ -126,6 +232,9 @@ func (c *cache) GetMinerCount() int32 {
 }
 
 func (c *cache) StoreRoundInfo(b Block) {
+	if ri, ok := c.roundInfo.Load().(RoundInfo); ok && ri.Height < b.Height {
+		c.prevRound.Store(prevRound{RoundInfo: ri, ended: time.Now()})
+	}
 	c.currentBlock.Store(b)
 	c.roundInfo.Store(RoundInfo{
 		Scoop:               b.Scoop,
@@ -136,13 +245,64 @@ func (c *cache) StoreRoundInfo(b Block) {
 		RoundStart:          b.Created})
 }
 
-func (c *cache) StoreMiningInfo(b *Block) {
+func miningInfoJSON(baseTarget uint64, genSig string, height, targetDeadline uint64) []byte {
 	miningInfoBytes, _ := json.Marshal(map[string]interface{}{
-		"baseTarget":          b.BaseTarget,
-		"generationSignature": b.GenerationSignature,
-		"height":              b.Height,
-		"targetDeadline":      Cfg.DeadlineLimit})
-	c.miningInfoJSON.Store(miningInfoBytes)
+		"baseTarget":          baseTarget,
+		"generationSignature": genSig,
+		"height":              height,
+		"targetDeadline":      targetDeadline})
+	return miningInfoBytes
+}
+
+func (c *cache) StoreMiningInfo(b *Block) {
+	c.miningInfoJSON.Store(miningInfoJSON(b.BaseTarget, b.GenerationSignature, b.Height, c.DeadlineLimit()))
+}
+
+// TargetDeadline yields the deadline limit of an account in the round at height, which
+// is the pool's limit or a lower one estimated from the account's EEPS. It's remembered
+// as served to the account's miner.
+func (c *cache) TargetDeadline(accountID, baseTarget, height uint64) uint64 {
+	deadlineLimit := c.DeadlineLimit()
+	miner := c.GetMiner(accountID)
+	if miner == nil {
+		return deadlineLimit
+	}
+	miner.Lock()
+	defer miner.Unlock()
+	targetDeadline := miner.TargetDeadline(baseTarget)
+	if targetDeadline == 0 || (deadlineLimit != 0 && targetDeadline > deadlineLimit) {
+		targetDeadline = deadlineLimit
+	}
+	miner.targetHeight = height
+	miner.targetDeadline = targetDeadline
+	return targetDeadline
+}
+
+// SubmitDeadlineLimit yields the deadline limit submissions of an account in the round
+// at height are checked against. A lower target deadline only applies if it was served
+// to the account's miner, which otherwise didn't know about it.
+func (c *cache) SubmitDeadlineLimit(accountID, height uint64) uint64 {
+	deadlineLimit := c.DeadlineLimit()
+	miner := c.GetMiner(accountID)
+	if miner == nil {
+		return deadlineLimit
+	}
+	miner.Lock()
+	defer miner.Unlock()
+	if miner.targetHeight != height || miner.targetDeadline == 0 ||
+		(deadlineLimit != 0 && miner.targetDeadline > deadlineLimit) {
+		return deadlineLimit
+	}
+	return miner.targetDeadline
+}
+
+// GetMiningInfoJSONWith yields the mining info with a different target deadline
+func (c *cache) GetMiningInfoJSONWith(targetDeadline uint64) []byte {
+	if targetDeadline == c.DeadlineLimit() {
+		return c.GetMiningInfoJSON()
+	}
+	ri := c.GetRoundInfo()
+	return miningInfoJSON(ri.BaseTarget, ri.GenerationSignature, ri.Height, targetDeadline)
 }
 
 func (c *cache) StoreCurrentBlock(b Block) {
@@ -158,6 +318,16 @@ func (c *cache) GetRoundInfo() RoundInfo {
 	return c.roundInfo.Load().(RoundInfo)
 }
 
+// GetPrevRoundInfo returns the round info of the previous height if the round
+// ended no longer than grace ago
+func (c *cache) GetPrevRoundInfo(grace time.Duration) (RoundInfo, bool) {
+	pr, ok := c.prevRound.Load().(prevRound)
+	if !ok || time.Since(pr.ended) > grace {
+		return RoundInfo{}, false
+	}
+	return pr.RoundInfo, true
+}
+
 func (c *cache) CurrentBlock() Block {
 	return c.currentBlock.Load().(Block)
 }
@@ -183,27 +353,67 @@ func (c *cache) DeleteMiner(id uint64) {
 	c.miners.Delete(id)
 }
 
-func (c *cache) IsRewardRecipient(id uint64) (bool, bool) {
+// IsRewardRecipient checks if the pool receives the rewards of an account at height,
+// the second return value is false if the account needs to be looked up
+func (c *cache) IsRewardRecipient(id, height uint64) (bool, bool) {
 	c.rewardRecipientMu.RLock()
 	defer c.rewardRecipientMu.RUnlock()
 
-	isCorrect, ok := c.rewardRecipient[id]
+	rr, ok := c.rewardRecipient[id]
 	if !ok {
 		return false, false
 	}
-	return isCorrect, true
+	return rr.IsPool(height)
 }
 
-func (c *cache) StoreRewardRecipient(id uint64, isCorrect bool) {
+// GetRewardRecipient yields what is known about the assignment of an account
+func (c *cache) GetRewardRecipient(id uint64) (RewardRecipient, bool) {
+	c.rewardRecipientMu.RLock()
+	defer c.rewardRecipientMu.RUnlock()
+
+	rr, ok := c.rewardRecipient[id]
+	if !ok {
+		return RewardRecipient{FromHeight: unknownHeight}, false
+	}
+	return rr, true
+}
+
+// RewardRecipients yields a copy of all known reward recipient assignments
+func (c *cache) RewardRecipients() map[uint64]RewardRecipient {
+	c.rewardRecipientMu.RLock()
+	defer c.rewardRecipientMu.RUnlock()
+	rewardRecipients := make(map[uint64]RewardRecipient, len(c.rewardRecipient))
+	for id, rr := range c.rewardRecipient {
+		rewardRecipients[id] = rr
+	}
+	return rewardRecipients
+}
+
+func (c *cache) StoreRewardRecipient(id uint64, rr RewardRecipient) {
 	c.rewardRecipientMu.Lock()
 	defer c.rewardRecipientMu.Unlock()
-	c.rewardRecipient[id] = isCorrect
+	c.rewardRecipient[id] = rr
+}
+
+// StoreLookedUpRewardRecipient caches the result of a lookup, accounts beyond
+// maxLookedUpRewardRecipients are only added again after the next refresh
+func (c *cache) StoreLookedUpRewardRecipient(id uint64, rr RewardRecipient) {
+	c.rewardRecipientMu.Lock()
+	defer c.rewardRecipientMu.Unlock()
+	if _, exists := c.rewardRecipient[id]; !exists {
+		if c.lookedUp >= maxLookedUpRewardRecipients {
+			return
+		}
+		c.lookedUp++
+	}
+	c.rewardRecipient[id] = rr
 }
 
-func (c *cache) StoreRewardRecipients(rewardRecipient map[uint64]bool) {
+func (c *cache) StoreRewardRecipients(rewardRecipient map[uint64]RewardRecipient) {
 	c.rewardRecipientMu.Lock()
 	defer c.rewardRecipientMu.Unlock()
 	c.rewardRecipient = rewardRecipient
+	c.lookedUp = 0
 }
 
 func (c *cache) StoreBestNonceSubmission(bestNonceSubmission NonceSubmission) {
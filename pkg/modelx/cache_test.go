@@ -2,6 +2,10 @@ package modelx
 
 import (
 	"testing"
+	"time"
+
+	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,3 +33,79 @@ func TestAddOrUpdate(t *testing.T) {
 		assert.Equal(t, i, h)
 	}
 }
+
+func TestGetPrevRoundInfo(t *testing.T) {
+	c := cache{}
+	c.StoreRoundInfo(Block{Height: 10, BaseTarget: 1})
+
+	_, ok := c.GetPrevRoundInfo(time.Minute)
+	assert.False(t, ok, "no previous round yet")
+
+	c.StoreRoundInfo(Block{Height: 11, BaseTarget: 2})
+	ri, ok := c.GetPrevRoundInfo(time.Minute)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(10), ri.Height)
+		assert.Equal(t, uint64(1), ri.BaseTarget)
+	}
+
+	// a switched block on the same height keeps the previous round
+	c.StoreRoundInfo(Block{Height: 11, BaseTarget: 3})
+	ri, _ = c.GetPrevRoundInfo(time.Minute)
+	assert.Equal(t, uint64(10), ri.Height)
+
+	_, ok = c.GetPrevRoundInfo(0)
+	assert.False(t, ok, "grace period exceeded")
+}
+
+func TestRewardRecipientIsPool(t *testing.T) {
+	joining := RewardRecipient{Assigned: true, FromHeight: 100}
+	leaving := RewardRecipient{PrevPool: true, FromHeight: 100}
+	unverified := RewardRecipient{Assigned: true, FromHeight: unknownHeight}
+	checked := RewardRecipient{FromHeight: unknownHeight, CheckedAt: 99, CheckedPool: true}
+
+	type test struct {
+		rr      RewardRecipient
+		height  uint64
+		isPool  bool
+		known   bool
+		message string
+	}
+	for _, test := range []test{
+		{joining, 99, false, true, "switch to the pool not effective yet"},
+		{joining, 100, true, true, "switch to the pool effective"},
+		{leaving, 99, true, true, "switch away not effective yet"},
+		{leaving, 100, false, true, "switch away effective"},
+		{unverified, 100, false, false, "unverified assignment known"},
+		{checked, 99, true, true, "lookup result not used"},
+		{checked, 100, false, false, "lookup result used for other height"},
+	} {
+		isPool, known := test.rr.IsPool(test.height)
+		assert.Equal(t, test.isPool, isPool, test.message)
+		assert.Equal(t, test.known, known, test.message)
+	}
+}
+
+func TestSubmitDeadlineLimit(t *testing.T) {
+	c := cache{}
+	c.StoreDeadlineLimit(1000)
+	m := &Miner{
+		ID:                  42,
+		DeadlinesParams:     map[uint64]*DeadlineParams{},
+		WeightedDeadlineSum: 6 * 120 * float64(burstmath.GenesisBaseTarget)}
+	for i := uint64(0); i < uint64(Cfg.NAVG); i++ {
+		m.DeadlinesParams[i] = nil
+	}
+	c.LoadOrStoreMiner(m)
+
+	defer func(rate float64) { Cfg.TargetDeadlineMissRate = rate }(Cfg.TargetDeadlineMissRate)
+	Cfg.TargetDeadlineMissRate = 0.001
+
+	assert.Equal(t, uint64(1000), c.SubmitDeadlineLimit(42, 10), "target deadline enforced before it was served")
+
+	targetDeadline := c.TargetDeadline(42, burstmath.GenesisBaseTarget, 10)
+	if assert.True(t, targetDeadline < 1000) {
+		assert.Equal(t, targetDeadline, c.SubmitDeadlineLimit(42, 10))
+	}
+	assert.Equal(t, uint64(1000), c.SubmitDeadlineLimit(42, 11), "target deadline of another round enforced")
+	assert.Equal(t, uint64(1000), c.SubmitDeadlineLimit(43, 10))
+}
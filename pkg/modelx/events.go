@@ -0,0 +1,30 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
+)
+
+// Events is the bus what happens in the pool is published on
+func (modelx *Modelx) Events() *events.Bus {
+	return modelx.events
+}
+
+func newBlockEvent(block Block) events.NewBlock {
+	return events.NewBlock{
+		Height:              block.Height,
+		BaseTarget:          block.BaseTarget,
+		Scoop:               block.Scoop,
+		GenerationSignature: block.GenerationSignature,
+		Created:             block.Created}
+}
+
+// addNonceSubmission queues the submission to be written and announces it
+func (modelx *Modelx) addNonceSubmission(minerID, height, deadline, nonce uint64) error {
+	if err := modelx.nonceWriter.add(minerID, height, deadline, nonce); err != nil {
+		return err
+	}
+	modelx.events.Publish(events.SubmissionAccepted{Height: height, MinerID: minerID, Deadline: deadline})
+	return nil
+}
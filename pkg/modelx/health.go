@@ -0,0 +1,131 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+const (
+	dbRetryAttempts   = 5
+	dbRetryBackoff    = 100 * time.Millisecond
+	dbRetryMaxBackoff = 5 * time.Second
+	dbCheckInterval   = 5 * time.Second
+)
+
+// DBStatus tells if the db is reachable. While it's degraded the pool keeps mining
+// from the cache and queues submissions until the db is back.
+type DBStatus struct {
+	Degraded          bool       `json:"degraded"`
+	DegradedSince     *time.Time `json:"degradedSince,omitempty"`
+	QueuedSubmissions int        `json:"queuedSubmissions"`
+}
+
+// dbHealth tracks outages of the db, it's degraded from a failed statement that
+// couldn't be retried until the db answers again
+type dbHealth struct {
+	db storage.Storage
+
+	mu            sync.Mutex
+	degradedSince time.Time
+}
+
+func newDBHealth(db storage.Storage) *dbHealth {
+	return &dbHealth{db: db}
+}
+
+// report marks the db as degraded if err is caused by an unreachable db, which is
+// returned
+func (h *dbHealth) report(err error) bool {
+	if err != nil {
+		if pingErr := h.db.Ping(); pingErr == nil {
+			// the statement failed on its own
+			return false
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	degraded := !h.degradedSince.IsZero()
+	switch {
+	case err != nil && !degraded:
+		h.degradedSince = time.Now()
+		Logger.Error("database unavailable, running degraded", zap.Error(err))
+	case err == nil && degraded:
+		Logger.Info("database available again", zap.Duration("downtime", time.Since(h.degradedSince)))
+		h.degradedSince = time.Time{}
+	}
+	return err != nil
+}
+
+func (h *dbHealth) status() DBStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.degradedSince.IsZero() {
+		return DBStatus{}
+	}
+	degradedSince := h.degradedSince
+	return DBStatus{Degraded: true, DegradedSince: &degradedSince}
+}
+
+// watch pings the db while it's degraded to notice when it's back
+func (h *dbHealth) watch() {
+	for range time.Tick(dbCheckInterval) {
+		if h.status().Degraded {
+			h.report(h.db.Ping())
+		}
+	}
+}
+
+// retry runs the idempotent op until it succeeds, backing off between attempts
+func (h *dbHealth) retry(op string, f func() error) error {
+	backoff := dbRetryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		if attempt == dbRetryAttempts {
+			break
+		}
+		Logger.Warn("database operation failed, retrying", zap.String("op", op),
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > dbRetryMaxBackoff {
+			backoff = dbRetryMaxBackoff
+		}
+	}
+	h.report(err)
+	return err
+}
+
+// exec runs an idempotent statement with retries
+func (h *dbHealth) exec(op, query string, args ...interface{}) error {
+	return h.retry(op, func() error {
+		_, err := h.db.Exec(query, args...)
+		return err
+	})
+}
+
+// execOnce runs a statement without retrying, for callers that can't wait for the db
+func (h *dbHealth) execOnce(query string, args ...interface{}) error {
+	_, err := h.db.Exec(query, args...)
+	if err != nil {
+		h.report(err)
+	}
+	return err
+}
+
+// DBStatus returns whether the db is reachable and how many submissions wait to be
+// written
+func (modelx *Modelx) DBStatus() DBStatus {
+	status := modelx.health.status()
+	status.QueuedSubmissions = modelx.nonceWriter.queued()
+	return status
+}
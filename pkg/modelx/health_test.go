@@ -0,0 +1,78 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBHealthRetry(t *testing.T) {
+	h := newDBHealth(modelx.db)
+
+	var calls int
+	err := h.retry("test", func() error {
+		if calls++; calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	// a failing statement doesn't make a reachable db degraded
+	calls = 0
+	err = h.retry("test", func() error {
+		calls++
+		return errors.New("constraint")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, dbRetryAttempts, calls)
+	assert.False(t, h.status().Degraded)
+}
+
+func TestDBHealthDegraded(t *testing.T) {
+	db, err := storage.Open(DBConfig{Driver: DriverSQLite, Name: ":memory:"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	h := newDBHealth(db)
+
+	db.Close()
+	assert.True(t, h.report(errors.New("connection refused")))
+	status := h.status()
+	assert.True(t, status.Degraded)
+	assert.NotNil(t, status.DegradedSince)
+
+	assert.False(t, h.report(nil))
+	assert.False(t, h.status().Degraded, "db not marked as available again")
+}
+
+func TestStoreQueuedBlock(t *testing.T) {
+	height := uint64(14)
+	defer modelx.db.MustExec("DELETE FROM block WHERE height = ?", height)
+
+	modelx.missingBlocksMu.Lock()
+	modelx.missingBlocks[height] = []interface{}{height, 1, 1, "gensig", time.Now(), 0}
+	modelx.missingBlocksMu.Unlock()
+
+	// the generation time of a queued block is stored along with it
+	assert.Nil(t, modelx.storeGenerationTime(height, 240))
+	assert.Nil(t, modelx.storeQueuedBlock(height))
+
+	modelx.missingBlocksMu.Lock()
+	_, missing := modelx.missingBlocks[height]
+	modelx.missingBlocksMu.Unlock()
+	assert.False(t, missing, "stored block still queued")
+
+	var generationTime int32
+	if assert.Nil(t, modelx.db.Get(&generationTime, "SELECT generation_time FROM block WHERE height = ?", height)) {
+		assert.Equal(t, int32(240), generationTime)
+	}
+}
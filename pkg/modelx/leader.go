@@ -0,0 +1,156 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+const leaderLeaseName = "pool"
+
+var errLeaseLost = errors.New("leader lease lost")
+
+// leaderLease elects the instance that runs the jobs, submits nonces and sends payments
+// among the ones sharing the db. The lease is renewed long before it expires, if the
+// leader fails to do so another instance takes it over once it's expired.
+type leaderLease struct {
+	db       storage.Storage
+	id       string
+	duration time.Duration
+
+	mu         sync.Mutex
+	validUntil time.Time
+	leading    bool
+}
+
+func newLeaderLease(db storage.Storage, id string, duration time.Duration) *leaderLease {
+	return &leaderLease{db: db, id: id, duration: duration}
+}
+
+// renew extends the lease if it's held by this instance and takes it over if it's
+// expired. Expiry is judged by the db's clock, so that it's the same for all instances.
+func (l *leaderLease) renew() error {
+	// the lease is considered lost before the other instances see it expired, the db
+	// sets the expiry after this
+	start := time.Now()
+	expires := start.Add(l.duration)
+	duration := int64(l.duration / time.Millisecond)
+
+	now := l.db.UnixMs()
+	res, err := l.db.Exec(`UPDATE leader_lease SET holder = ?, expires = `+now+` + ?
+                WHERE name = ? AND (holder = ? OR expires < `+now+`)`,
+		l.id, duration, leaderLeaseName, l.id)
+	if err != nil {
+		return err
+	}
+	acquired, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if acquired == 0 {
+		// no instance held the lease so far
+		res, err = l.db.Exec(l.db.InsertIgnore("leader_lease", "(name, holder, expires) VALUES (?, ?, "+now+" + ?)"),
+			leaderLeaseName, l.id, duration)
+		if err != nil {
+			return err
+		}
+		if acquired, err = res.RowsAffected(); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if acquired == 1 {
+		l.validUntil = expires
+	} else {
+		l.validUntil = time.Time{}
+	}
+	l.update()
+	return nil
+}
+
+// update logs when the instance became leader or lost the lease, mu must be held
+func (l *leaderLease) update() {
+	leading := time.Now().Before(l.validUntil)
+	switch {
+	case leading && !l.leading:
+		Logger.Info("became leader", zap.String("id", l.id))
+	case !leading && l.leading:
+		Logger.Warn("lost leadership", zap.String("id", l.id))
+	}
+	l.leading = leading
+}
+
+// held tells if this instance is the leader
+func (l *leaderLease) held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.update()
+	return l.leading
+}
+
+func (l *leaderLease) run() {
+	for range time.Tick(l.duration / 3) {
+		if err := l.renew(); err != nil {
+			Logger.Error("renewing leader lease failed", zap.Error(err))
+			l.held()
+		}
+	}
+}
+
+// release gives up the lease, so that another instance can take over right away
+func (l *leaderLease) release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !time.Now().Before(l.validUntil) {
+		return nil
+	}
+	l.validUntil = time.Time{}
+	l.update()
+	_, err := l.db.Exec("UPDATE leader_lease SET expires = 0 WHERE name = ? AND holder = ?",
+		leaderLeaseName, l.id)
+	return err
+}
+
+// check confirms that the lease is still held in the db. Within a transaction the lease
+// can't be taken over until it ends.
+func (l *leaderLease) check(q storage.Querier) error {
+	var holder string
+	err := q.Get(&holder, "SELECT holder FROM leader_lease WHERE name = ? AND holder = ? AND expires > "+
+		l.db.UnixMs()+l.db.ForUpdate(), leaderLeaseName, l.id)
+	if err == sql.ErrNoRows {
+		return errLeaseLost
+	}
+	return err
+}
+
+// checkLeader confirms that this instance still leads before it pays, see check
+func (modelx *Modelx) checkLeader(q storage.Querier) error {
+	if modelx.leader == nil {
+		return nil
+	}
+	return modelx.leader.check(q)
+}
+
+// instanceID identifies this instance among the ones sharing the db
+func (modelx *Modelx) instanceID() string {
+	if modelx.leader == nil {
+		return leaderLeaseName
+	}
+	return modelx.leader.id
+}
+
+// IsLeader tells if this instance runs the jobs, submits nonces and sends payments.
+// Without leader election it's always the case.
+func (modelx *Modelx) IsLeader() bool {
+	return modelx.leader == nil || modelx.leader.held()
+}
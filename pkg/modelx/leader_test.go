@@ -0,0 +1,68 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderLease(t *testing.T) {
+	defer modelx.db.MustExec("DELETE FROM leader_lease")
+
+	a := newLeaderLease(modelx.db, "a", 100*time.Millisecond)
+	b := newLeaderLease(modelx.db, "b", 100*time.Millisecond)
+
+	assert.Nil(t, a.renew())
+	assert.Nil(t, b.renew())
+	assert.True(t, a.held(), "free lease not acquired")
+	assert.False(t, b.held(), "lease held twice")
+
+	assert.Nil(t, a.renew())
+	assert.True(t, a.held(), "lease not renewed")
+	assert.Nil(t, a.check(modelx.db))
+	assert.Equal(t, errLeaseLost, b.check(modelx.db), "lease of another instance confirmed")
+
+	// the leader stopped renewing
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, a.held(), "expired lease still held")
+	assert.Equal(t, errLeaseLost, a.check(modelx.db), "expired lease confirmed")
+	assert.Nil(t, b.renew())
+	assert.True(t, b.held(), "expired lease not taken over")
+	assert.Nil(t, a.renew())
+	assert.False(t, a.held())
+
+	assert.Nil(t, b.release())
+	assert.False(t, b.held())
+	assert.Nil(t, a.renew())
+	assert.True(t, a.held(), "released lease not taken over")
+}
+
+func TestClaimTransaction(t *testing.T) {
+	defer modelx.db.MustExec("DELETE FROM leader_lease")
+	defer func(leader *leaderLease) { modelx.leader = leader }(modelx.leader)
+
+	id, err := modelx.db.InsertID("INSERT INTO `transaction` (block_height) VALUES (NULL)")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer modelx.db.MustExec("DELETE FROM `transaction` WHERE id = ?", id)
+
+	// another instance leads
+	b := newLeaderLease(modelx.db, "b", time.Minute)
+	assert.Nil(t, b.renew())
+	modelx.leader = newLeaderLease(modelx.db, "a", time.Minute)
+	_, err = modelx.claimTransaction(uint64(id))
+	assert.Equal(t, errLeaseLost, err, "claimed without leading")
+
+	assert.Nil(t, b.release())
+	assert.Nil(t, modelx.leader.renew())
+	claimed, err := modelx.claimTransaction(uint64(id))
+	assert.Nil(t, err)
+	assert.True(t, claimed, "transaction not claimed")
+	claimed, err = modelx.claimTransaction(uint64(id))
+	assert.Nil(t, err)
+	assert.False(t, claimed, "transaction claimed twice")
+}
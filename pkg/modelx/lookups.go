@@ -0,0 +1,54 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// on demand reward recipient lookups per ip and in total within lookupWindow
+	maxLookupsPerIP = 30
+	maxLookups      = 1000
+	lookupWindow    = time.Minute
+
+	// lookup results kept until the reward recipients are refreshed with the next block
+	maxLookedUpRewardRecipients = 100000
+)
+
+// ErrLookupThrottled is returned if a reward recipient can't be looked up right now
+var ErrLookupThrottled = errors.New("too many reward recipient lookups")
+
+// lookupLimiter throttles the reward recipient lookups in the wallet, so that
+// submissions for random accounts can't flood it
+type lookupLimiter struct {
+	mu     sync.Mutex
+	start  time.Time
+	total  int
+	perIP  map[string]int
+	window time.Duration
+}
+
+func newLookupLimiter() *lookupLimiter {
+	return &lookupLimiter{perIP: make(map[string]int), window: lookupWindow}
+}
+
+// allow counts a lookup for ip if neither the ip's nor the total limit is exhausted
+func (l *lookupLimiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		l.total = 0
+		l.perIP = make(map[string]int)
+	}
+	if l.total >= maxLookups || l.perIP[ip] >= maxLookupsPerIP {
+		return false
+	}
+	l.total++
+	l.perIP[ip]++
+	return true
+}
@@ -0,0 +1,50 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupLimiter(t *testing.T) {
+	l := newLookupLimiter()
+	now := time.Now()
+
+	for i := 0; i < maxLookupsPerIP; i++ {
+		assert.True(t, l.allow("192.0.2.1", now))
+	}
+	assert.False(t, l.allow("192.0.2.1", now), "lookups of ip not limited")
+	assert.True(t, l.allow("192.0.2.2", now), "other ip throttled")
+
+	for i := 0; l.total < maxLookups; i++ {
+		l.allow("10.0.0."+strconv.Itoa(i), now)
+	}
+	assert.False(t, l.allow("192.0.2.3", now), "lookups not limited in total")
+
+	assert.True(t, l.allow("192.0.2.1", now.Add(lookupWindow)), "limit not reset with next window")
+}
+
+func TestStoreLookedUpRewardRecipient(t *testing.T) {
+	c := cache{}
+	c.StoreRewardRecipients(map[uint64]RewardRecipient{})
+	c.lookedUp = maxLookedUpRewardRecipients - 1
+
+	c.StoreLookedUpRewardRecipient(1, RewardRecipient{FromHeight: unknownHeight, CheckedAt: 10})
+	c.StoreLookedUpRewardRecipient(2, RewardRecipient{FromHeight: unknownHeight, CheckedAt: 10})
+	_, cached := c.GetRewardRecipient(2)
+	assert.False(t, cached, "lookups cached beyond limit")
+
+	// known accounts are still updated
+	c.StoreLookedUpRewardRecipient(1, RewardRecipient{FromHeight: unknownHeight, CheckedAt: 11})
+	rr, _ := c.GetRewardRecipient(1)
+	assert.Equal(t, uint64(11), rr.CheckedAt)
+
+	c.StoreRewardRecipients(map[uint64]RewardRecipient{})
+	c.StoreLookedUpRewardRecipient(2, RewardRecipient{FromHeight: unknownHeight, CheckedAt: 10})
+	_, cached = c.GetRewardRecipient(2)
+	assert.True(t, cached, "limit not reset with refresh")
+}
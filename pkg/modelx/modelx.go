@@ -13,15 +13,14 @@ import (
 
 	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
 	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
 	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
 	"github.com/PoC-Consortium/Nogrod/pkg/rsencoding"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
 	"github.com/PoC-Consortium/Nogrod/pkg/wallet"
 	"github.com/PoC-Consortium/Nogrod/pkg/wallethandler"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/mysql"
-	_ "github.com/golang-migrate/migrate/source/file"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -49,8 +48,21 @@ type Miner struct {
 
 	UserAgent string
 
-	// this mutex ensures that there is only one concurrent write process
-	// to the db for each miner
+	// listener the miner submitted to last, its limits apply to the miner
+	Listener *MiningListener
+
+	// accepted and rejected submissions in the round at submitsHeight
+	submitsHeight   uint64
+	roundSubmits    int
+	rejectedSubmits int
+	lastSubmit      time.Time
+
+	// target deadline answered in the mining info of the round at targetHeight
+	targetHeight   uint64
+	targetDeadline uint64
+
+	// this mutex ensures that there is only one concurrent update of the
+	// submissions of each miner
 	dbMu sync.Mutex
 
 	// this mutex is used to protect the data
@@ -74,7 +86,11 @@ type Block struct {
 	Reward                   sql.NullInt64
 	BestNonceSubmissionID    sql.NullInt64 `db:"best_nonce_submission_id"`
 	Created                  time.Time
-	GenerationTime           int32 `db:"generation_time"`
+	GenerationTime           int32          `db:"generation_time"`
+	NonceSubmitted           bool           `db:"nonce_submitted"`
+	SubmitWallet             sql.NullString `db:"submit_wallet"`
+	SubmitLatency            sql.NullInt64  `db:"submit_latency"`
+	DeadlineMismatch         bool           `db:"deadline_mismatch"`
 }
 
 type Transaction struct {
@@ -85,11 +101,32 @@ type Transaction struct {
 }
 
 type Modelx struct {
-	db            *sqlx.DB
+	db            storage.Storage
+	readDBMu      sync.RWMutex
+	readDB        storage.Storage
 	walletDB      *sqlx.DB
 	walletHandler wallethandler.WalletHandler
+	nonceWriter   *nonceWriter
+	health        *dbHealth
+	events        *events.Bus
+	leader        *leaderLease
+	autoBans      chan autoBan
+	lookups       *lookupLimiter
 
 	newBlockMu sync.Mutex
+
+	// blocks and switches that couldn't be stored while the db was unavailable by height
+	missingBlocksMu sync.Mutex
+	missingBlocks   map[uint64][]interface{}
+	missingSwitches map[uint64]*blockSwitch
+}
+
+// blockSwitch replaces the block at a height by the one of another fork
+type blockSwitch struct {
+	baseTarget uint64
+	genSig     string
+	// the miners whose submissions on the old fork get deleted
+	minerIDs []uint64
 }
 
 type NonceSubmission struct {
@@ -124,27 +161,62 @@ func NewModelX(walletHandler wallethandler.WalletHandler, migrateDB bool) *Model
 	if err != nil {
 		Logger.Fatal("failed to connect to database", zap.Error(err))
 	}
+	return newModelX(walletHandler, db)
+}
+
+// newModelX sets up the model on top of an initialized db
+func newModelX(walletHandler wallethandler.WalletHandler, db storage.Storage) *Modelx {
 	modelx := Modelx{
-		db:            db,
-		walletHandler: walletHandler}
+		db:              db,
+		readDB:          db,
+		walletHandler:   walletHandler,
+		health:          newDBHealth(db),
+		events:          events.NewBus(),
+		autoBans:        make(chan autoBan, autoBanQueueSize),
+		lookups:         newLookupLimiter(),
+		missingBlocks:   make(map[uint64][]interface{}),
+		missingSwitches: make(map[uint64]*blockSwitch)}
+	go modelx.health.watch()
+	modelx.connectReadDB()
+
+	nonceWriter, err := newNonceWriter(db)
+	if err != nil {
+		Logger.Fatal("opening nonce submission log failed", zap.Error(err))
+	}
+	nonceWriter.health = modelx.health
+	// submissions can only be written once their block is stored
+	nonceWriter.prepare = modelx.storeMissingBlocks
+	modelx.nonceWriter = nonceWriter
+	// submissions recovered from the log need to be in the db before miners are cached
+	if err := modelx.nonceWriter.Flush(); err != nil {
+		Logger.Error("writing recovered nonce submissions failed", zap.Error(err))
+	}
 
 	if Cfg.WalletDB.Name != "" {
 		walletDB, err := sqlx.Connect("mysql", Cfg.WalletDB.DataSourceName(true))
 		if err != nil {
 			Logger.Fatal("failed to connect to database", zap.Error(err))
 		}
+		storage.SetPoolLimits(walletDB, Cfg.WalletDB)
 		modelx.walletDB = walletDB
 	}
 
 	if Cfg.FeeAccountID != 0 {
-		modelx.createFeeAccount()
+		if err := modelx.createFeeAccount(); err != nil {
+			Logger.Fatal("creating fee account failed", zap.Error(err))
+		}
 	}
 
+	if err := modelx.LoadBans(); err != nil {
+		Logger.Error("loading bans failed", zap.Error(err))
+	}
+	go modelx.autoBanJob()
+
 	loaded := modelx.loadCurrentBlock()
-	if loaded {
+	if loaded && !modelx.loadSnapshot() {
 		modelx.cacheMiners()
 		modelx.cacheRewardRecipients()
-	} else {
+	} else if !loaded {
 		miningInfo, err := modelx.walletHandler.GetMiningInfo()
 		if err != nil {
 			Logger.Fatal("getting inital mining info failed", zap.Error(err))
@@ -156,24 +228,38 @@ func NewModelX(walletHandler wallethandler.WalletHandler, migrateDB bool) *Model
 		}
 	}
 
+	if Cfg.LeaderElection.Enabled {
+		modelx.leader = newLeaderLease(db, Cfg.LeaderElection.ID, Cfg.LeaderElection.LeaseDur)
+		if err := modelx.leader.renew(); err != nil {
+			Logger.Error("acquiring leader lease failed", zap.Error(err))
+		}
+		go modelx.leader.run()
+	}
+
+	go modelx.nonceWriter.run()
+	go modelx.snapshotJob()
+
 	return &modelx
 }
 
-func initializeDatabase(migrateDB bool) (*sqlx.DB, error) {
-	tmpdb, err := sqlx.Connect("mysql", Cfg.DB.DataSourceName(false))
-
-	if err != nil {
-		Logger.Fatal("failed to connect to sql server", zap.Error(err))
+// Shutdown writes the queued submissions and saves the cache for a fast restart, the
+// leader lease is handed over to the other instances
+func (modelx *Modelx) Shutdown() {
+	if err := modelx.nonceWriter.Flush(); err != nil {
+		Logger.Error("writing nonce submissions on shutdown failed", zap.Error(err))
 	}
-
-	_, err = tmpdb.Exec("CREATE SCHEMA IF NOT EXISTS `" + Cfg.DB.Name + "` DEFAULT CHARACTER SET utf8;")
-	if err != nil {
-		Logger.Fatal("failed to create the database", zap.Error(err))
+	if err := modelx.SaveSnapshot(); err != nil {
+		Logger.Error("saving cache snapshot on shutdown failed", zap.Error(err))
 	}
+	if modelx.leader != nil {
+		if err := modelx.leader.release(); err != nil {
+			Logger.Error("releasing leader lease failed", zap.Error(err))
+		}
+	}
+}
 
-	tmpdb.Close()
-
-	db, err := sqlx.Connect("mysql", Cfg.DB.DataSourceName(true))
+func initializeDatabase(migrateDB bool) (storage.Storage, error) {
+	db, err := storage.Open(Cfg.DB)
 	if err != nil {
 		Logger.Fatal("failed to connect to sql server", zap.Error(err))
 	}
@@ -181,33 +267,7 @@ func initializeDatabase(migrateDB bool) (*sqlx.DB, error) {
 		return db, err
 	}
 
-	driver, err := mysql.WithInstance(db.DB, &mysql.Config{})
-	if err != nil {
-		Logger.Fatal("failed to initialise migration driver", zap.Error(err))
-	}
-
-	m, err := migrate.NewWithDatabaseInstance("file://migrations", "mysql", driver)
-	if err != nil {
-		Logger.Fatal("failed to initialise migration instance", zap.Error(err))
-	}
-
-	version, dirty, versionErr := m.Version()
-
-	if versionErr != nil && versionErr != migrate.ErrNilVersion {
-		Logger.Fatal("failed to get migration version", zap.Error(err))
-	}
-
-	if dirty {
-		Logger.Warn("Forcing migration", zap.Uint("version", version))
-		err = m.Force(int(version))
-
-		if err != nil {
-			Logger.Fatal("failed to force execute migration", zap.Error(err))
-		}
-	}
-
-	migrateErr := m.Up()
-	if migrateErr != nil && migrateErr != migrate.ErrNoChange {
+	if err := db.Migrate(); err != nil {
 		Logger.Fatal("failed to execute migrations", zap.Error(err))
 	}
 
@@ -235,9 +295,12 @@ func (modelx *Modelx) loadCurrentBlock() bool {
 func (modelx *Modelx) cacheMiners() {
 	type submissionData struct {
 		Miner
-		Deadline   uint64 `db:"deadline"`
-		Height     uint64 `db:"height"`
-		BaseTarget uint64 `db:"block.base_target"`
+		Deadline       uint64     `db:"deadline"`
+		Height         uint64     `db:"height"`
+		BaseTarget     uint64     `db:"block.base_target"`
+		MinPayoutValue *int64     `db:"account.min_payout_value"`
+		PayoutInterval *string    `db:"account.payout_interval"`
+		NextPayoutDate *time.Time `db:"account.next_payout_date"`
 	}
 
 	var slowBlockHeights []uint64
@@ -275,9 +338,9 @@ func (modelx *Modelx) cacheMiners() {
                   deadline          "deadline",
                   block_height      "height",
                   block.base_target "block.base_target",
-                  COALESCE(account.min_payout_value,
-                           CONCAT(account.payout_interval, "|", account.next_payout_date),
-                           "") "account.payout_detail"
+                  account.min_payout_value "account.min_payout_value",
+                  account.payout_interval  "account.payout_interval",
+                  account.next_payout_date "account.next_payout_date"
                 FROM nonce_submission
                   JOIN account ON nonce_submission.miner_id = account.id
                   JOIN block ON block.height = nonce_submission.block_height
@@ -287,7 +350,6 @@ func (modelx *Modelx) cacheMiners() {
 	if err != nil {
 		Logger.Fatal("failed to create IN query", zap.Error(err))
 	}
-	query = modelx.db.Rebind(query)
 
 	var sds []submissionData
 
@@ -306,6 +368,8 @@ func (modelx *Modelx) cacheMiners() {
 		if miner == nil {
 			miner = &sds[i].Miner
 			miner.DeadlinesParams = make(map[uint64]*DeadlineParams)
+			miner.PayoutDetail = payoutDetail(sds[i].MinPayoutValue, sds[i].PayoutInterval,
+				sds[i].NextPayoutDate)
 			Cache.LoadOrStoreMiner(miner)
 		}
 
@@ -325,45 +389,41 @@ func (modelx *Modelx) cacheMiners() {
 	}
 }
 
-func (modelx *Modelx) createFeeAccount() {
-	modelx.db.MustExec(
-		"INSERT IGNORE INTO account (id, address) VALUES (?, ?)",
+// payoutDetail yields the minimum payout of a miner or its payout interval with the
+// next payout date separated by |, or nothing if neither is set
+func payoutDetail(minPayoutValue *int64, payoutInterval *string, nextPayoutDate *time.Time) string {
+	switch {
+	case minPayoutValue != nil:
+		return strconv.FormatInt(*minPayoutValue, 10)
+	case payoutInterval != nil && nextPayoutDate != nil:
+		return *payoutInterval + "|" + nextPayoutDate.Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+func (modelx *Modelx) createFeeAccount() error {
+	return modelx.health.exec("create fee account",
+		modelx.db.InsertIgnore("account", "(id, address) VALUES (?, ?)"),
 		Cfg.FeeAccountID, rsencoding.Encode(Cfg.FeeAccountID))
 }
 
 func (modelx *Modelx) CleanDB() {
 	Logger.Info("starting to cleanup db")
 
-	currentBlock := Cache.CurrentBlock()
-	modelx.db.MustExec("DELETE FROM block WHERE height < ?", currentBlock.Height-5000)
-	modelx.db.MustExec(`DELETE FROM miner WHERE id NOT IN
-                              (SELECT DISTINCT miner_id FROM nonce_submission)`)
-
-	tx, err := modelx.db.Begin()
-	if err != nil {
-		Logger.Error("begining cleanup transaction failed", zap.Error(err))
+	// miners without submissions in the db are deleted
+	if err := modelx.nonceWriter.Flush(); err != nil {
+		Logger.Error("flushing nonce submissions failed", zap.Error(err))
 		return
 	}
 
-	transferPendngSQL := `UPDATE account SET pending =
-                               (SELECT * FROM ( SELECT SUM(pending) FROM account WHERE id NOT IN
-                                 (SELECT id FROM miner) ) bold ) WHERE id = ?`
-	_, err = tx.Exec(transferPendngSQL, Cfg.FeeAccountID)
+	modelx.applyRetention()
+	err := modelx.health.exec("delete inactive miners", `DELETE FROM miner WHERE id NOT IN
+                              (SELECT DISTINCT miner_id FROM nonce_submission)`)
 	if err != nil {
-		Logger.Error("transfering pending to fee account failed", zap.Error(err))
-		tx.Rollback()
-		return
+		Logger.Error("deleting inactive miners failed", zap.Error(err))
 	}
 
-	deleteOldAccountsSQL := "DELETE FROM account WHERE id != ? AND id NOT IN (SELECT id FROM miner)"
-	_, err = tx.Exec(deleteOldAccountsSQL, Cfg.FeeAccountID)
-	if err != nil {
-		Logger.Error("deleteing old accounts failed", zap.Error(err))
-		tx.Rollback()
-		return
-	}
-
-	tx.Commit()
+	modelx.handleAbandonedBalances()
 }
 
 func (modelx *Modelx) RereadMinerNames() {
@@ -385,9 +445,12 @@ func (modelx *Modelx) RereadMinerNames() {
 		miner.Name = accountInfo.Name
 		miner.Unlock()
 
-		_, err = modelx.db.Exec("UPDATE account SET name = ? WHERE id = ?", accountInfo.Name, miner.ID)
+		err = modelx.health.exec("update miner name", "UPDATE account SET name = ? WHERE id = ?",
+			accountInfo.Name, miner.ID)
 		if err != nil {
 			Logger.Error("updating miner name failed", zap.Error(err))
+			// the names are read again next time
+			return !modelx.health.status().Degraded
 		}
 
 		return true
@@ -396,11 +459,11 @@ func (modelx *Modelx) RereadMinerNames() {
 
 func (modelx *Modelx) getMinerFromDB(accountID uint64) *Miner {
 	sql := `SELECT
-                  id,
+                  account.id,
 	          address,
                   COALESCE(name, '') "name",
 	          pending
-	        FROM account WHERE id = ?`
+	        FROM account JOIN miner ON miner.id = account.id WHERE account.id = ?`
 
 	miner := Miner{}
 	err := modelx.db.Get(&miner, sql, accountID)
@@ -453,6 +516,7 @@ func (modelx *Modelx) MaybeSwitchOrNewBlock(baseTarget uint64, genSig string, he
 			Logger.Error("switching new block", zap.Error(err))
 			return
 		}
+		modelx.events.Publish(events.BlockSwitched(newBlockEvent(Cache.CurrentBlock())))
 	} else if oldBlock.Height != height {
 		Logger.Info("got new Block with height", zap.Uint64("height", height))
 
@@ -466,6 +530,9 @@ func (modelx *Modelx) MaybeSwitchOrNewBlock(baseTarget uint64, genSig string, he
 	return
 }
 
+const blockColumns = `(height, base_target, scoop, generation_signature, created, generation_time)
+	        VALUES (?, ?, ?, ?, ?, ?)`
+
 func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64) error {
 	if _, exists := Cache.WasSlowBlock(height); exists {
 		return nil
@@ -481,17 +548,21 @@ func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64)
 		return err
 	}
 
-	var removedHeight uint64
+	var removedHeight, closedHeight uint64
 	var newBlock Block
 	var generationTime int32
+	created := modelx.roundStart(height)
 	if height > currentBlock.Height {
 		generationTime, err = modelx.getGenerationTime(currentBlock.Height)
 		if err != nil {
 			Logger.Error("could not get generation time", zap.Uint64("height", currentBlock.Height))
 		} else {
-			modelx.db.MustExec("UPDATE block SET generation_time = ? WHERE height = ?",
-				generationTime, currentBlock.Height)
+			if err := modelx.storeGenerationTime(currentBlock.Height, generationTime); err != nil {
+				Logger.Error("storing generation time failed", zap.Uint64("height", currentBlock.Height),
+					zap.Error(err))
+			}
 			removedHeight = Cache.AddBlock(currentBlock.Height, generationTime)
+			closedHeight = currentBlock.Height
 		}
 		newBlock = Block{
 			Height:                   height,
@@ -499,7 +570,7 @@ func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64)
 			Scoop:                    burstmath.CalcScoop(height, genSigBytes),
 			GenerationSignature:      genSig,
 			GenerationSignatureBytes: genSigBytes,
-			Created:                  time.Now()}
+			Created:                  created}
 	} else {
 		generationTime, err = modelx.getGenerationTime(height)
 		if err != nil {
@@ -510,11 +581,13 @@ func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64)
 		removedHeight = Cache.AddBlock(height, generationTime)
 	}
 
-	modelx.db.MustExec(`INSERT
-	        INTO block (height, base_target, scoop, generation_signature, created, generation_time)
-	        VALUES (?, ?, ?, ?, ?, ?)`,
-		height, baseTarget, burstmath.CalcScoop(height, genSigBytes), genSig,
-		time.Now(), generationTime)
+	// the block is queued before the cache moves on, so that the nonce writer stores it
+	// before any submissions on it
+	blockArgs := []interface{}{height, baseTarget, burstmath.CalcScoop(height, genSigBytes), genSig,
+		created, generationTime}
+	modelx.missingBlocksMu.Lock()
+	modelx.missingBlocks[height] = blockArgs
+	modelx.missingBlocksMu.Unlock()
 
 	Cache.MinerRange(func(key, value interface{}) bool {
 		miner := value.(*Miner)
@@ -526,7 +599,7 @@ func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64)
 		if removedHeight != 0 {
 			miner.removeDeadlineParams(removedHeight)
 		}
-		if miner.CurrentBlockHeight() < height-Cfg.InactiveAfterXBlocks {
+		if len(miner.DeadlinesParams) == 0 && miner.CurrentBlockHeight() < height-Cfg.InactiveAfterXBlocks {
 			Cache.DeleteMiner(key.(uint64))
 		}
 		miner.Unlock()
@@ -534,23 +607,135 @@ func (modelx *Modelx) newBlock(baseTarget uint64, genSig string, height uint64)
 		return true
 	})
 
+	// with several instances the submissions are spread over their caches, late
+	// submissions on the closed block still count for its shares
+	if closedHeight != 0 && !Cfg.LeaderElection.Enabled {
+		time.AfterFunc(Cfg.LateSubmissionGraceDur, func() { modelx.storeCachedShares(closedHeight) })
+	}
+
 	if newBlock.Height != 0 {
 		modelx.cacheRewardRecipients()
+		modelx.updateDeadlineLimit()
 		Cache.StoreCurrentBlock(newBlock)
+		modelx.events.Publish(newBlockEvent(newBlock))
+	}
+
+	// mining goes on from the cache while the db is unavailable, the block isn't retried
+	// here so that the next one isn't held up
+	if err := modelx.storeQueuedBlock(height); err != nil {
+		Logger.Error("storing block failed, retrying before submissions are written",
+			zap.Uint64("height", height), zap.Error(err))
 	}
 
 	return nil
 }
 
-func (modelx *Modelx) switchBlock(baseTarget uint64, genSig string, height uint64) error {
-	genSigBytes, err := burstmath.DecodeGeneratorSignature(genSig)
+// storeQueuedBlock tries once to store a block queued by newBlock. It stays queued if
+// the db is unavailable.
+func (modelx *Modelx) storeQueuedBlock(height uint64) error {
+	modelx.missingBlocksMu.Lock()
+	defer modelx.missingBlocksMu.Unlock()
+	blockArgs, missing := modelx.missingBlocks[height]
+	if !missing {
+		return nil
+	}
+	err := modelx.health.execOnce(modelx.db.InsertIgnore("block", blockColumns), blockArgs...)
+	if err == nil || !modelx.health.status().Degraded {
+		delete(modelx.missingBlocks, height)
+	}
+	return err
+}
+
+// storeGenerationTime stores the generation time of a closed block once, or along with
+// the block if it's still queued
+func (modelx *Modelx) storeGenerationTime(height uint64, generationTime int32) error {
+	modelx.missingBlocksMu.Lock()
+	defer modelx.missingBlocksMu.Unlock()
+	if blockArgs, missing := modelx.missingBlocks[height]; missing {
+		blockArgs[5] = generationTime
+		return nil
+	}
+	return modelx.health.execOnce("UPDATE block SET generation_time = ? WHERE height = ?", generationTime, height)
+}
+
+// storeMissingBlocks stores the blocks and switches that couldn't be stored when they
+// came in
+func (modelx *Modelx) storeMissingBlocks() error {
+	modelx.missingBlocksMu.Lock()
+	defer modelx.missingBlocksMu.Unlock()
+	for height, blockArgs := range modelx.missingBlocks {
+		if _, err := modelx.db.Exec(modelx.db.InsertIgnore("block", blockColumns), blockArgs...); err != nil {
+			return err
+		}
+		delete(modelx.missingBlocks, height)
+		Logger.Info("stored missing block", zap.Uint64("height", height))
+	}
+	for height, blockSwitch := range modelx.missingSwitches {
+		err := modelx.storeSwitch(height, blockSwitch, func(_, query string, args ...interface{}) error {
+			_, err := modelx.db.Exec(query, args...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		delete(modelx.missingSwitches, height)
+		Logger.Info("stored missing block switch", zap.Uint64("height", height))
+	}
+	return nil
+}
+
+// roundStart derives the start of the round at height from the chain timestamp of the
+// previous block, corrected by the measured clock offset to the wallet
+func (modelx *Modelx) roundStart(height uint64) time.Time {
+	now := time.Now()
+	if height == 0 {
+		return now
+	}
+	prevBlock, err := modelx.walletHandler.GetBlockInfo(height - 1)
 	if err != nil {
-		return err
+		Logger.Warn("could not get previous block, using local time as round start",
+			zap.Uint64("height", height), zap.Error(err))
+		return now
+	}
+	start := burstmath.TimeStampToDate(int64(prevBlock.Timestamp)).Add(Cache.ClockOffset())
+	if start.After(now) {
+		return now
 	}
+	return start
+}
 
-	_, err = modelx.db.Exec(
-		"UPDATE block SET generation_signature = ?, baseTarget = ? WHERE height = ?",
-		genSig, baseTarget, height)
+// updateDeadlineLimit adapts the pool wide deadline limit, so that the pool receives about
+// Cfg.SubmissionsPerRound deadlines below it in a round. The configured deadline
+// limit serves as upper bound. The pool's capacity is the one of the cached miners, with
+// several instances every one adapts its limit to the miners that stick to it.
+func (modelx *Modelx) updateDeadlineLimit() {
+	if Cfg.SubmissionsPerRound == 0 {
+		return
+	}
+	// an outdated replica would lag behind the block that was just added
+	deadlineLimit := dynamicDeadlineLimit(modelx.avgNetDiff(uint(Cfg.NAVG), modelx.primaryQuery),
+		Cache.GetPoolCap())
+	Logger.Info("updated deadline limit", zap.Uint64("deadlineLimit", deadlineLimit))
+	Cache.StoreDeadlineLimit(deadlineLimit)
+}
+
+// dynamicDeadlineLimit yields the deadline the pool's capacity (in TB) undercuts
+// Cfg.SubmissionsPerRound times per round on average
+func dynamicDeadlineLimit(netDiff, poolCap float64) uint64 {
+	if poolCap <= 0 || netDiff <= 0 {
+		return Cfg.DeadlineLimit
+	}
+	deadlineLimit := uint64(math.Ceil(Cfg.SubmissionsPerRound * 240.0 * netDiff / poolCap))
+	if Cfg.DeadlineLimit != 0 && deadlineLimit > Cfg.DeadlineLimit {
+		return Cfg.DeadlineLimit
+	}
+	return deadlineLimit
+}
+
+// switchBlock replaces the current block by the one of another fork. Mining goes on from
+// the cache right away, the db follows once it's available.
+func (modelx *Modelx) switchBlock(baseTarget uint64, genSig string, height uint64) error {
+	genSigBytes, err := burstmath.DecodeGeneratorSignature(genSig)
 	if err != nil {
 		return err
 	}
@@ -561,23 +746,17 @@ func (modelx *Modelx) switchBlock(baseTarget uint64, genSig string, height uint6
 		Scoop:                    burstmath.CalcScoop(height, genSigBytes),
 		GenerationSignature:      genSig,
 		GenerationSignatureBytes: genSigBytes,
-		Created:                  time.Now(),
+		Created:                  modelx.roundStart(height),
 	}
 
 	Cache.StoreRoundInfo(newBlock)
 
+	var minerIDs []uint64
 	Cache.MinerRange(func(_, value interface{}) bool {
 		miner := value.(*Miner)
 
 		miner.Lock()
-		_, err = modelx.db.Exec(
-			"DELETE nonce_submission WHERE miner_id = ? AND block_height = ?",
-			miner.ID, height)
-		if err != nil {
-			Logger.Error("failed to delete nonce submission", zap.Error(err))
-			miner.Unlock()
-			return true
-		}
+		minerIDs = append(minerIDs, miner.ID)
 		miner.removeDeadlineParams(height)
 		miner.Unlock()
 
@@ -586,7 +765,53 @@ func (modelx *Modelx) switchBlock(baseTarget uint64, genSig string, height uint6
 
 	Cache.StoreMiningInfo(&newBlock)
 
-	return nil
+	// queued submissions of the old fork must not be written after the delete
+	return modelx.nonceWriter.discard(height, func() error {
+		modelx.missingBlocksMu.Lock()
+		defer modelx.missingBlocksMu.Unlock()
+
+		// a block that isn't stored yet has no submissions either
+		if blockArgs, missing := modelx.missingBlocks[height]; missing {
+			blockArgs[1], blockArgs[2], blockArgs[3] = baseTarget, newBlock.Scoop, genSig
+			return nil
+		}
+
+		blockSwitch := &blockSwitch{baseTarget: baseTarget, genSig: genSig, minerIDs: minerIDs}
+		if queued, exists := modelx.missingSwitches[height]; exists {
+			blockSwitch.minerIDs = append(blockSwitch.minerIDs, queued.minerIDs...)
+		}
+		// not retried, the next block would be held up
+		err := modelx.storeSwitch(height, blockSwitch, func(_, query string, args ...interface{}) error {
+			return modelx.health.execOnce(query, args...)
+		})
+		if err != nil && modelx.health.status().Degraded {
+			Logger.Error("storing block switch failed, retrying before submissions are written",
+				zap.Uint64("height", height), zap.Error(err))
+			modelx.missingSwitches[height] = blockSwitch
+			return nil
+		}
+		if err == nil {
+			delete(modelx.missingSwitches, height)
+		}
+		return err
+	})
+}
+
+// storeSwitch replaces the block at height and deletes the submissions on the old fork
+func (modelx *Modelx) storeSwitch(height uint64, blockSwitch *blockSwitch,
+	exec func(op, query string, args ...interface{}) error) error {
+	err := exec("switch block", "UPDATE block SET generation_signature = ?, base_target = ? WHERE height = ?",
+		blockSwitch.genSig, blockSwitch.baseTarget, height)
+	if err != nil || len(blockSwitch.minerIDs) == 0 {
+		return err
+	}
+
+	query, args, err := sqlx.In("DELETE FROM nonce_submission WHERE block_height = ? AND miner_id IN (?)",
+		height, blockSwitch.minerIDs)
+	if err != nil {
+		return err
+	}
+	return exec("delete nonce submissions of old fork", query, args...)
 }
 
 func (modelx *Modelx) createMiner(accountID uint64) (*Miner, error) {
@@ -607,20 +832,34 @@ func (modelx *Modelx) createMiner(accountID uint64) (*Miner, error) {
 		return nil, err
 	}
 
-	accountSQL := "INSERT IGNORE INTO account (id, address, name) VALUES (?, ?, ?)"
+	accountSQL := modelx.db.InsertIgnore("account", "(id, address, name) VALUES (?, ?, ?)")
 	_, err = tx.Exec(accountSQL, accountID, address, name)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	minerSQL := "INSERT IGNORE INTO miner (id) VALUES (?)"
+	minerSQL := modelx.db.InsertIgnore("miner", "(id) VALUES (?)")
 	_, err = tx.Exec(minerSQL, accountID)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	err = modelx.restoreSweptBalance(tx, accountID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// the account might still be there with a balance waiting to be swept
+	var pending int64
+	err = tx.QueryRow("SELECT pending FROM account WHERE id = ?", accountID).Scan(&pending)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -630,6 +869,7 @@ func (modelx *Modelx) createMiner(accountID uint64) (*Miner, error) {
 		ID:              accountID,
 		Address:         address,
 		Name:            name,
+		Pending:         pending,
 		DeadlinesParams: make(map[uint64]*DeadlineParams)}, nil
 }
 
@@ -692,10 +932,53 @@ func (miner *Miner) CurrentDeadline() uint64 {
 	return miner.CurrentDeadlineParams.Deadline
 }
 
+// AddRoundSubmit counts an accepted submission of the round at height, it fails
+// if the miner already reached maxSubmits (0 = unlimited) in that round
+func (miner *Miner) AddRoundSubmit(height uint64, maxSubmits int) bool {
+	miner.Lock()
+	defer miner.Unlock()
+	if miner.submitsHeight != height {
+		miner.submitsHeight = height
+		miner.roundSubmits = 0
+		miner.rejectedSubmits = 0
+	}
+	if maxSubmits != 0 && miner.roundSubmits >= maxSubmits {
+		miner.rejectedSubmits++
+		return false
+	}
+	miner.roundSubmits++
+	miner.lastSubmit = time.Now()
+	return true
+}
+
+// RoundSubmits yields the accepted and rejected submissions of the round at height
+func (miner *Miner) RoundSubmits(height uint64) (int, int) {
+	if miner.submitsHeight != height {
+		return 0, 0
+	}
+	return miner.roundSubmits, miner.rejectedSubmits
+}
+
 func (miner *Miner) CalculateEEPS() float64 {
 	return eeps(len(miner.DeadlinesParams), miner.WeightedDeadlineSum)
 }
 
+// TargetDeadline yields a deadline the miner's best deadline exceeds only with a probability
+// of Cfg.TargetDeadlineMissRate. Deadlines are exponentially distributed with a mean
+// derived from the miner's EEPS. 0 means there is not enough data for an estimation.
+func (miner *Miner) TargetDeadline(baseTarget uint64) uint64 {
+	nConf := len(miner.DeadlinesParams)
+	if Cfg.TargetDeadlineMissRate == 0 || nConf < Cfg.NMin || baseTarget == 0 {
+		return 0
+	}
+	eeps := miner.CalculateEEPS()
+	if eeps <= 0 {
+		return 0
+	}
+	mean := 240.0 * float64(burstmath.GenesisBaseTarget) / (float64(baseTarget) * eeps)
+	return uint64(math.Ceil(mean * math.Log(1/Cfg.TargetDeadlineMissRate)))
+}
+
 func (modelx *Modelx) UpdateOrCreateNonceSubmission(miner *Miner, height, deadline, nonce, baseTarget uint64,
 	genSig string) error {
 	miner.dbMu.Lock()
@@ -706,14 +989,13 @@ func (modelx *Modelx) UpdateOrCreateNonceSubmission(miner *Miner, height, deadli
 			return nil
 		}
 
-		sql := "UPDATE nonce_submission SET deadline = ?, nonce = ? WHERE miner_id = ? AND block_height = ?"
-		_, err := modelx.db.Exec(sql, deadline, nonce, miner.ID, height)
-		if err != nil {
+		if err := modelx.addNonceSubmission(miner.ID, height, deadline, nonce); err != nil {
 			return err
 		}
 
+		// late submissions update the closed block's deadline in the EEPS as well
 		miner.Lock()
-		miner.CurrentDeadlineParams.Deadline = deadline
+		miner.setDeadline(height, deadline, miner.CurrentDeadlineParams.BaseTarget)
 		miner.Unlock()
 
 		return nil
@@ -729,9 +1011,7 @@ func (modelx *Modelx) UpdateOrCreateNonceSubmission(miner *Miner, height, deadli
 			return nil
 		}
 
-		sql := "UPDATE nonce_submission SET deadline = ?, nonce = ? WHERE miner_id = ? AND block_height = ?"
-		_, err := modelx.db.Exec(sql, deadline, nonce, miner.ID, height)
-		if err != nil {
+		if err := modelx.addNonceSubmission(miner.ID, height, deadline, nonce); err != nil {
 			return err
 		}
 
@@ -750,9 +1030,7 @@ func (modelx *Modelx) UpdateOrCreateNonceSubmission(miner *Miner, height, deadli
 		modelx.MaybeSwitchOrNewBlock(baseTarget, genSig, height)
 	}
 
-	sql := "INSERT INTO nonce_submission (miner_id, block_height, deadline, nonce) VALUES (?, ?, ?, ?)"
-	_, err := modelx.db.Exec(sql, miner.ID, height, deadline, nonce)
-	if err != nil {
+	if err := modelx.addNonceSubmission(miner.ID, height, deadline, nonce); err != nil {
 		return err
 	}
 
@@ -772,14 +1050,70 @@ func (modelx *Modelx) UpdateOrCreateNonceSubmission(miner *Miner, height, deadli
 	return nil
 }
 
-func (modelx *Modelx) UpdateBestSubmission(minerID, height uint64) {
+func (modelx *Modelx) UpdateBestSubmission(minerID, height uint64) error {
+	if err := modelx.nonceWriter.Flush(); err != nil {
+		return err
+	}
+
 	sql := `UPDATE block SET best_nonce_submission_id =
                   (SELECT id FROM nonce_submission WHERE block_height = block.height AND miner_id = ?)
                   WHERE height = ? `
-	modelx.db.MustExec(sql, minerID, height)
+	args := []interface{}{minerID, height}
+	if Cfg.LeaderElection.Enabled {
+		// another instance sharing the db might have stored a better one
+		sql += `AND (best_nonce_submission_id IS NULL OR
+                    (SELECT deadline FROM nonce_submission WHERE id = block.best_nonce_submission_id) >
+                    (SELECT deadline FROM nonce_submission WHERE block_height = block.height AND miner_id = ?))`
+		args = append(args, minerID)
+	}
+	return modelx.health.exec("update best submission", sql, args...)
+}
+
+// StoreSubmitOutcome records how submitting the best nonce of a block went. The
+// fastest wallet that accepted the nonce is stored, latency is in ms.
+func (modelx *Modelx) StoreSubmitOutcome(height uint64, results []wallethandler.SubmitNonceResult) {
+	var submitted, mismatch bool
+	var best *wallethandler.SubmitNonceResult
+	for i, result := range results {
+		mismatch = mismatch || result.Mismatch
+		if result.Err != nil {
+			if !submitted && (best == nil || result.Latency < best.Latency) {
+				best = &results[i]
+			}
+			continue
+		}
+		if !submitted || result.Latency < best.Latency {
+			best = &results[i]
+		}
+		submitted = true
+	}
+
+	var wallet sql.NullString
+	var latency sql.NullInt64
+	if best != nil {
+		wallet = sql.NullString{String: best.URL, Valid: true}
+		latency = sql.NullInt64{Int64: int64(best.Latency / time.Millisecond), Valid: true}
+	}
+
+	err := modelx.health.exec("store submit outcome", `UPDATE block SET nonce_submitted = ?, submit_wallet = ?,
+                submit_latency = ?, deadline_mismatch = ? WHERE height = ?`,
+		submitted, wallet, latency, mismatch, height)
+	if err != nil {
+		Logger.Error("storing submit outcome", zap.Uint64("height", height), zap.Error(err))
+	}
 }
 
 func (modelx *Modelx) RewardBlocks() {
+	modelx.verifyBlocks(true)
+}
+
+// ReportBlocks marks won blocks like RewardBlocks, but doesn't credit any rewards.
+// This is used if the miners are paid by someone else (e.g. an upstream pool).
+func (modelx *Modelx) ReportBlocks() {
+	modelx.verifyBlocks(false)
+}
+
+func (modelx *Modelx) verifyBlocks(reward bool) {
 	currentBlock := Cache.CurrentBlock()
 
 	type BlockWonInfo struct {
@@ -792,14 +1126,24 @@ func (modelx *Modelx) RewardBlocks() {
 	payoutDelay := time.Now().Add(-time.Duration(Cfg.PayoutDelay) * time.Second)
 	payoutHeightDelay := currentBlock.Height - Cfg.BlockHeightPayoutDelay
 
-	modelx.db.MustExec(`UPDATE block SET best_nonce_submission_id =
+	// the winners and shares must be determined on all submissions
+	if err := modelx.nonceWriter.Flush(); err != nil {
+		Logger.Error("flushing nonce submissions failed", zap.Error(err))
+		return
+	}
+
+	err := modelx.health.exec("update best submissions", `UPDATE block SET best_nonce_submission_id =
                               (SELECT id FROM nonce_submission
                                WHERE nonce_submission.block_height = block.height ORDER BY deadline ASC LIMIT 1)
                             WHERE winner_verified = 0 AND height <= ? AND created <= ?`,
 		payoutHeightDelay,
 		payoutDelay)
+	if err != nil {
+		Logger.Error("failed to update best submissions", zap.Error(err))
+		return
+	}
 
-	modelx.db.MustExec(`UPDATE block SET winner_verified = 1
+	err = modelx.health.exec("verify blocks without submissions", `UPDATE block SET winner_verified = 1
                             WHERE
                               best_nonce_submission_id IS NULL and
                               winner_verified = 0 AND
@@ -807,6 +1151,10 @@ func (modelx *Modelx) RewardBlocks() {
                               created <= ?`,
 		payoutHeightDelay,
 		payoutDelay)
+	if err != nil {
+		Logger.Error("failed to verify blocks without submissions", zap.Error(err))
+		return
+	}
 
 	var blockWonInfos []BlockWonInfo
 	sql := `SELECT
@@ -823,7 +1171,7 @@ func (modelx *Modelx) RewardBlocks() {
                   created <= ?
                   ORDER BY height ASC`
 
-	err := modelx.db.Select(&blockWonInfos, sql, payoutHeightDelay, payoutDelay)
+	err = modelx.db.Select(&blockWonInfos, sql, payoutHeightDelay, payoutDelay)
 	if err != nil {
 		Logger.Error("failed to fetch blocks without winner verified", zap.Error(err))
 		return
@@ -832,7 +1180,7 @@ func (modelx *Modelx) RewardBlocks() {
 	// set dynamic min payout
 	// for this we need to check transactions
 	// since the block that has not been checked
-	if len(blockWonInfos) > 0 {
+	if reward && len(blockWonInfos) > 0 {
 		earliestCreated := blockWonInfos[0].Created
 		msgOf, err := modelx.walletHandler.GetIncomingMsgsSince(earliestCreated.Add(-time.Second * 30))
 		if err != nil {
@@ -849,12 +1197,40 @@ func (modelx *Modelx) RewardBlocks() {
 			Logger.Error("failed to determine if block was won", zap.Error(err))
 			continue
 		}
-		if wonBlock {
-			modelx.rewardBlock(blockInfo)
+		if wonBlock && reward {
+			err = modelx.rewardBlock(blockInfo)
+		} else if wonBlock {
+			Logger.Info("block won", zap.Uint64("height", blockInfo.Height),
+				zap.Uint64("winner", blockInfo.Generator))
+			err = modelx.health.exec("verify won block",
+				"UPDATE block SET winner_verified = 1, reward = ?, winner_id = ? WHERE height = ?",
+				blockInfo.BlockReward*100000000+blockInfo.TotalFeeNQT, blockInfo.Generator, blockInfo.Height)
 		} else {
-			modelx.db.MustExec("UPDATE block SET winner_verified = 1 WHERE height = ?",
+			err = modelx.health.exec("verify block", "UPDATE block SET winner_verified = 1 WHERE height = ?",
 				blockWonInfo.Height)
 		}
+		if err != nil {
+			Logger.Error("failed to verify block", zap.Uint64("height", blockWonInfo.Height), zap.Error(err))
+			continue
+		}
+
+		// only once the block is stored as verified, so that it's announced once and
+		// subscribers read it from the db
+		if wonBlock {
+			modelx.events.Publish(events.BlockWon{
+				Height:   blockInfo.Height,
+				WinnerID: blockInfo.Generator,
+				Reward:   blockInfo.BlockReward*100000000 + blockInfo.TotalFeeNQT})
+		} else {
+			modelx.events.Publish(events.BlockLost{Height: blockWonInfo.Height})
+		}
+	}
+
+	// shares are only needed until the block's rewards are paid
+	err = modelx.health.exec("delete shares", `DELETE FROM block_share
+                            WHERE block_height IN (SELECT height FROM block WHERE winner_verified = 1)`)
+	if err != nil {
+		Logger.Error("failed to delete shares of verified blocks", zap.Error(err))
 	}
 }
 
@@ -867,7 +1243,7 @@ func (modelx *Modelx) GetEEPSsOnBlock(height uint64, writeToDb bool) (map[uint64
 
 	eepsSQL := `SELECT
                   miner_id "miner_id",
-                  CAST(SUM(deadline * block.base_target) AS DOUBLE) "weighted_deadline_sum",
+                  ` + modelx.db.Float("SUM(deadline * block.base_target)") + ` "weighted_deadline_sum",
                   COUNT(deadline) "confirmed_deadlines"
                 FROM nonce_submission
                   JOIN (SELECT base_target, height
@@ -886,8 +1262,11 @@ func (modelx *Modelx) GetEEPSsOnBlock(height uint64, writeToDb bool) (map[uint64
 	for _, args := range eepsArgs {
 		eeps := eeps(args.NConf, args.WeightedDeadlineSum)
 		if writeToDb {
-			modelx.db.MustExec("UPDATE miner SET capacity = ? WHERE id = ?",
+			err := modelx.health.exec("update capacity", "UPDATE miner SET capacity = ? WHERE id = ?",
 				int32(eeps*1000.0), args.MinerID)
+			if err != nil {
+				Logger.Error("failed to store capacity", zap.Uint64("minerID", args.MinerID), zap.Error(err))
+			}
 		}
 		eepsSum += eeps
 		eepsOf[args.MinerID] = eeps
@@ -897,12 +1276,27 @@ func (modelx *Modelx) GetEEPSsOnBlock(height uint64, writeToDb bool) (map[uint64
 }
 
 func (modelx *Modelx) GetSharesOnBlock(height uint64) (map[uint64]float64, error) {
-	eepsOf, eepsSum, err := modelx.GetEEPSsOnBlock(height, true)
-
+	eepsOf, err := modelx.storedEEPSs(height)
 	if err != nil {
 		return nil, err
 	}
 
+	var eepsSum float64
+	if len(eepsOf) == 0 {
+		// blocks closed while the pool was down have no stored shares
+		eepsOf, eepsSum, err = modelx.GetEEPSsOnBlock(height, true)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if Cfg.VerifyShares {
+			modelx.verifyShares(height, eepsOf)
+		}
+		for _, eeps := range eepsOf {
+			eepsSum += eeps
+		}
+	}
+
 	shareOf := make(map[uint64]float64)
 	if eepsSum != 0.0 {
 		for i := range eepsOf {
@@ -913,7 +1307,7 @@ func (modelx *Modelx) GetSharesOnBlock(height uint64) (map[uint64]float64, error
 	return shareOf, nil
 }
 
-func (modelx *Modelx) rewardBlock(blockInfo *wallet.GetBlockReply) {
+func (modelx *Modelx) rewardBlock(blockInfo *wallet.GetBlockReply) error {
 	shareInPlanckOf := make(map[uint64]int64)
 	totalReward := blockInfo.BlockReward*100000000 + blockInfo.TotalFeeNQT
 	reward := totalReward
@@ -945,22 +1339,15 @@ func (modelx *Modelx) rewardBlock(blockInfo *wallet.GetBlockReply) {
 	tx, err := modelx.db.Begin()
 	if err != nil {
 		Logger.Error("beginning rewardBlock transaction failed", zap.Error(err))
-		return
-	}
-
-	stmt, err := tx.Prepare("UPDATE account SET pending = pending + ? WHERE id = ?")
-	if err != nil {
-		Logger.Error("failed to prepare update pending stmt", zap.Error(err))
-		tx.Rollback()
-		return
+		return err
 	}
 
 	for accountID, shareInPlanck := range shareInPlanckOf {
-		_, err := stmt.Exec(shareInPlanck, accountID)
+		_, err := tx.Exec("UPDATE account SET pending = pending + ? WHERE id = ?", shareInPlanck, accountID)
 		if err != nil {
 			Logger.Error("increasing pending failed", zap.Error(err))
 			tx.Rollback()
-			return
+			return err
 		}
 	}
 
@@ -968,12 +1355,12 @@ func (modelx *Modelx) rewardBlock(blockInfo *wallet.GetBlockReply) {
 	if _, err := tx.Exec(sql, totalReward, blockInfo.Generator, blockInfo.Height); err != nil {
 		Logger.Error("udpate won block failed", zap.Error(err))
 		tx.Rollback()
-		return
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
 		Logger.Error("rewardBlock transaction failed", zap.Error(err))
-		return
+		return err
 	}
 
 	// udpate cache, separate loop, because we don't want to lock inside the transaction
@@ -984,14 +1371,15 @@ func (modelx *Modelx) rewardBlock(blockInfo *wallet.GetBlockReply) {
 			cachedMiner.Unlock()
 		}
 	}
+	return nil
 }
 
 func (modelx *Modelx) validateTransactions() {
 	var txsToValidate []uint64
-	err := modelx.db.Select(&txsToValidate, `SELECT transaction_id FROM transaction WHERE
+	err := modelx.db.Select(&txsToValidate, `SELECT transaction_id FROM `+"`transaction`"+` WHERE
             block_height IS NULL AND
-            created < NOW() - INTERVAL 1 DAY AND
-            transaction_id IS NOT NULL`)
+            created < ? AND
+            transaction_id IS NOT NULL`, time.Now().Add(-24*time.Hour))
 	if err != nil {
 		Logger.Error("get unconfirmed transactions")
 		return
@@ -1004,24 +1392,42 @@ func (modelx *Modelx) validateTransactions() {
 		}
 		if err != nil {
 			Logger.Warn("tx did not make it into blockchain", zap.Uint64("tx_id", tx))
-			modelx.db.MustExec("UPDATE transaction SET transaction_id = NULL WHERE transaction_id = ?",
-				tx)
+			err := modelx.health.exec("reset transaction",
+				"UPDATE `transaction` SET transaction_id = NULL, sender = NULL WHERE transaction_id = ?", tx)
+			if err != nil {
+				Logger.Error("failed to reset transaction", zap.Uint64("tx_id", tx), zap.Error(err))
+			}
 			continue
 		}
-		modelx.db.MustExec(`
-                    IF EXISTS(SELECT 1 FROM block WHERE height = ?) THEN
-                        UPDATE transaction SET block_height = ? WHERE transaction_id = ?;
-                    ELSE
-                        DELETE FROM transaction WHERE transaction_id = ?;
-                    END IF;`, txInfo.Height, txInfo.Height, tx, tx)
+
+		// transactions of blocks that aren't kept anymore go too
+		var blockExists bool
+		if err := modelx.db.Get(&blockExists, "SELECT COUNT(*) > 0 FROM block WHERE height = ?",
+			txInfo.Height); err != nil {
+			Logger.Error("checking block of transaction failed", zap.Uint64("tx_id", tx), zap.Error(err))
+			continue
+		}
+		if blockExists {
+			err = modelx.health.exec("confirm transaction",
+				"UPDATE `transaction` SET block_height = ? WHERE transaction_id = ?", txInfo.Height, tx)
+			if err == nil {
+				modelx.events.Publish(events.PayoutConfirmed{TransactionID: tx, Height: txInfo.Height})
+			}
+		} else {
+			err = modelx.health.exec("delete transaction", "DELETE FROM `transaction` WHERE transaction_id = ?", tx)
+		}
+		if err != nil {
+			Logger.Error("failed to validate transaction", zap.Uint64("tx_id", tx), zap.Error(err))
+		}
 	}
 }
 
 func (modelx *Modelx) sendMoney() {
 	var txs []uint64
-	err := modelx.db.Select(&txs, `SELECT id FROM transaction WHERE
+	err := modelx.db.Select(&txs, `SELECT id FROM `+"`transaction`"+` WHERE
             block_height IS NULL AND
-            transaction_id IS NULL`)
+            transaction_id IS NULL AND
+            sender IS NULL`)
 	if err != nil {
 		Logger.Error("fetch transaction ids", zap.Error(err))
 		return
@@ -1040,6 +1446,18 @@ func (modelx *Modelx) sendMoney() {
 			Logger.Error("fetch recips and amounts", zap.Error(err))
 			continue
 		}
+
+		// another instance might have taken over meanwhile, transactions this one
+		// claimed aren't sent by it
+		claimed, err := modelx.claimTransaction(tx)
+		if err != nil {
+			Logger.Error("claiming transaction failed, stopping payouts", zap.Uint64("id", tx), zap.Error(err))
+			return
+		}
+		if !claimed {
+			continue
+		}
+
 		var txID uint64
 		if len(recipToAmounts) == 1 {
 			txID, err = modelx.walletHandler.SendPayment(recipToAmounts[0].Recip,
@@ -1053,6 +1471,11 @@ func (modelx *Modelx) sendMoney() {
 		}
 		if err != nil {
 			Logger.Error("send payment", zap.Error(err))
+			err = modelx.health.exec("release transaction",
+				"UPDATE `transaction` SET sender = NULL WHERE id = ? AND transaction_id IS NULL", tx)
+			if err != nil {
+				Logger.Error("failed to release transaction", zap.Uint64("id", tx), zap.Error(err))
+			}
 			continue
 		}
 		// TODO: if this fails then there is the risk of a double payout
@@ -1062,11 +1485,48 @@ func (modelx *Modelx) sendMoney() {
 		// 3. check for recent transactions of the account in the blockchain, where we did not save any
 		//    transaction_ids
 		// 4. analyse those transactions and update the transation_id field if needed
-		modelx.db.MustExec(`UPDATE transaction SET transaction_id = ?,
-                                                           created = NOW() WHERE id = ?`, txID, tx)
+		err = modelx.health.exec("store transaction id",
+			"UPDATE `transaction` SET transaction_id = ?, created = ? WHERE id = ?", txID, time.Now(), tx)
+		if err != nil {
+			// the payment is sent anyway, paying more without recording it risks double payouts
+			Logger.Error("failed to store sent transaction, stopping payouts", zap.Uint64("id", tx),
+				zap.Uint64("tx_id", txID), zap.Error(err))
+			return
+		}
+
+		var amount int64
+		for _, ra := range recipToAmounts {
+			amount += ra.Amount
+		}
+		modelx.events.Publish(events.PayoutCreated{TransactionID: txID, Recipients: len(recipToAmounts),
+			Amount: amount})
 	}
 }
 
+// claimTransaction marks the transaction as sent by this instance, if it still leads and
+// no instance claimed it before
+func (modelx *Modelx) claimTransaction(id uint64) (bool, error) {
+	tx, err := modelx.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	if err := modelx.checkLeader(tx); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	res, err := tx.Exec("UPDATE `transaction` SET sender = ? WHERE id = ? AND transaction_id IS NULL AND sender IS NULL",
+		modelx.instanceID(), id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if claimed, err := res.RowsAffected(); err != nil || claimed != 1 {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
 func (modelx *Modelx) Payout() {
 	// TODO: probably we should validate transactions first, then
 	// delete transactions and increase pendings so that
@@ -1084,10 +1544,11 @@ func (modelx *Modelx) createTransactions() {
                   payout_interval "payout_interval"
                 FROM account WHERE
                   (min_payout_value IS NOT NULL AND pending >= min_payout_value + ?) OR
-                  (next_payout_date IS NOT NULL AND next_payout_date <= NOW() AND pending >= ?) OR
+                  (next_payout_date IS NOT NULL AND next_payout_date <= ? AND pending >= ?) OR
                   (min_payout_value IS NULL AND next_payout_date IS NULL AND pending >= ?)`
 	err := modelx.db.Select(&pendingInfos, sql,
 		Cfg.MinerTxFee,
+		time.Now(),
 		Cfg.MinerTxFee,
 		Cfg.MinimumPayout+Cfg.MinerTxFee)
 	if err != nil {
@@ -1104,25 +1565,18 @@ func (modelx *Modelx) createTransactions() {
 		Logger.Error("begin payBlock transaction", zap.Error(err))
 		return
 	}
-
-	pendingUpdateStmt, err := tx.Prepare("UPDATE account SET pending = pending - ? WHERE id = ?")
-	if err != nil {
-		Logger.Error("prepare pending update stmt", zap.Error(err))
-		return
-	}
-	payoutIntervalUpdateStmt, err := tx.Prepare("UPDATE account SET next_payout_date = ? WHERE id = ?")
-	if err != nil {
-		Logger.Error("prepare payout interval update stmt", zap.Error(err))
+	if err := modelx.checkLeader(tx); err != nil {
+		Logger.Error("not leading anymore, not creating transactions", zap.Error(err))
+		tx.Rollback()
 		return
 	}
 
-	newTransactionStmt, err := tx.Prepare(`INSERT INTO transaction_recipient
+	pendingUpdateSQL := "UPDATE account SET pending = pending - ? WHERE id = ?"
+	payoutIntervalUpdateSQL := "UPDATE account SET next_payout_date = ? WHERE id = ?"
+	forcedPayoutClearSQL := "UPDATE account SET next_payout_date = NULL WHERE id = ? AND next_payout_date IS NOT NULL"
+	newTransactionSQL := `INSERT INTO transaction_recipient
             (transaction_id, recipient_id, amount)
-            VALUES(?, ?, ?)`)
-	if err != nil {
-		Logger.Error("prepare new transaction stmt", zap.Error(err))
-		return
-	}
+            VALUES(?, ?, ?)`
 
 	var numberTxsToCreate int
 	if len(pendingInfos)%wallet.MaxMultiRecipients == 0 {
@@ -1133,23 +1587,17 @@ func (modelx *Modelx) createTransactions() {
 
 	var dbTxIDs []int64
 	for i := 0; i < numberTxsToCreate; i++ {
-		res, err := tx.Exec("INSERT INTO transaction (id) VALUES(NULL)")
+		id, err := tx.InsertID("INSERT INTO `transaction` (block_height) VALUES (NULL)")
 		if err != nil {
 			tx.Rollback()
 			Logger.Error("create transaction", zap.Error(err))
 			return
 		}
-		id, err := res.LastInsertId()
-		if err != nil {
-			tx.Rollback()
-			Logger.Error("get db tx id", zap.Error(err))
-			return
-		}
 		dbTxIDs = append(dbTxIDs, id)
 	}
 
 	for i, pendingInfo := range pendingInfos {
-		_, err := pendingUpdateStmt.Exec(pendingInfo.Pending, pendingInfo.ID)
+		_, err := tx.Exec(pendingUpdateSQL, pendingInfo.Pending, pendingInfo.ID)
 		if err != nil {
 			Logger.Error("update pending", zap.Error(err))
 			tx.Rollback()
@@ -1159,24 +1607,26 @@ func (modelx *Modelx) createTransactions() {
 		if pendingInfo.PayoutInterval.Valid {
 			switch pendingInfo.PayoutInterval.String {
 			case "weekly":
-				_, err = payoutIntervalUpdateStmt.Exec(time.Now().AddDate(0, 0, 7),
+				_, err = tx.Exec(payoutIntervalUpdateSQL, time.Now().AddDate(0, 0, 7),
 					pendingInfo.ID)
 			case "daily":
-				_, err = payoutIntervalUpdateStmt.Exec(time.Now().AddDate(0, 0, 1),
+				_, err = tx.Exec(payoutIntervalUpdateSQL, time.Now().AddDate(0, 0, 1),
 					pendingInfo.ID)
 			case "now":
-				_, err = payoutIntervalUpdateStmt.Exec(nil, pendingInfo.ID)
-			}
-
-			if err != nil {
-				Logger.Error("update next_payout_date", zap.Error(err))
-				tx.Rollback()
-				return
+				_, err = tx.Exec(payoutIntervalUpdateSQL, nil, pendingInfo.ID)
 			}
+		} else {
+			// a forced final payout mustn't stay due
+			_, err = tx.Exec(forcedPayoutClearSQL, pendingInfo.ID)
+		}
+		if err != nil {
+			Logger.Error("update next_payout_date", zap.Error(err))
+			tx.Rollback()
+			return
 		}
 
 		// after 64, we need to go to the next tx
-		_, err = newTransactionStmt.Exec(dbTxIDs[int(i/wallet.MaxMultiRecipients)],
+		_, err = tx.Exec(newTransactionSQL, dbTxIDs[int(i/wallet.MaxMultiRecipients)],
 			pendingInfo.ID, pendingInfo.Pending-Cfg.MinerTxFee)
 		if err != nil {
 			Logger.Error("create transaction recipient", zap.Error(err))
@@ -1222,7 +1672,11 @@ func (modelx *Modelx) GetRecentlyWonBlocks() []WonBlock {
                 WHERE NOT winner_id IS NULL ORDER BY height DESC LIMIT 100`
 
 	var wonBlocks []WonBlock
-	if err := modelx.db.Select(&wonBlocks, sql); err != nil {
+	err := modelx.readQuery(func(db storage.Querier) error {
+		wonBlocks = nil
+		return db.Select(&wonBlocks, sql)
+	})
+	if err != nil {
 		Logger.Error("fetching recentlyWonBlocks from db failed", zap.Error(err))
 	}
 
@@ -1241,6 +1695,25 @@ func (modelx *Modelx) getGenerationTime(height uint64) (int32, error) {
 	return modelx.walletHandler.GetGenerationTime(height)
 }
 
+type rewardRecipAssign struct {
+	AccountID   uint64 `db:"account_id"`
+	PrevRecipID uint64 `db:"prev_recip_id"`
+	RecipID     uint64 `db:"recip_id"`
+	FromHeight  uint64 `db:"from_height"`
+}
+
+func (ra *rewardRecipAssign) rewardRecipient() RewardRecipient {
+	return RewardRecipient{
+		Assigned:   ra.RecipID == Cfg.PoolPublicID,
+		PrevPool:   ra.PrevRecipID == Cfg.PoolPublicID,
+		FromHeight: ra.FromHeight}
+}
+
+const rewardRecipAssignSQL = `SELECT CAST(account_id AS UNSIGNED) AS account_id,
+                  CAST(prev_recip_id AS UNSIGNED) AS prev_recip_id,
+                  CAST(recip_id AS UNSIGNED) AS recip_id, from_height
+                  FROM reward_recip_assign`
+
 func (modelx *Modelx) cacheRewardRecipients() {
 	if !modelx.isConnectedToWalletDB() {
 		recips, err := modelx.walletHandler.GetRewardRecipients()
@@ -1248,32 +1721,101 @@ func (modelx *Modelx) cacheRewardRecipients() {
 			Logger.Error("failed to get rewrad recipients", zap.Error(err))
 			return
 		}
-		Cache.StoreRewardRecipients(recips)
+
+		// the wallet lists pending assignments too, so we can only rely on the ones
+		// that were found to be effective already, the others are looked up on demand
+		rewardRecipients := make(map[uint64]RewardRecipient)
+		for accountID := range recips {
+			rr, cached := Cache.GetRewardRecipient(accountID)
+			if !cached || !rr.Assigned || rr.FromHeight == unknownHeight {
+				rr = RewardRecipient{Assigned: true, FromHeight: unknownHeight}
+			}
+			rewardRecipients[accountID] = rr
+		}
+		Cache.StoreRewardRecipients(rewardRecipients)
 		return
 	}
 
-	var validAccoundIDs []uint64
-	sql := `SELECT CAST(account_id AS UNSIGNED)
-                  FROM reward_recip_assign
-                  WHERE recip_id = CAST(? AS SIGNED) AND latest = 1`
-	err := modelx.walletDB.Select(&validAccoundIDs, sql, Cfg.PoolPublicID)
+	// accounts that are about to leave the pool still mine for us until their switch
+	// takes effect
+	var assigns []rewardRecipAssign
+	err := modelx.walletDB.Select(&assigns, rewardRecipAssignSQL+`
+                  WHERE latest = 1 AND (recip_id = CAST(? AS SIGNED) OR
+                        (prev_recip_id = CAST(? AS SIGNED) AND from_height > ?))`,
+		Cfg.PoolPublicID, Cfg.PoolPublicID, Cache.GetRoundInfo().Height)
 	if err != nil {
 		Logger.Error("failed caching reward recipients", zap.Error(err))
 		return
 	}
 
-	recips := make(map[uint64]bool)
-	for _, accoundID := range validAccoundIDs {
-		recips[accoundID] = true
+	rewardRecipients := make(map[uint64]RewardRecipient)
+	for _, assign := range assigns {
+		rewardRecipients[assign.AccountID] = assign.rewardRecipient()
+	}
+	Cache.StoreRewardRecipients(rewardRecipients)
+}
+
+// IsRewardRecipient checks if the pool receives the rewards of an account at height.
+// Accounts the cache can't tell about are looked up and the result is cached, negative
+// results until the reward recipients get refreshed with the next block. Lookups are
+// throttled per ip of the submission and in total, ErrLookupThrottled is returned then.
+func (modelx *Modelx) IsRewardRecipient(accountID, height uint64, ip string) (bool, error) {
+	if isPool, known := Cache.IsRewardRecipient(accountID, height); known {
+		return isPool, nil
+	}
+	if !modelx.lookups.allow(ip, time.Now()) {
+		return false, ErrLookupThrottled
+	}
+
+	rr, _ := Cache.GetRewardRecipient(accountID)
+	if modelx.isConnectedToWalletDB() {
+		var assign rewardRecipAssign
+		err := modelx.walletDB.Get(&assign, rewardRecipAssignSQL+`
+                  WHERE account_id = CAST(? AS SIGNED) AND latest = 1`, accountID)
+		switch err {
+		case nil:
+			rr = assign.rewardRecipient()
+		case sql.ErrNoRows:
+			rr = RewardRecipient{}
+		default:
+			Logger.Error("looking up reward recipient failed", zap.Uint64("accountID", accountID),
+				zap.Error(err))
+			rr.CheckedAt = height
+			rr.CheckedPool = false
+		}
+	} else {
+		recipID, err := modelx.walletHandler.GetRewardRecipient(accountID)
+		if err != nil {
+			Logger.Error("looking up reward recipient failed", zap.Uint64("accountID", accountID),
+				zap.Error(err))
+		}
+		rr.CheckedAt = height
+		rr.CheckedPool = err == nil && recipID == Cfg.PoolPublicID
+
+		// the wallet only yields the effective recipient, if it's us and we are also
+		// assigned the account stays with us
+		if rr.CheckedPool && rr.Assigned {
+			rr.FromHeight = 0
+		}
 	}
-	Cache.StoreRewardRecipients(recips)
+	Cache.StoreLookedUpRewardRecipient(accountID, rr)
+
+	isPool, _ := rr.IsPool(height)
+	return isPool, nil
 }
 
 func (modelx *Modelx) GetAVGNetDiff(n uint) float64 {
+	return modelx.avgNetDiff(n, modelx.readQuery)
+}
+
+// avgNetDiff yields the average net difficulty of the last n blocks read by query
+func (modelx *Modelx) avgNetDiff(n uint, query func(f func(db storage.Querier) error) error) float64 {
 	var netDiff float64
-	err := modelx.db.Get(&netDiff,
-		`SELECT 18325193796 / AVG(blocks.base_target)
+	err := query(func(db storage.Querier) error {
+		return db.Get(&netDiff,
+			`SELECT 18325193796 / AVG(blocks.base_target)
                  FROM (SELECT base_target FROM block ORDER BY height DESC LIMIT ?) blocks`, n)
+	})
 	if err != nil {
 		Logger.Error("failed to get netDiff", zap.Error(err))
 		return 250000.0
@@ -1305,7 +1847,7 @@ func (modelx *Modelx) SetMinPayout(msgOf map[uint64]string) {
 			nextPayoutDate = &tmpTime
 			cost = Cfg.SetWeeklyFee
 
-			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = "weekly"`,
+			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = 'weekly'`,
 				accountID).Scan(&oldMsg)
 			if oldMsg {
 				Logger.Info("processed msg second time", zap.Uint64("account id", accountID))
@@ -1318,7 +1860,7 @@ func (modelx *Modelx) SetMinPayout(msgOf map[uint64]string) {
 			nextPayoutDate = &tmpTime
 			cost = Cfg.SetDailyFee
 
-			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = "daily"`,
+			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = 'daily'`,
 				accountID).Scan(&oldMsg)
 			if oldMsg {
 				Logger.Info("processed msg second time", zap.Uint64("account id", accountID))
@@ -1331,7 +1873,7 @@ func (modelx *Modelx) SetMinPayout(msgOf map[uint64]string) {
 			nextPayoutDate = &tmpTime
 			cost = Cfg.SetNowFee
 
-			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = "now"`,
+			modelx.db.QueryRow(`SELECT 1 FROM account WHERE id = ? AND payout_interval = 'now'`,
 				accountID).Scan(&oldMsg)
 			if oldMsg {
 				Logger.Info("processed msg second time", zap.Uint64("account id", accountID))
@@ -5,13 +5,16 @@ package modelx
 import (
 	"database/sql"
 	"errors"
+	"io/ioutil"
 	"log"
-	"os/exec"
+	"math"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
 	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
 	"github.com/PoC-Consortium/Nogrod/pkg/mocks"
 	"github.com/PoC-Consortium/Nogrod/pkg/wallet"
 
@@ -21,30 +24,32 @@ import (
 )
 
 const (
-	user         = "root"
 	sampleGenSig = "35844ab83e21851b38340cd7e8fc96b8bc139c132759ce3de1fcb616d888f2c9"
 )
 
+// floatSumDelta is the relative error of float sums, which depend on the order the
+// db returns the rows in
+const floatSumDelta = 1e-15
+
 var modelx *Modelx
 var walletHandlerMock mocks.WalletHandler
 
 func init() {
 	LoadConfig()
+	Cfg.NonceWriter.WALDir, _ = ioutil.TempDir("", "wal")
+	Cfg.Snapshot.File = filepath.Join(Cfg.NonceWriter.WALDir, "cache.snapshot")
 
-	cmd := "echo 'DROP DATABASE IF EXISTS testburstpool; CREATE DATABASE testburstpool;' | mysql -u" + user
-	_, err := exec.Command("bash", "-c", cmd).Output()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	cmd = "mysql -u" + user + " testburstpool < testburstpool.sql"
-	_, err = exec.Command("bash", "-c", cmd).Output()
+	db, err := openTestDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	InitCache()
-	modelx = NewModelX(&walletHandlerMock, false)
+	// round starts fall back to the local time
+	walletHandlerMock.On("GetBlockInfo", mock.Anything).Return(nil, errors.New(""))
+	// without a wallet db the reward recipients are fetched from the wallet
+	walletHandlerMock.On("GetRewardRecipients").Return(map[uint64]bool{}, nil)
+	modelx = newModelX(&walletHandlerMock, db)
 }
 
 func TestNewModelX(t *testing.T) {
@@ -138,8 +143,8 @@ func TestGetSharesOnBlock(t *testing.T) {
 	shares, err = modelx.GetSharesOnBlock(493725)
 	if assert.Nil(t, err) {
 		assert.Equal(t, 35, len(shares), "wrong number of entries in shares")
-		assert.Equal(t, 0.062089898438717844, shares[8686227335924170201])
-		assert.Equal(t, 0.012949809323534489, shares[14250239474703782444])
+		assert.InDelta(t, 0.062089898438717844, shares[8686227335924170201], floatSumDelta)
+		assert.InDelta(t, 0.012949809323534489, shares[14250239474703782444], floatSumDelta)
 	}
 }
 
@@ -194,8 +199,17 @@ func TestRewardBlocks(t *testing.T) {
 			BlockReward: 39500000000,
 			TotalFeeNQT: 400000000}, nil)
 
+	won := modelx.events.Subscribe(8, events.BlockWon{})
+	defer modelx.events.Unsubscribe(won)
+
 	modelx.RewardBlocks()
 
+	var wonHeights []uint64
+	for len(won.C) > 0 {
+		wonHeights = append(wonHeights, (<-won.C).(events.BlockWon).Height)
+	}
+	assert.Equal(t, []uint64{491588, 493698}, wonHeights, "won blocks not announced once")
+
 	var winnerVerified bool
 	err := modelx.db.Get(&winnerVerified, "SELECT winner_verified FROM block WHERE height = 493730")
 	if assert.Nil(t, err) {
@@ -245,11 +259,13 @@ func TestRewardBlocks(t *testing.T) {
 		var pending int64
 		err := modelx.db.Get(&pending, "SELECT pending FROM account WHERE id = ?", test.accountID)
 		if assert.Nil(t, err) {
-			assert.Equal(t, test.pending, pending, "updated pending correctly")
+			assert.InDelta(t, test.pending, pending, float64(test.pending)*floatSumDelta,
+				"updated pending correctly")
 
 			// skip pool fee account (not in cache)
 			if miner := Cache.GetMiner(test.accountID); miner != nil {
-				assert.Equal(t, test.pending, miner.Pending, "updated pending correctly (cache)")
+				assert.InDelta(t, test.pending, miner.Pending, float64(test.pending)*floatSumDelta,
+					"updated pending correctly (cache)")
 			}
 		}
 	}
@@ -288,6 +304,46 @@ func TestFirstOrCreateMiner(t *testing.T) {
 	modelx.db.MustExec("DELETE FROM account WHERE id = ?", miner.ID)
 }
 
+func TestIsRewardRecipient(t *testing.T) {
+	walletDB := modelx.walletDB
+	modelx.walletDB = nil
+	defer func() { modelx.walletDB = walletDB }()
+
+	const (
+		member  = uint64(9000000000000000001)
+		joining = uint64(9000000000000000002)
+		leaving = uint64(9000000000000000003)
+		other   = uint64(9000000000000000004)
+	)
+	Cache.StoreRewardRecipients(map[uint64]RewardRecipient{
+		member:  {Assigned: true, FromHeight: unknownHeight},
+		joining: {Assigned: true, FromHeight: unknownHeight}})
+	walletHandlerMock.On("GetRewardRecipient", member).Return(Cfg.PoolPublicID, nil).Once()
+	walletHandlerMock.On("GetRewardRecipient", joining).Return(joining, nil).Once()
+	walletHandlerMock.On("GetRewardRecipient", leaving).Return(Cfg.PoolPublicID, nil).Once()
+	walletHandlerMock.On("GetRewardRecipient", other).Return(uint64(0), errors.New("")).Once()
+
+	isRewardRecipient := func(accountID, height uint64) bool {
+		isPool, err := modelx.IsRewardRecipient(accountID, height, "192.0.2.1")
+		assert.Nil(t, err)
+		return isPool
+	}
+
+	assert.True(t, isRewardRecipient(member, 100))
+	assert.False(t, isRewardRecipient(joining, 100), "pending assignment accepted")
+	assert.True(t, isRewardRecipient(leaving, 100), "leaving account rejected too early")
+	assert.False(t, isRewardRecipient(other, 100))
+
+	// the results are cached, mocks return only once
+	assert.True(t, isRewardRecipient(member, 100))
+	assert.True(t, isRewardRecipient(member, 101), "verified member looked up again")
+	assert.False(t, isRewardRecipient(joining, 100))
+	assert.False(t, isRewardRecipient(other, 100))
+
+	walletHandlerMock.On("GetRewardRecipient", joining).Return(Cfg.PoolPublicID, nil).Once()
+	assert.True(t, isRewardRecipient(joining, 101), "assignment not effective on next height")
+}
+
 func TestRemoveDeadlineParams(t *testing.T) {
 	m := &Miner{DeadlinesParams: map[uint64]*DeadlineParams{999: &DeadlineParams{BaseTarget: 3, Deadline: 4}}}
 	m.removeDeadlineParams(1)
@@ -312,6 +368,50 @@ func TestCalculateEEPS(t *testing.T) {
 	assert.Equal(t, 1.4464712184653721e-05, m.CalculateEEPS())
 }
 
+func TestAddRoundSubmit(t *testing.T) {
+	m := Miner{}
+	assert.True(t, m.AddRoundSubmit(1, 2))
+	assert.True(t, m.AddRoundSubmit(1, 2))
+	assert.False(t, m.AddRoundSubmit(1, 2), "quota exceeded")
+
+	accepted, rejected := m.RoundSubmits(1)
+	assert.Equal(t, 2, accepted)
+	assert.Equal(t, 1, rejected)
+
+	assert.True(t, m.AddRoundSubmit(2, 2), "quota not reset on new round")
+	accepted, rejected = m.RoundSubmits(2)
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, 0, rejected)
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, m.AddRoundSubmit(2, 0), "unlimited quota")
+	}
+}
+
+func TestTargetDeadline(t *testing.T) {
+	m := Miner{
+		DeadlinesParams:     map[uint64]*DeadlineParams{},
+		WeightedDeadlineSum: 6 * 120 * float64(burstmath.GenesisBaseTarget)}
+	for i := uint64(0); i < uint64(Cfg.NAVG); i++ {
+		m.DeadlinesParams[i] = nil
+	}
+
+	defer func(rate float64) { Cfg.TargetDeadlineMissRate = rate }(Cfg.TargetDeadlineMissRate)
+
+	Cfg.TargetDeadlineMissRate = 0
+	assert.Equal(t, uint64(0), m.TargetDeadline(burstmath.GenesisBaseTarget), "disabled, but got target deadline")
+
+	Cfg.TargetDeadlineMissRate = 0.001
+	targetDeadline := m.TargetDeadline(burstmath.GenesisBaseTarget)
+	mean := 240 / m.CalculateEEPS()
+	assert.InDelta(t, mean*math.Log(1000), float64(targetDeadline), 1)
+	assert.InDelta(t, targetDeadline/2, m.TargetDeadline(2*burstmath.GenesisBaseTarget), 1,
+		"target deadline should shrink with rising difficulty")
+
+	m.DeadlinesParams = map[uint64]*DeadlineParams{}
+	assert.Equal(t, uint64(0), m.TargetDeadline(burstmath.GenesisBaseTarget), "no confirmed deadlines")
+}
+
 /*
 1. New Block
 2. "Old" New Block
@@ -397,6 +497,13 @@ func TestUpdateOrCreateNonceSubmission(t *testing.T) {
 	assert.True(t, exists, "did not add block to cache")
 	assert.False(t, slow, "did not mark block as fast")
 
+	if assert.Nil(t, modelx.nonceWriter.Flush()) {
+		var deadline uint64
+		modelx.db.Get(&deadline, "SELECT deadline FROM nonce_submission WHERE miner_id = ? AND block_height = ?",
+			miner.ID, currentHeight+2)
+		assert.Equal(t, uint64(5), deadline, "best deadline not written")
+	}
+
 	for _, s := range submissions {
 		modelx.db.MustExec("DELETE FROM block WHERE height = ?", s.height)
 	}
@@ -406,13 +513,31 @@ func TestUpdateOrCreateNonceSubmission(t *testing.T) {
 
 func TestGetAVGNetDiff(t *testing.T) {
 	assert.Equal(t, 250000.0, modelx.GetAVGNetDiff(0), "netDiff default wrong")
-	assert.Equal(t, 333045.039, modelx.GetAVGNetDiff(100), "netDiff wrong (1)")
-	assert.Equal(t, 344765.2544, modelx.GetAVGNetDiff(200), "netDiff wrong (2)")
+	// MariaDB rounds the division to four decimal places
+	assert.InDelta(t, 333045.039, modelx.GetAVGNetDiff(100), 1e-4, "netDiff wrong (1)")
+	assert.InDelta(t, 344765.2544, modelx.GetAVGNetDiff(200), 1e-4, "netDiff wrong (2)")
+}
+
+func TestDynamicDeadlineLimit(t *testing.T) {
+	defer func(n float64, limit uint64) {
+		Cfg.SubmissionsPerRound = n
+		Cfg.DeadlineLimit = limit
+	}(Cfg.SubmissionsPerRound, Cfg.DeadlineLimit)
+
+	Cfg.SubmissionsPerRound = 10
+	Cfg.DeadlineLimit = 0
+	assert.Equal(t, uint64(2400), dynamicDeadlineLimit(1000, 1000))
+	assert.Equal(t, uint64(1200), dynamicDeadlineLimit(1000, 2000), "limit should shrink with pool capacity")
+	assert.Equal(t, uint64(0), dynamicDeadlineLimit(1000, 0), "static limit expected without capacity")
+
+	Cfg.DeadlineLimit = 1000
+	assert.Equal(t, uint64(1000), dynamicDeadlineLimit(1000, 1000), "static limit exceeded")
+	assert.Equal(t, uint64(1000), dynamicDeadlineLimit(1000, 0))
 }
 
 func TestUpdateBestNonceSubmission(t *testing.T) {
 	height := uint64(493731)
-	modelx.UpdateBestSubmission(3685541669762741899, height)
+	assert.Nil(t, modelx.UpdateBestSubmission(3685541669762741899, height))
 
 	var bestNonceSubmissionID int64
 	err := modelx.db.Get(&bestNonceSubmissionID,
@@ -421,7 +546,19 @@ func TestUpdateBestNonceSubmission(t *testing.T) {
 		assert.Equal(t, bestNonceSubmissionID, int64(125506))
 	}
 
-	modelx.UpdateBestSubmission(13517851317125621367, height)
+	assert.Nil(t, modelx.UpdateBestSubmission(13517851317125621367, height))
+	err = modelx.db.Get(&bestNonceSubmissionID,
+		"SELECT best_nonce_submission_id FROM block WHERE height = ?", height)
+	if assert.Nil(t, err, nil) {
+		assert.Equal(t, bestNonceSubmissionID, int64(125513))
+	}
+
+	// with several instances a worse submission doesn't replace the best one
+	Cfg.LeaderElection.Enabled = true
+	defer func() {
+		Cfg.LeaderElection.Enabled = false
+	}()
+	assert.Nil(t, modelx.UpdateBestSubmission(3685541669762741899, height))
 	err = modelx.db.Get(&bestNonceSubmissionID,
 		"SELECT best_nonce_submission_id FROM block WHERE height = ?", height)
 	if assert.Nil(t, err, nil) {
@@ -454,7 +591,7 @@ func TestCleanDB(t *testing.T) {
 	// miner that should get deleted
 	modelx.db.MustExec(`INSERT INTO account
                 (id, pending, address)
-                VALUES (?, 500, "herscht")`, minerToDelete)
+                VALUES (?, 500, 'herscht')`, minerToDelete)
 	modelx.db.MustExec(`INSERT INTO miner (id) VALUES (?)`, minerToDelete)
 	modelx.db.MustExec(`INSERT INTO nonce_submission
                 (miner_id, block_height, deadline, nonce)
@@ -463,18 +600,32 @@ func TestCleanDB(t *testing.T) {
 	// miner that should not get deleted
 	modelx.db.MustExec(`INSERT INTO account
                 (id, pending, address)
-                VALUES (?, 600, "bold")`, minerNotToDelete)
+                VALUES (?, 600, 'bold')`, minerNotToDelete)
 	modelx.db.MustExec(`INSERT INTO miner (id) VALUES (?)`, minerNotToDelete)
 	modelx.db.MustExec(`INSERT INTO nonce_submission
                 (miner_id, block_height, deadline, nonce)
                 VALUES (?, ?, 1, 1)`, minerNotToDelete, heightNotToDelete)
 
+	// the abandoned balance of the fixture is worth a notice
+	walletHandlerMock.On("SendMessage", mock.Anything, mock.Anything).Return(uint64(1), nil)
+
+	archive := Cfg.Retention.Archive
+	Cfg.Retention.Archive = ArchiveTable
 	modelx.CleanDB()
+	Cfg.Retention.Archive = archive
 
 	var blockCount int
 	modelx.db.Get(&blockCount, "SELECT COUNT(*) FROM block WHERE height = ?", heightToDelete)
 	assert.Equal(t, 0, blockCount, "block is not deleted")
 
+	modelx.db.Get(&blockCount, "SELECT COUNT(*) FROM block_archive WHERE height = ?", heightToDelete)
+	assert.Equal(t, 1, blockCount, "block is not archived")
+
+	var nonceSubmissionCount int
+	modelx.db.Get(&nonceSubmissionCount, "SELECT COUNT(*) FROM nonce_submission_archive WHERE block_height = ?",
+		heightToDelete)
+	assert.Equal(t, 1, nonceSubmissionCount, "nonce submission is not archived")
+
 	var herschtID uint64
 	modelx.db.Get(&herschtID, "SELECT id FROM account WHERE id = 13371337")
 	assert.Equal(t, uint64(0), herschtID, "herscht account didn't get deleted")
@@ -483,12 +634,14 @@ func TestCleanDB(t *testing.T) {
 	modelx.db.Get(&boldID, "SELECT id FROM account WHERE id = 13371338")
 	assert.Equal(t, uint64(13371338), boldID, "bold account got deleted")
 
+	// only herscht's balance is swept right away, the notified one is kept for now
 	var feeAccountPending int64
 	modelx.db.Get(&feeAccountPending, "SELECT pending FROM account WHERE id = ?", Cfg.FeeAccountID)
-	assert.Equal(t, int64(38207000500), feeAccountPending, "didn't transfer pending to poolFeeAccount")
+	assert.Equal(t, int64(500), feeAccountPending, "didn't transfer pending to poolFeeAccount")
 
 	modelx.db.MustExec("DELETE FROM account WHERE id = ?", minerNotToDelete)
 	modelx.db.MustExec("DELETE FROM nonce_submission WHERE miner_id = ?", minerNotToDelete)
+	modelx.db.MustExec("DELETE FROM account WHERE id = ?", uint64(7511290003635342472))
 }
 
 func TestGetBestNonceSubmission(t *testing.T) {
@@ -583,16 +736,24 @@ func TestCreateTransactionsAndSendMoney(t *testing.T) {
 		payoutTest{accountID: 17025714653385549002, pending: 29231769150797280},
 	}
 
-	for accountID := uint64(0); accountID < 30; accountID++ {
+	for accountID := uint64(0); accountID < 29; accountID++ {
 		payoutTests = append(payoutTests, payoutTest{
 			accountID: accountID,
 			pending:   10000000000000})
-		modelx.db.MustExec(`INSERT INTO account SET
-                    id = ?,
-                    pending = 10000000000000,
-                    address = ?`, accountID, accountID)
+		modelx.db.MustExec(`INSERT INTO account (id, pending, address)
+                    VALUES (?, 10000000000000, ?)`, accountID, accountID)
 	}
 
+	// forced final payout of an abandoned balance below the minimum payout, it takes the
+	// place of a 30th account above, so that the payments still split into two transactions
+	forcedAccountID := uint64(29)
+	payoutTests = append(payoutTests, payoutTest{
+		accountID:      forcedAccountID,
+		pending:        500000000,
+		nextPayoutDate: &now})
+	modelx.db.MustExec(`INSERT INTO account (id, pending, address)
+                    VALUES (?, 500000000, ?)`, forcedAccountID, forcedAccountID)
+
 	for _, test := range payoutTests {
 		modelx.db.MustExec(`UPDATE account SET
                                       pending = ?,
@@ -604,13 +765,12 @@ func TestCreateTransactionsAndSendMoney(t *testing.T) {
 	}
 
 	var txsBefore []uint64
-	modelx.db.Select(&txsBefore, "SELECT id FROM transaction")
+	modelx.db.Select(&txsBefore, "SELECT id FROM `transaction`")
 
 	modelx.createTransactions()
 
 	var newTxs []int64
-	query, args, _ := sqlx.In(`SELECT id FROM transaction WHERE id NOT IN (?)`, txsBefore)
-	query = modelx.db.Rebind(query)
+	query, args, _ := sqlx.In("SELECT id FROM `transaction` WHERE id NOT IN (?)", txsBefore)
 	if !assert.Nil(t, modelx.db.Select(&newTxs, query, args...)) ||
 		!assert.Equal(t, 2, len(newTxs), "did not create two new transactions") {
 		return
@@ -635,7 +795,17 @@ func TestCreateTransactionsAndSendMoney(t *testing.T) {
 		if test.accountID == uint64(13517851317125621367) {
 			miner := Cache.GetMiner(test.accountID)
 			if assert.NotNil(t, miner) {
-				assert.Equal(t, int64(1086735605741493440), miner.Pending, "didn't reset pending")
+				assert.InDelta(t, int64(1086735605741493440), miner.Pending, 1086735605741493440*floatSumDelta,
+					"didn't reset pending")
+			}
+		}
+
+		if test.nextPayoutDate != nil && test.payoutInterval == nil {
+			var nextPayoutDate *time.Time
+			err := modelx.db.Get(&nextPayoutDate, "SELECT next_payout_date FROM account WHERE id = ?",
+				test.accountID)
+			if assert.Nil(t, err) {
+				assert.Nil(t, nextPayoutDate, "forced payout still due", test.accountID)
 			}
 		}
 
@@ -655,7 +825,7 @@ func TestCreateTransactionsAndSendMoney(t *testing.T) {
 	var feeAcountPending int64
 	err := modelx.db.Get(&feeAcountPending, `SELECT pending FROM account WHERE id = ?`, Cfg.FeeAccountID)
 	if assert.Nil(t, err) {
-		assert.Equal(t, int64(38837000500), feeAcountPending, "increased pending for fee account correctly")
+		assert.Equal(t, int64(630000500), feeAcountPending, "increased pending for fee account correctly")
 	}
 
 	var txCount, multiIdx, singleIdx int
@@ -671,11 +841,11 @@ func TestCreateTransactionsAndSendMoney(t *testing.T) {
 	walletHandlerMock.On("SendPayment", mock.Anything, mock.Anything).Return(uint64(1), nil)
 	walletHandlerMock.On("SendPayments", mock.Anything).Return(uint64(2), nil)
 	modelx.sendMoney()
-	err = modelx.db.Get(&txID, "SELECT transaction_id FROM transaction WHERE id = ?", newTxs[singleIdx])
+	err = modelx.db.Get(&txID, "SELECT transaction_id FROM `transaction` WHERE id = ?", newTxs[singleIdx])
 	if assert.Nil(t, err) && assert.NotNil(t, txID) {
 		assert.Equal(t, uint64(1), *txID)
 	}
-	err = modelx.db.Get(&txID, "SELECT transaction_id FROM transaction WHERE id = ?", newTxs[multiIdx])
+	err = modelx.db.Get(&txID, "SELECT transaction_id FROM `transaction` WHERE id = ?", newTxs[multiIdx])
 	if assert.Nil(t, err) && assert.NotNil(t, txID) {
 		assert.Equal(t, uint64(2), *txID)
 	}
@@ -832,10 +1002,10 @@ func TestSetMinPayout(t *testing.T) {
 func TestValidateTransactions(t *testing.T) {
 	txs := []uint64{17204890824727593469, 18229332154218210617, 16866907143315583784, 1337, 10899586909738602132}
 	for _, tx := range txs {
-		modelx.db.MustExec("UPDATE transaction SET block_height = NULL WHERE transaction_id = ?", tx)
+		modelx.db.MustExec("UPDATE `transaction` SET block_height = NULL WHERE transaction_id = ?", tx)
 	}
 	// new transaction should not be validated
-	modelx.db.MustExec("UPDATE transaction SET created = ? WHERE transaction_id = ?", time.Now(), txs[4])
+	modelx.db.MustExec("UPDATE `transaction` SET created = ? WHERE transaction_id = ?", time.Now(), txs[4])
 	walletHandlerMock.On("GetTransaction", txs[0]).Return(nil, true, errors.New("Unknown transaction"))
 	walletHandlerMock.On("GetTransaction", txs[1]).Return(nil, false, errors.New("some network error"))
 	walletHandlerMock.On("GetTransaction", txs[2]).Return(&wallet.GetTransactionReply{
@@ -847,32 +1017,32 @@ func TestValidateTransactions(t *testing.T) {
 	modelx.validateTransactions()
 
 	var height, tx *uint64
-	modelx.db.Get(&height, "SELECT block_height FROM transaction WHERE transaction_id = ?", txs[0])
-	modelx.db.Get(&tx, "SELECT transaction_id FROM transaction WHERE transaction_id = ?", txs[0])
+	modelx.db.Get(&height, "SELECT block_height FROM `transaction` WHERE transaction_id = ?", txs[0])
+	modelx.db.Get(&tx, "SELECT transaction_id FROM `transaction` WHERE transaction_id = ?", txs[0])
 	assert.Nil(t, height)
 	assert.Nil(t, tx)
 
-	modelx.db.Get(&height, "SELECT block_height FROM transaction WHERE transaction_id = ?", txs[1])
-	modelx.db.Get(&tx, "SELECT transaction_id FROM transaction WHERE transaction_id = ?", txs[1])
+	modelx.db.Get(&height, "SELECT block_height FROM `transaction` WHERE transaction_id = ?", txs[1])
+	modelx.db.Get(&tx, "SELECT transaction_id FROM `transaction` WHERE transaction_id = ?", txs[1])
 	assert.Nil(t, height)
 	if assert.NotNil(t, tx) {
 		assert.Equal(t, txs[1], *tx)
 	}
 
-	modelx.db.Get(&height, "SELECT block_height FROM transaction WHERE transaction_id = ?", txs[2])
-	modelx.db.Get(&tx, "SELECT transaction_id FROM transaction WHERE transaction_id = ?", txs[2])
+	modelx.db.Get(&height, "SELECT block_height FROM `transaction` WHERE transaction_id = ?", txs[2])
+	modelx.db.Get(&tx, "SELECT transaction_id FROM `transaction` WHERE transaction_id = ?", txs[2])
 	if assert.NotNil(t, tx) && assert.NotNil(t, height) {
 		assert.Equal(t, uint64(492826), *height)
 		assert.Equal(t, txs[2], *tx)
 	}
 
-	err := modelx.db.Get(&tx, "SELECT transaction_id FROM transaction WHERE transaction_id = ?", txs[3])
+	err := modelx.db.Get(&tx, "SELECT transaction_id FROM `transaction` WHERE transaction_id = ?", txs[3])
 	if assert.NotNil(t, err) {
 		assert.Equal(t, sql.ErrNoRows, err)
 	}
 
-	modelx.db.Get(&height, "SELECT block_height FROM transaction WHERE transaction_id = ?", txs[4])
-	modelx.db.Get(&tx, "SELECT transaction_id FROM transaction WHERE transaction_id = ?", txs[4])
+	modelx.db.Get(&height, "SELECT block_height FROM `transaction` WHERE transaction_id = ?", txs[4])
+	modelx.db.Get(&tx, "SELECT transaction_id FROM `transaction` WHERE transaction_id = ?", txs[4])
 	assert.Nil(t, height)
 	if assert.NotNil(t, tx) {
 		assert.Equal(t, txs[4], *tx)
@@ -0,0 +1,299 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+// nonceWriteAttempts is how often a nonce submission the db rejects on its own is
+// tried to be written before it is dropped
+const nonceWriteAttempts = 5
+
+// errNonceQueueFull is returned if the queue stayed full for Cfg.NonceWriter.QueueTimeoutDur
+var errNonceQueueFull = errors.New("nonce submission queue full")
+
+var nonceSubmissionColumns = []string{"miner_id", "block_height", "deadline", "nonce"}
+var nonceSubmissionKeys = []string{"miner_id", "block_height"}
+
+type nonceKey struct {
+	minerID uint64
+	height  uint64
+}
+
+type nonceWrite struct {
+	deadline uint64
+	nonce    uint64
+	attempts int
+}
+
+// nonceWriter persists nonce submissions behind the cache. Submissions of a miner at a
+// height are coalesced until they are written in multi-row batches. If the queue is
+// full, adding further submissions blocks until the next batch was written or it times
+// out. Queued submissions are logged to disk, so they aren't lost if the pool stops
+// meanwhile.
+type nonceWriter struct {
+	db  storage.Storage
+	wal *nonceWAL
+
+	// health is told about outages, if set
+	health *dbHealth
+	// prepare runs before writing, if set, submissions are held back while it fails
+	prepare func() error
+
+	mu      sync.Mutex
+	space   *sync.Cond
+	pending map[nonceKey]*nonceWrite
+	flushes chan struct{}
+
+	// flushMu makes a flush wait for the one in progress
+	flushMu sync.Mutex
+}
+
+// newNonceWriter opens the log of queued submissions and queues the ones that weren't
+// written before the last stop again
+func newNonceWriter(db storage.Storage) (*nonceWriter, error) {
+	wal, records, err := openNonceWAL(Cfg.NonceWriter.WALDir, Cfg.NonceWriter.WALMaxSizeBytes,
+		Cfg.NonceWriter.WALSync)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &nonceWriter{
+		db:      db,
+		wal:     wal,
+		pending: make(map[nonceKey]*nonceWrite),
+		flushes: make(chan struct{}, 1)}
+	w.space = sync.NewCond(&w.mu)
+
+	// later records of a miner at a height are better ones
+	for i := range records {
+		w.pending[records[i].key] = &records[i].write
+	}
+	if len(records) > 0 {
+		Logger.Info("recovered nonce submissions", zap.Int("records", len(records)),
+			zap.Int("submissions", len(w.pending)))
+	}
+	return w, nil
+}
+
+// run flushes the queue in the background every flush interval or once a batch is full
+func (w *nonceWriter) run() {
+	ticker := time.NewTicker(Cfg.NonceWriter.FlushIntervalDur)
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushes:
+		}
+		w.Flush()
+	}
+}
+
+// add logs and queues the better deadline of a miner at a height
+func (w *nonceWriter) add(minerID, height, deadline, nonce uint64) error {
+	key := nonceKey{minerID: minerID, height: height}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var giveUp time.Time
+	for {
+		if write, queued := w.pending[key]; queued {
+			if err := w.wal.append(key, deadline, nonce, false); err != nil {
+				return err
+			}
+			write.deadline, write.nonce, write.attempts = deadline, nonce, 0
+			return nil
+		}
+		if len(w.pending) < Cfg.NonceWriter.QueueSize {
+			break
+		}
+		if giveUp.IsZero() {
+			giveUp = time.Now().Add(Cfg.NonceWriter.QueueTimeoutDur)
+			// wakes this waiter up in case no batch gets written in time
+			timer := time.AfterFunc(Cfg.NonceWriter.QueueTimeoutDur, func() {
+				w.mu.Lock()
+				w.space.Broadcast()
+				w.mu.Unlock()
+			})
+			defer timer.Stop()
+		} else if !time.Now().Before(giveUp) {
+			return errNonceQueueFull
+		}
+		w.space.Wait()
+	}
+
+	if err := w.wal.append(key, deadline, nonce, false); err != nil {
+		return err
+	}
+	w.pending[key] = &nonceWrite{deadline: deadline, nonce: nonce}
+	if len(w.pending) >= Cfg.NonceWriter.BatchSize {
+		select {
+		case w.flushes <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// discard drops the queued submissions at height and runs f before any further ones are
+// written, so that none of the dropped ones is still in flight meanwhile
+func (w *nonceWriter) discard(height uint64, f func() error) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	for key := range w.pending {
+		if key.height == height {
+			delete(w.pending, key)
+		}
+	}
+	w.mu.Unlock()
+	w.space.Broadcast()
+
+	return f()
+}
+
+// queued is the number of submissions waiting to be written
+func (w *nonceWriter) queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// Flush writes everything that was queued before it was called
+func (w *nonceWriter) Flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	writes := w.pending
+	if len(writes) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	w.pending = make(map[nonceKey]*nonceWrite)
+	// the sealed segments only hold submissions of this flush and the failed ones of
+	// previous flushes, which are part of it as well
+	sealed, rotateErr := w.wal.rotate()
+	if rotateErr != nil {
+		Logger.Error("rotating nonce submission log failed", zap.Error(rotateErr))
+	}
+	w.mu.Unlock()
+
+	// the batches in flight take up the queue's space until they are written
+	defer w.space.Broadcast()
+
+	if w.prepare != nil {
+		if err := w.prepare(); err != nil {
+			Logger.Error("preparing to write nonce submissions failed", zap.Error(err))
+			if requeueErr := w.requeue(writes, sealed); requeueErr != nil {
+				Logger.Error("logging held back nonce submissions again failed", zap.Error(requeueErr))
+			}
+			return err
+		}
+	}
+
+	var failed map[nonceKey]*nonceWrite
+	var err error
+	batch := make([]nonceKey, 0, Cfg.NonceWriter.BatchSize)
+	for key := range writes {
+		batch = append(batch, key)
+		if len(batch) < Cfg.NonceWriter.BatchSize {
+			continue
+		}
+		if batchErr := w.writeBatch(batch, writes, &failed); batchErr != nil {
+			err = batchErr
+		}
+		batch = batch[:0]
+	}
+	if len(batch) > 0 {
+		if batchErr := w.writeBatch(batch, writes, &failed); batchErr != nil {
+			err = batchErr
+		}
+	}
+
+	if requeueErr := w.requeue(failed, sealed); requeueErr != nil {
+		Logger.Error("logging failed nonce submissions again failed", zap.Error(requeueErr))
+	}
+	return err
+}
+
+// writeBatch upserts the batch, if that fails the submissions are written one by one so
+// that a single bad row doesn't hold back the others. Submissions the db keeps
+// rejecting while others get written are dropped eventually.
+func (w *nonceWriter) writeBatch(batch []nonceKey, writes map[nonceKey]*nonceWrite,
+	failed *map[nonceKey]*nonceWrite) error {
+	args := make([]interface{}, 0, len(batch)*len(nonceSubmissionColumns))
+	for _, key := range batch {
+		write := writes[key]
+		args = append(args, key.minerID, key.height, write.deadline, write.nonce)
+	}
+	// another instance might have written a better deadline of the miner meanwhile
+	_, err := w.db.Exec(w.db.UpsertLower("nonce_submission", nonceSubmissionColumns, nonceSubmissionKeys,
+		"deadline", len(batch)), args...)
+	if err == nil {
+		return nil
+	}
+	Logger.Error("writing nonce submissions failed", zap.Int("submissions", len(batch)), zap.Error(err))
+
+	var rejected []nonceKey
+	single := w.db.UpsertLower("nonce_submission", nonceSubmissionColumns, nonceSubmissionKeys, "deadline", 1)
+	for _, key := range batch {
+		write := writes[key]
+		if _, err := w.db.Exec(single, key.minerID, key.height, write.deadline, write.nonce); err != nil {
+			rejected = append(rejected, key)
+		}
+	}
+
+	// if nothing could be written the db is most likely unavailable
+	allRejected := len(rejected) == len(batch)
+	unreachable := allRejected && w.health != nil && w.health.report(err)
+	unavailable := allRejected && (len(batch) > 1 || unreachable)
+	for _, key := range rejected {
+		write := writes[key]
+		if !unavailable {
+			write.attempts++
+		}
+		if write.attempts >= nonceWriteAttempts {
+			Logger.Error("dropped nonce submission", zap.Uint64("minerID", key.minerID),
+				zap.Uint64("height", key.height), zap.Uint64("deadline", write.deadline))
+			continue
+		}
+		if *failed == nil {
+			*failed = make(map[nonceKey]*nonceWrite)
+		}
+		(*failed)[key] = write
+	}
+	return err
+}
+
+// requeue queues failed submissions again, unless a better one came in meanwhile.
+// Once they are logged again the sealed segments aren't needed anymore.
+func (w *nonceWriter) requeue(failed map[nonceKey]*nonceWrite, sealed int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, write := range failed {
+		if _, queued := w.pending[key]; queued {
+			continue
+		}
+		if err := w.wal.append(key, write.deadline, write.nonce, true); err != nil {
+			// keep the sealed segments, they still hold the remaining submissions
+			for key, write := range failed {
+				if _, queued := w.pending[key]; !queued {
+					w.pending[key] = write
+				}
+			}
+			return err
+		}
+		w.pending[key] = write
+	}
+	return w.wal.removeSealed(sealed)
+}
@@ -0,0 +1,182 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nonceWriterFixture inserts a block at height and the accounts of minerIDs, the
+// returned func deletes them again
+func nonceWriterFixture(height uint64, minerIDs []uint64) func() {
+	genSigBytes, _ := burstmath.DecodeGeneratorSignature(sampleGenSig)
+	modelx.db.MustExec(`INSERT
+	        INTO block (height, base_target, scoop, generation_signature, created, generation_time)
+	        VALUES (?, ?, ?, ?, ?, ?)`,
+		height, 13, burstmath.CalcScoop(height, genSigBytes), sampleGenSig, time.Now(), 30)
+	for _, minerID := range minerIDs {
+		modelx.db.MustExec("INSERT INTO account (id, address) VALUES (?, ?)", minerID, minerID)
+	}
+	return func() {
+		modelx.db.MustExec("DELETE FROM block WHERE height = ?", height)
+		for _, minerID := range minerIDs {
+			modelx.db.MustExec("DELETE FROM account WHERE id = ?", minerID)
+		}
+	}
+}
+
+func deadlineInDB(minerID, height uint64) uint64 {
+	var deadline uint64
+	modelx.db.Get(&deadline, "SELECT deadline FROM nonce_submission WHERE miner_id = ? AND block_height = ?",
+		minerID, height)
+	return deadline
+}
+
+func TestNonceWriter(t *testing.T) {
+	defer func(nonceWriter NonceWriterConfig) {
+		Cfg.NonceWriter = nonceWriter
+	}(Cfg.NonceWriter)
+	Cfg.NonceWriter.BatchSize = 1
+	Cfg.NonceWriter.QueueSize = 2
+	Cfg.NonceWriter.QueueTimeoutDur = 5 * time.Second
+	Cfg.NonceWriter.WALDir, _ = ioutil.TempDir("", "wal")
+	defer os.RemoveAll(Cfg.NonceWriter.WALDir)
+
+	height := uint64(1000)
+	minerIDs := []uint64{42424241, 42424242, 42424243}
+	defer nonceWriterFixture(height, minerIDs)()
+
+	w, err := newNonceWriter(modelx.db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// submissions of a miner at a height are coalesced
+	assert.Nil(t, w.add(minerIDs[0], height, 10, 1))
+	assert.Nil(t, w.add(minerIDs[0], height, 5, 2))
+	assert.Nil(t, w.add(minerIDs[1], height, 7, 3))
+	assert.Len(t, w.pending, 2, "submissions not coalesced")
+
+	added := make(chan struct{})
+	go func() {
+		assert.Nil(t, w.add(minerIDs[2], height, 9, 4))
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Error("adding to a full queue didn't block")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Nil(t, w.Flush())
+	<-added
+	assert.Equal(t, uint64(5), deadlineInDB(minerIDs[0], height))
+	assert.Equal(t, uint64(7), deadlineInDB(minerIDs[1], height))
+
+	// written submissions get updated
+	assert.Nil(t, w.add(minerIDs[0], height, 3, 5))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, uint64(3), deadlineInDB(minerIDs[0], height))
+	assert.Equal(t, uint64(9), deadlineInDB(minerIDs[2], height))
+
+	// worse deadlines, e.g. the ones of another instance, don't replace written ones
+	assert.Nil(t, w.add(minerIDs[0], height, 4, 6))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, uint64(3), deadlineInDB(minerIDs[0], height), "better deadline replaced")
+
+	// submissions the db rejects, here on a missing block, are retried and dropped eventually
+	assert.Nil(t, w.add(minerIDs[0], height+1, 1, 1))
+	for i := 0; i < nonceWriteAttempts; i++ {
+		assert.Len(t, w.pending, 1, "rejected submission dropped too early")
+		assert.NotNil(t, w.Flush())
+	}
+	assert.Len(t, w.pending, 0, "rejected submission not dropped")
+
+	// waiting for space in a queue that stays full times out
+	Cfg.NonceWriter.QueueTimeoutDur = 20 * time.Millisecond
+	assert.Nil(t, w.add(minerIDs[0], height, 2, 6))
+	assert.Nil(t, w.add(minerIDs[1], height, 2, 7))
+	assert.Equal(t, errNonceQueueFull, w.add(minerIDs[2], height, 2, 8))
+	assert.Nil(t, w.Flush())
+}
+
+func TestNonceWriterDiscard(t *testing.T) {
+	defer func(nonceWriter NonceWriterConfig) {
+		Cfg.NonceWriter = nonceWriter
+	}(Cfg.NonceWriter)
+	Cfg.NonceWriter.WALDir, _ = ioutil.TempDir("", "wal")
+	defer os.RemoveAll(Cfg.NonceWriter.WALDir)
+
+	height := uint64(1000)
+	minerIDs := []uint64{42424241, 42424242}
+	defer nonceWriterFixture(height, minerIDs)()
+	defer nonceWriterFixture(height+1, nil)()
+
+	w, err := newNonceWriter(modelx.db)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Nil(t, w.add(minerIDs[0], height, 10, 1))
+	assert.Nil(t, w.add(minerIDs[1], height+1, 10, 2))
+	assert.Nil(t, w.discard(height, func() error {
+		assert.Len(t, w.pending, 1, "submissions at the height not discarded")
+		return nil
+	}))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, uint64(0), deadlineInDB(minerIDs[0], height), "discarded submission written")
+	assert.Equal(t, uint64(10), deadlineInDB(minerIDs[1], height+1))
+}
+
+func TestNonceWAL(t *testing.T) {
+	defer func(nonceWriter NonceWriterConfig) {
+		Cfg.NonceWriter = nonceWriter
+	}(Cfg.NonceWriter)
+	Cfg.NonceWriter.WALDir, _ = ioutil.TempDir("", "wal")
+	defer os.RemoveAll(Cfg.NonceWriter.WALDir)
+	Cfg.NonceWriter.WALMaxSizeBytes = 2 * walRecordSize
+
+	height := uint64(1001)
+	minerIDs := []uint64{42424244, 42424245}
+	defer nonceWriterFixture(height, minerIDs)()
+
+	w, err := newNonceWriter(modelx.db)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, w.add(minerIDs[0], height, 10, 1))
+	assert.Nil(t, w.add(minerIDs[0], height, 5, 2))
+	assert.Equal(t, errWALFull, w.add(minerIDs[1], height, 7, 3), "size limit of the log exceeded")
+
+	// a crash while logging leaves an incomplete record
+	segments, _ := filepath.Glob(filepath.Join(Cfg.NonceWriter.WALDir, "*.wal"))
+	if assert.Len(t, segments, 1) {
+		f, err := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0640)
+		if assert.Nil(t, err) {
+			f.Write([]byte{1, 2, 3})
+			f.Close()
+		}
+	}
+
+	// the submissions of the crashed writer are recovered
+	recovered, err := newNonceWriter(modelx.db)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, recovered.pending, 1, "submissions not recovered")
+	assert.Nil(t, recovered.Flush())
+	assert.Equal(t, uint64(5), deadlineInDB(minerIDs[0], height))
+
+	segments, _ = filepath.Glob(filepath.Join(Cfg.NonceWriter.WALDir, "*.wal"))
+	assert.Len(t, segments, 1, "written segments not removed")
+	assert.Nil(t, recovered.add(minerIDs[1], height, 7, 3), "log not emptied")
+}
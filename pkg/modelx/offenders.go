@@ -0,0 +1,227 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxRecentRejects = 10
+	maxAutoBan       = 30 * 24 * time.Hour
+
+	// automatic bans waiting to be stored, further ones are dropped
+	autoBanQueueSize = 1024
+)
+
+// Reject is a submission the pool refused
+type Reject struct {
+	Reason string
+	Time   time.Time
+}
+
+// Offender collects the rejected submissions of an account or an ip
+type Offender struct {
+	Type    string
+	Value   string
+	Counts  map[string]int
+	Total   int
+	Recent  []Reject
+	Bans    int
+	Banned  time.Time
+	Expires time.Time
+
+	windowStart time.Time
+	windowCount int
+	// rejections of reasons with a threshold of their own
+	reasonCounts map[string]int
+}
+
+type offenders struct {
+	byKey map[string]*Offender
+	sync.Mutex
+}
+
+func newOffenders() *offenders {
+	return &offenders{byKey: make(map[string]*Offender)}
+}
+
+// autoBan is a ban of an offender waiting to be stored
+type autoBan struct {
+	banType  string
+	value    string
+	reason   string
+	duration time.Duration
+}
+
+// note counts a rejection for diagnostics only, the offender is never banned
+func (o *offenders) note(banType, value, reason string, now time.Time) {
+	o.Lock()
+	defer o.Unlock()
+	o.count(banType, value, reason, now)
+}
+
+// record counts a rejection and yields the duration of a ban if the offender reached
+// Cfg.AutoBanThreshold rejections within Cfg.AutoBanWindowDur. Reasons with a threshold
+// in Cfg.AutoBanThresholds are counted on their own. Each further ban of the same
+// offender lasts twice as long.
+func (o *offenders) record(banType, value, reason string, now time.Time) time.Duration {
+	o.Lock()
+	defer o.Unlock()
+
+	offender := o.count(banType, value, reason, now)
+	threshold, own := Cfg.AutoBanThresholds[reason]
+	if !own {
+		threshold = Cfg.AutoBanThreshold
+	}
+	if threshold == 0 || now.Before(offender.Expires) {
+		return 0
+	}
+
+	if now.Sub(offender.windowStart) > Cfg.AutoBanWindowDur {
+		offender.windowStart = now
+		offender.windowCount = 0
+		offender.reasonCounts = nil
+	}
+	var count int
+	if own {
+		if offender.reasonCounts == nil {
+			offender.reasonCounts = make(map[string]int)
+		}
+		offender.reasonCounts[reason]++
+		count = offender.reasonCounts[reason]
+	} else {
+		offender.windowCount++
+		count = offender.windowCount
+	}
+	if count < threshold {
+		return 0
+	}
+
+	duration := Cfg.AutoBanDurationDur << uint(offender.Bans)
+	if duration > maxAutoBan || duration <= 0 {
+		duration = maxAutoBan
+	}
+	offender.Bans++
+	offender.Banned = now
+	offender.Expires = now.Add(duration)
+	offender.windowCount = 0
+	offender.reasonCounts = nil
+	return duration
+}
+
+// count adds a rejection to an offender, o must be locked
+func (o *offenders) count(banType, value, reason string, now time.Time) *Offender {
+	key := banType + ":" + value
+	offender, exists := o.byKey[key]
+	if !exists {
+		offender = &Offender{
+			Type:   banType,
+			Value:  value,
+			Counts: make(map[string]int)}
+		o.byKey[key] = offender
+	}
+
+	offender.Counts[reason]++
+	offender.Total++
+	offender.Recent = append(offender.Recent, Reject{Reason: reason, Time: now})
+	if len(offender.Recent) > maxRecentRejects {
+		offender.Recent = offender.Recent[1:]
+	}
+	return offender
+}
+
+// list yields a copy of all offenders, the ones with most rejections first
+func (o *offenders) list() []Offender {
+	o.Lock()
+	defer o.Unlock()
+
+	list := make([]Offender, 0, len(o.byKey))
+	for _, offender := range o.byKey {
+		cp := *offender
+		cp.Counts = make(map[string]int, len(offender.Counts))
+		for reason, count := range offender.Counts {
+			cp.Counts[reason] = count
+		}
+		cp.Recent = append([]Reject(nil), offender.Recent...)
+		list = append(list, cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Total > list[j].Total })
+	return list
+}
+
+// get yields a copy of an offender
+func (o *offenders) get(banType, value string) (Offender, bool) {
+	o.Lock()
+	defer o.Unlock()
+
+	offender, exists := o.byKey[banType+":"+value]
+	if !exists {
+		return Offender{}, false
+	}
+	cp := *offender
+	cp.Recent = append([]Reject(nil), offender.Recent...)
+	return cp, true
+}
+
+// forget drops offenders that haven't been rejected since before
+func (o *offenders) forget(before time.Time) {
+	o.Lock()
+	defer o.Unlock()
+
+	for key, offender := range o.byKey {
+		if len(offender.Recent) > 0 && offender.Recent[len(offender.Recent)-1].Time.Before(before) &&
+			offender.Expires.Before(before) {
+			delete(o.byKey, key)
+		}
+	}
+}
+
+// RecordReject counts a rejected submission for the ip and, if known, the account that
+// sent it. Ips exceeding the threshold get banned temporarily, accounts are only counted
+// for diagnostics, as anybody can send submissions in the name of any account.
+func (modelx *Modelx) RecordReject(accountID uint64, ip, reason string) {
+	now := time.Now()
+	if ip != "" {
+		if duration := Cache.offenders.record(BanIP, ip, reason, now); duration != 0 {
+			select {
+			case modelx.autoBans <- autoBan{BanIP, ip, reason, duration}:
+			default:
+				Logger.Error("automatic ban dropped, queue full", zap.String("ip", ip))
+			}
+		}
+	}
+	if accountID != 0 {
+		Cache.offenders.note(BanAccount, strconv.FormatUint(accountID, 10), reason, now)
+	}
+}
+
+// autoBanJob stores the automatic bans outside of the request path, bans queued
+// together are applied by loading the bans once
+func (modelx *Modelx) autoBanJob() {
+	for ban := range modelx.autoBans {
+		modelx.storeAutoBan(ban)
+		for queued := len(modelx.autoBans); queued > 0; queued-- {
+			modelx.storeAutoBan(<-modelx.autoBans)
+		}
+		if err := modelx.LoadBans(); err != nil {
+			Logger.Error("loading bans failed", zap.Error(err))
+		}
+	}
+}
+
+func (modelx *Modelx) storeAutoBan(ban autoBan) {
+	err := modelx.storeBan(ban.banType, ban.value, "automatic ban: "+ban.reason, ban.duration)
+	if err != nil {
+		Logger.Error("automatic ban failed", zap.String("type", ban.banType), zap.String("value", ban.value),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,124 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReject(t *testing.T) {
+	threshold, window, duration := Cfg.AutoBanThreshold, Cfg.AutoBanWindowDur, Cfg.AutoBanDurationDur
+	defer func() {
+		Cfg.AutoBanThreshold, Cfg.AutoBanWindowDur, Cfg.AutoBanDurationDur = threshold, window, duration
+	}()
+	Cfg.AutoBanThreshold = 3
+	Cfg.AutoBanWindowDur = time.Minute
+	Cfg.AutoBanDurationDur = 10 * time.Minute
+
+	o := newOffenders()
+	now := time.Now()
+
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", now))
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "malformed nonce", now))
+	assert.Equal(t, 10*time.Minute, o.record(BanIP, "1.2.3.4", "wrong height", now))
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", now.Add(time.Minute)),
+		"banned offender banned again")
+
+	// rejections spread over several windows don't trigger a ban
+	later := now.Add(20 * time.Minute)
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", later))
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", later.Add(2*time.Minute)))
+
+	later = later.Add(2 * time.Minute)
+	o.record(BanIP, "1.2.3.4", "wrong height", later)
+	assert.Equal(t, 20*time.Minute, o.record(BanIP, "1.2.3.4", "wrong height", later),
+		"ban duration not doubled")
+
+	o.record(BanAccount, "1337", "deadline over limit", now)
+
+	offenders := o.list()
+	if assert.Len(t, offenders, 2) {
+		assert.Equal(t, "1.2.3.4", offenders[0].Value)
+		assert.Equal(t, 8, offenders[0].Total)
+		assert.Equal(t, 7, offenders[0].Counts["wrong height"])
+		assert.Equal(t, 2, offenders[0].Bans)
+	}
+
+	o.forget(later.Add(time.Hour))
+	assert.Len(t, o.list(), 0)
+}
+
+func TestRecordRejectDisabled(t *testing.T) {
+	threshold := Cfg.AutoBanThreshold
+	defer func() { Cfg.AutoBanThreshold = threshold }()
+	Cfg.AutoBanThreshold = 0
+
+	o := newOffenders()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", time.Now()))
+	}
+	assert.Equal(t, 100, o.list()[0].Total)
+}
+
+func TestRecordRejectThresholdsByReason(t *testing.T) {
+	threshold, thresholds, window := Cfg.AutoBanThreshold, Cfg.AutoBanThresholds, Cfg.AutoBanWindowDur
+	defer func() {
+		Cfg.AutoBanThreshold, Cfg.AutoBanThresholds, Cfg.AutoBanWindowDur = threshold, thresholds, window
+	}()
+	Cfg.AutoBanThreshold = 2
+	Cfg.AutoBanThresholds = map[string]int{"deadline over limit": 3, "malformed nonce": 0}
+	Cfg.AutoBanWindowDur = time.Minute
+
+	o := newOffenders()
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "malformed nonce", now),
+			"banned for a reason without bans")
+	}
+
+	// reasons with a threshold of their own don't add up with others
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "deadline over limit", now))
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "wrong height", now))
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "deadline over limit", now))
+	assert.NotEqual(t, time.Duration(0), o.record(BanIP, "1.2.3.4", "deadline over limit", now),
+		"own threshold not applied")
+
+	assert.Equal(t, time.Duration(0), o.record(BanIP, "5.6.7.8", "wrong height", now))
+	assert.NotEqual(t, time.Duration(0), o.record(BanIP, "5.6.7.8", "wrong height", now),
+		"global threshold not applied")
+}
+
+func TestRecordRejectBansIPs(t *testing.T) {
+	threshold, window, duration := Cfg.AutoBanThreshold, Cfg.AutoBanWindowDur, Cfg.AutoBanDurationDur
+	defer func() {
+		Cfg.AutoBanThreshold, Cfg.AutoBanWindowDur, Cfg.AutoBanDurationDur = threshold, window, duration
+	}()
+	Cfg.AutoBanThreshold = 2
+	Cfg.AutoBanWindowDur = time.Minute
+	Cfg.AutoBanDurationDur = 10 * time.Minute
+	defer modelx.LoadBans()
+	defer modelx.db.MustExec("DELETE FROM ban")
+
+	for i := 0; i < 3; i++ {
+		modelx.RecordReject(1337, "192.0.2.7", "wrong height")
+	}
+
+	// the ban is stored in the background
+	for i := 0; i < 100 && Cache.BannedClient("192.0.2.7", "") == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotNil(t, Cache.BannedClient("192.0.2.7", ""), "offending ip not banned")
+	assert.Nil(t, Cache.BannedAccount(1337), "account banned for rejections of an ip")
+
+	offender, exists := Cache.offenders.get(BanAccount, "1337")
+	if assert.True(t, exists, "account rejections not counted") {
+		assert.Equal(t, 3, offender.Total)
+		assert.Equal(t, 0, offender.Bans)
+	}
+}
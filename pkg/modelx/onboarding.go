@@ -0,0 +1,90 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/rsencoding"
+)
+
+// AccountCheck sums up what keeps an account from mining in the pool
+type AccountCheck struct {
+	ID      uint64
+	Address string
+
+	// reward recipient that is effective at the current height as seen by the wallet
+	RewardRecipient        uint64
+	RewardRecipientAddress string
+	// the account is in the wallet's list of accounts assigned to the pool,
+	// the assignment might not be effective yet
+	AssignedToPool bool
+	Effective      bool
+
+	Ban *Ban
+
+	LastSubmitHeight uint64
+	LastSubmit       time.Time
+	LastReject       *Reject
+
+	NConf     int
+	UntilNMin time.Duration
+}
+
+// ParseAccount reads a numeric account id or a BURST- address
+func ParseAccount(s string) (uint64, error) {
+	trimmed := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "BURST-")
+	accountID, err := strconv.ParseUint(trimmed, 10, 64)
+	if err != nil {
+		return rsencoding.Decode(trimmed)
+	}
+	return accountID, nil
+}
+
+// CheckAccount asks the wallet for the reward recipient of an account and collects
+// what the pool knows about it
+func (modelx *Modelx) CheckAccount(accountID uint64) (*AccountCheck, error) {
+	rewardRecipient, err := modelx.walletHandler.GetRewardRecipient(accountID)
+	if err != nil {
+		return nil, err
+	}
+	rr, _ := Cache.GetRewardRecipient(accountID)
+
+	check := &AccountCheck{
+		ID:                     accountID,
+		Address:                rsencoding.Encode(accountID),
+		RewardRecipient:        rewardRecipient,
+		RewardRecipientAddress: rsencoding.Encode(rewardRecipient),
+		AssignedToPool:         rr.Assigned,
+		Effective:              rewardRecipient == Cfg.PoolPublicID,
+		Ban:                    Cache.BannedAccount(accountID)}
+
+	if miner := Cache.GetMiner(accountID); miner != nil {
+		miner.Lock()
+		check.LastSubmitHeight = miner.CurrentBlockHeight()
+		check.LastSubmit = miner.lastSubmit
+		check.NConf = len(miner.DeadlinesParams)
+		miner.Unlock()
+	}
+
+	if offender, exists := Cache.Offender(BanAccount, strconv.FormatUint(accountID, 10)); exists &&
+		len(offender.Recent) > 0 {
+		check.LastReject = &offender.Recent[len(offender.Recent)-1]
+	}
+
+	check.UntilNMin = untilNMin(check.NConf)
+
+	return check, nil
+}
+
+// untilNMin estimates how long it takes until a miner submitting every round has
+// enough confirmed deadlines for its capacity to be estimated
+func untilNMin(nConf int) time.Duration {
+	if nConf >= Cfg.NMin {
+		return 0
+	}
+	return time.Duration(Cfg.NMin-nConf) * 240 * time.Second
+}
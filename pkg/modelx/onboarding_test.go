@@ -0,0 +1,32 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/rsencoding"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccount(t *testing.T) {
+	const id = 10282355196851764065
+	for _, s := range []string{"10282355196851764065", rsencoding.Encode(id), " burst-" + rsencoding.Encode(id)} {
+		accountID, err := ParseAccount(s)
+		if assert.Nil(t, err, s) {
+			assert.Equal(t, uint64(id), accountID, s)
+		}
+	}
+	_, err := ParseAccount("BURST-nope")
+	assert.NotNil(t, err)
+}
+
+func TestUntilNMin(t *testing.T) {
+	assert.Equal(t, time.Duration(Cfg.NMin)*240*time.Second, untilNMin(0))
+	assert.Equal(t, 240*time.Second, untilNMin(Cfg.NMin-1))
+	assert.Equal(t, time.Duration(0), untilNMin(Cfg.NMin))
+	assert.Equal(t, time.Duration(0), untilNMin(Cfg.NMin+1))
+}
@@ -0,0 +1,69 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+// readDBRetryInterval is how long to wait before connecting to the read replica again
+const readDBRetryInterval = 30 * time.Second
+
+// connectReadDB connects to the read replica the queries of the web server and the api
+// run on, without one they use the primary db. If the replica is unavailable they use
+// the primary db until connecting succeeds.
+func (modelx *Modelx) connectReadDB() {
+	if Cfg.ReadDB.Name == "" || modelx.openReadDB() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(readDBRetryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if modelx.openReadDB() {
+				return
+			}
+		}
+	}()
+}
+
+func (modelx *Modelx) openReadDB() bool {
+	readDB, err := storage.Connect(Cfg.ReadDB)
+	if err != nil {
+		Logger.Error("connecting to read replica failed, using primary db", zap.Error(err))
+		return false
+	}
+	modelx.readDBMu.Lock()
+	modelx.readDB = readDB
+	modelx.readDBMu.Unlock()
+	Logger.Info("connected to read replica")
+	return true
+}
+
+// readQuery runs a query that may see slightly outdated data on the read replica, the
+// primary db answers if the replica fails
+func (modelx *Modelx) readQuery(f func(db storage.Querier) error) error {
+	modelx.readDBMu.RLock()
+	readDB := modelx.readDB
+	modelx.readDBMu.RUnlock()
+	if readDB != modelx.db {
+		err := f(readDB)
+		if err == nil || err == sql.ErrNoRows {
+			return err
+		}
+		Logger.Warn("query on read replica failed, using primary db", zap.Error(err))
+	}
+	return f(modelx.db)
+}
+
+// primaryQuery runs a query on the primary db, for data that has to be up to date
+func (modelx *Modelx) primaryQuery(f func(db storage.Querier) error) error {
+	return f(modelx.db)
+}
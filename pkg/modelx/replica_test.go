@@ -0,0 +1,55 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"testing"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadQuery(t *testing.T) {
+	readDB, err := storage.Open(DBConfig{Driver: DriverSQLite, Name: ":memory:"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer func(readDB storage.Storage) {
+		modelx.readDB = readDB
+	}(modelx.readDB)
+	modelx.readDB = readDB
+
+	// the replica is queried first
+	readDB.MustExec("CREATE TABLE block (height INTEGER, base_target INTEGER)")
+	readDB.MustExec("INSERT INTO block (height, base_target) VALUES (1, 18325193796)")
+	assert.Equal(t, 1.0, modelx.GetAVGNetDiff(1))
+	assert.NotEqual(t, 1.0, modelx.avgNetDiff(1, modelx.primaryQuery), "primary query ran on the replica")
+
+	// the primary answers if the replica fails
+	readDB.Close()
+	var height uint64
+	err = modelx.readQuery(func(db storage.Querier) error {
+		return db.Get(&height, "SELECT MAX(height) FROM block")
+	})
+	if assert.Nil(t, err) {
+		assert.NotZero(t, height)
+	}
+}
+
+func TestOpenReadDB(t *testing.T) {
+	defer func(readDB storage.Storage, config DBConfig) {
+		modelx.readDB, Cfg.ReadDB = readDB, config
+	}(modelx.readDB, Cfg.ReadDB)
+
+	Cfg.ReadDB = DBConfig{Driver: "unknown", Name: "replica"}
+	assert.False(t, modelx.openReadDB())
+	assert.Equal(t, modelx.db, modelx.readDB, "primary db not used")
+
+	Cfg.ReadDB = DBConfig{Driver: DriverSQLite, Name: ":memory:"}
+	if assert.True(t, modelx.openReadDB(), "replica not connected on retry") {
+		assert.NotEqual(t, modelx.db, modelx.readDB)
+		modelx.readDB.Close()
+	}
+}
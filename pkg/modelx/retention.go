@@ -0,0 +1,319 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// retentionBatchPause gives other queries a chance to get their locks between batches
+const retentionBatchPause = 100 * time.Millisecond
+
+// archiver keeps a copy of rows before they get deleted. Copies outside of the db are
+// staged and settled by the returned function once it's known whether the deletion
+// was committed.
+type archiver interface {
+	archive(tx storage.Tx, table, column string, keys []int64) (func(committed bool) error, error)
+}
+
+type noArchiver struct{}
+
+func (noArchiver) archive(tx storage.Tx, table, column string, keys []int64) (func(committed bool) error, error) {
+	return nil, nil
+}
+
+// tableArchiver copies rows into <table>_archive
+type tableArchiver struct {
+	db storage.Storage
+}
+
+func (archiver tableArchiver) archive(tx storage.Tx, table, column string,
+	keys []int64) (func(committed bool) error, error) {
+	query, args, err := sqlx.In(archiver.db.InsertIgnore(fmt.Sprintf("`%s_archive`", table),
+		fmt.Sprintf("SELECT * FROM `%s` WHERE %s IN (?)", table, column)), keys)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(query, args...)
+	return nil, err
+}
+
+// fileArchiver appends rows as gzipped JSON lines to <dir>/<table>-<date>.jsonl.gz, every
+// batch is a gzip member of its own. The rows of a batch are written to a temporary file
+// before the deletion is committed and only appended to the archive afterwards. If that
+// fails the temporary file stays, so that no rows get lost.
+type fileArchiver struct {
+	dir string
+}
+
+func (archiver fileArchiver) archive(tx storage.Tx, table, column string,
+	keys []int64) (func(committed bool) error, error) {
+	query, args, err := sqlx.In(fmt.Sprintf("SELECT * FROM `%s` WHERE %s IN (?)", table, column), keys)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []map[string]interface{}
+	for rows.Next() {
+		record := make(map[string]interface{})
+		if err := rows.MapScan(record); err != nil {
+			return nil, err
+		}
+		for k, v := range record {
+			if b, ok := v.([]byte); ok {
+				record[k] = string(b)
+			}
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	tmp, err := ioutil.TempFile(archiver.dir, table+"-*.jsonl.gz.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	if err := appendJSONLines(tmp.Name(), records); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	path := filepath.Join(archiver.dir, fmt.Sprintf("%s-%s.jsonl.gz", table, time.Now().Format("2006-01-02")))
+	return func(committed bool) error {
+		if !committed {
+			return os.Remove(tmp.Name())
+		}
+		if err := appendFile(path, tmp.Name()); err != nil {
+			return fmt.Errorf("appending %s to %s failed: %v", tmp.Name(), path, err)
+		}
+		return nil
+	}, nil
+}
+
+func appendJSONLines(path string, records []map[string]interface{}) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// appendFile appends src to dst and removes it, a missing dst is replaced by src
+func appendFile(dst, src string) error {
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+
+	// a partially appended batch is cut off again, src is still there to retry
+	if _, err := io.Copy(out, in); err != nil {
+		out.Truncate(info.Size())
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Truncate(info.Size())
+		return err
+	}
+	return os.Remove(src)
+}
+
+func newArchiver(db storage.Storage) (archiver, error) {
+	switch Cfg.Retention.Archive {
+	case ArchiveTable:
+		return tableArchiver{db: db}, nil
+	case ArchiveFile:
+		if err := os.MkdirAll(Cfg.Retention.ArchiveDir, 0750); err != nil {
+			return nil, err
+		}
+		return fileArchiver{dir: Cfg.Retention.ArchiveDir}, nil
+	}
+	return noArchiver{}, nil
+}
+
+// dependent are rows that get deleted by a cascade along with the ones of their parent table
+type dependent struct {
+	table  string
+	column string
+}
+
+// purge archives and deletes the rows whose keys are selected by keysSQL in batches of
+// Cfg.Retention.BatchSize, keysSQL gets the batch size as its last argument
+func (modelx *Modelx) purge(archiver archiver, table, column, keysSQL string, dependents []dependent,
+	args ...interface{}) (int, error) {
+	args = append(args, Cfg.Retention.BatchSize)
+
+	deleted := 0
+	for {
+		var keys []int64
+		if err := modelx.db.Select(&keys, keysSQL, args...); err != nil {
+			return deleted, err
+		}
+		if len(keys) == 0 {
+			return deleted, nil
+		}
+
+		if err := modelx.purgeBatch(archiver, table, column, keys, dependents); err != nil {
+			return deleted, err
+		}
+		deleted += len(keys)
+
+		if len(keys) < Cfg.Retention.BatchSize {
+			return deleted, nil
+		}
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+func (modelx *Modelx) purgeBatch(archiver archiver, table, column string, keys []int64, dependents []dependent) error {
+	tx, err := modelx.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	tables := append(append([]dependent(nil), dependents...), dependent{table: table, column: column})
+	var settles []func(committed bool) error
+	settle := func(committed bool) error {
+		var err error
+		for _, settle := range settles {
+			if settleErr := settle(committed); settleErr != nil {
+				err = settleErr
+			}
+		}
+		return err
+	}
+	rollback := func(err error) error {
+		tx.Rollback()
+		settle(false)
+		return err
+	}
+
+	for _, d := range tables {
+		settleArchive, err := archiver.archive(tx, d.table, d.column, keys)
+		if err != nil {
+			return rollback(err)
+		}
+		if settleArchive != nil {
+			settles = append(settles, settleArchive)
+		}
+	}
+
+	query, args, err := sqlx.In(fmt.Sprintf("DELETE FROM `%s` WHERE %s IN (?)", table, column), keys)
+	if err != nil {
+		return rollback(err)
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return rollback(err)
+	}
+	if err := tx.Commit(); err != nil {
+		settle(false)
+		return err
+	}
+
+	// the rows are gone already, so the remaining archives are still written
+	return settle(true)
+}
+
+// applyRetention removes the history that is older than configured. Nonce submissions
+// and transactions go first, so that deleting a block only cascades to a few rows.
+func (modelx *Modelx) applyRetention() {
+	archiver, err := newArchiver(modelx.db)
+	if err != nil {
+		Logger.Error("creating archiver failed", zap.Error(err))
+		return
+	}
+
+	height := Cache.CurrentBlock().Height
+	cutoff := func(keep uint64) uint64 {
+		if height < keep {
+			return 0
+		}
+		return height - keep
+	}
+
+	// the best nonce submission of a block stays, deleting it would cascade to the block
+	n, err := modelx.purge(archiver, "nonce_submission", "id", `SELECT nonce_submission.id FROM nonce_submission
+                  LEFT JOIN block ON block.best_nonce_submission_id = nonce_submission.id
+                WHERE nonce_submission.block_height < ? AND block.height IS NULL
+                ORDER BY nonce_submission.id LIMIT ?`, nil, cutoff(Cfg.Retention.NonceSubmissions))
+	if !logPurge("nonce_submission", n, err) {
+		return
+	}
+
+	n, err = modelx.purge(archiver, "transaction", "id",
+		"SELECT id FROM `transaction` WHERE block_height < ? ORDER BY id LIMIT ?",
+		[]dependent{{table: "transaction_recipient", column: "transaction_id"}}, cutoff(Cfg.Retention.Transactions))
+	// a failure would let the blocks take unarchived transactions with them
+	if !logPurge("transaction", n, err) {
+		return
+	}
+
+	n, err = modelx.purge(archiver, "block", "height",
+		"SELECT height FROM block WHERE height < ? ORDER BY height LIMIT ?",
+		[]dependent{{table: "nonce_submission", column: "block_height"}}, cutoff(Cfg.Retention.Blocks))
+	logPurge("block", n, err)
+}
+
+func logPurge(table string, deleted int, err error) bool {
+	if err != nil {
+		Logger.Error("applying retention failed", zap.String("table", table), zap.Int("deleted", deleted),
+			zap.Error(err))
+		return false
+	}
+	Logger.Info("applied retention", zap.String("table", table), zap.Int("deleted", deleted))
+	return true
+}
@@ -0,0 +1,158 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendJSONLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "block.jsonl.gz")
+
+	assert.Nil(t, appendJSONLines(path, []map[string]interface{}{{"height": 1}, {"height": 2}}))
+	assert.Nil(t, appendJSONLines(path, nil))
+	assert.Nil(t, appendJSONLines(path, []map[string]interface{}{{"height": 3}}))
+
+	f, err := os.Open(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var heights []int
+	dec := json.NewDecoder(zr)
+	for dec.More() {
+		var record struct{ Height int }
+		if !assert.Nil(t, dec.Decode(&record)) {
+			return
+		}
+		heights = append(heights, record.Height)
+	}
+	assert.Equal(t, []int{1, 2, 3}, heights, "batches not appended")
+}
+
+// failingArchiver fails to archive one table after archiving the others
+type failingArchiver struct {
+	archiver
+	table string
+}
+
+func (archiver failingArchiver) archive(tx storage.Tx, table, column string,
+	keys []int64) (func(committed bool) error, error) {
+	if table == archiver.table {
+		return nil, errors.New("archiving failed")
+	}
+	return archiver.archiver.archive(tx, table, column, keys)
+}
+
+func TestPurge(t *testing.T) {
+	defer func(batchSize int) { Cfg.Retention.BatchSize = batchSize }(Cfg.Retention.BatchSize)
+	Cfg.Retention.BatchSize = 2
+
+	heights := []int64{11, 12, 13}
+	insertBlocks := func() {
+		for _, height := range heights {
+			modelx.db.MustExec(`INSERT INTO block (height, base_target, scoop, generation_signature, created)
+                                  VALUES (?, 1, 1, 'gensig', ?)`, height, time.Now())
+			modelx.db.MustExec(`INSERT INTO nonce_submission (miner_id, block_height, deadline, nonce)
+                                  VALUES (?, ?, 100, '1')`, Cfg.FeeAccountID, height)
+		}
+	}
+	count := func(table string) int {
+		var n int
+		modelx.db.Get(&n, "SELECT COUNT(*) FROM "+table+" WHERE height BETWEEN 11 AND 13")
+		return n
+	}
+	defer modelx.db.MustExec("DELETE FROM block WHERE height BETWEEN 11 AND 13")
+	defer modelx.db.MustExec("DELETE FROM block_archive WHERE height BETWEEN 11 AND 13")
+	defer modelx.db.MustExec("DELETE FROM nonce_submission_archive WHERE block_height BETWEEN 11 AND 13")
+
+	purge := func(archiver archiver) (int, error) {
+		return modelx.purge(archiver, "block", "height",
+			"SELECT height FROM block WHERE height < ? ORDER BY height LIMIT ?",
+			[]dependent{{table: "nonce_submission", column: "block_height"}}, 14)
+	}
+
+	insertBlocks()
+	n, err := purge(tableArchiver{db: modelx.db})
+	if assert.Nil(t, err) {
+		assert.Equal(t, len(heights), n, "not all batches purged")
+	}
+	assert.Equal(t, 0, count("block"))
+	assert.Equal(t, len(heights), count("block_archive"))
+	var archivedSubmissions int
+	modelx.db.Get(&archivedSubmissions,
+		"SELECT COUNT(*) FROM nonce_submission_archive WHERE block_height BETWEEN 11 AND 13")
+	assert.Equal(t, len(heights), archivedSubmissions, "cascaded rows not archived")
+
+	dir, err := ioutil.TempDir("", "archive")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	files := fileArchiver{dir: dir}
+
+	// nothing is written to the files if the batch isn't deleted
+	insertBlocks()
+	assert.NotNil(t, modelx.purgeBatch(failingArchiver{files, "block"}, "block", "height", heights,
+		[]dependent{{table: "nonce_submission", column: "block_height"}}))
+	assert.Equal(t, len(heights), count("block"), "batch deleted although archiving failed")
+	written, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, written, "rows of a rolled back batch archived")
+
+	n, err = purge(files)
+	if assert.Nil(t, err) {
+		assert.Equal(t, len(heights), n)
+	}
+	for _, table := range []string{"block", "nonce_submission"} {
+		written, _ := filepath.Glob(filepath.Join(dir, table+"-*.jsonl.gz"))
+		assert.Len(t, written, 1, "no archive of "+table)
+	}
+	staged, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Empty(t, staged, "staged batches not removed")
+}
+
+func TestAppendFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "block.jsonl.gz")
+
+	for _, content := range []string{"first", "second"} {
+		src := filepath.Join(dir, content+".tmp")
+		if !assert.Nil(t, ioutil.WriteFile(src, []byte(content), 0640)) {
+			return
+		}
+		assert.Nil(t, appendFile(dst, src))
+		_, err := os.Stat(src)
+		assert.True(t, os.IsNotExist(err), "appended file not removed")
+	}
+
+	content, err := ioutil.ReadFile(dst)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "firstsecond", string(content))
+	}
+}
@@ -0,0 +1,146 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"math"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+// shareBatchSize is the number of miners whose shares or capacities are written by
+// one statement
+const shareBatchSize = 500
+
+// shareDelta is the relative difference up to which an EEPS from the cache and one
+// from the submissions in the db are equal
+const shareDelta = 1e-9
+
+var blockShareColumns = []string{"block_height", "miner_id", "eeps"}
+var blockShareKeys = []string{"block_height", "miner_id"}
+
+// storeCachedShares stores the EEPS of the miners in the cache as their shares on the
+// closed block at height. If the next block was closed meanwhile the cache covers a
+// later window, the shares are computed from the submissions in the db then.
+func (modelx *Modelx) storeCachedShares(height uint64) {
+	// the cache must not move on to another block meanwhile
+	modelx.newBlockMu.Lock()
+	if Cache.CurrentBlock().Height != height+1 {
+		modelx.newBlockMu.Unlock()
+		Logger.Info("next block closed within the grace period, shares are taken from the db",
+			zap.Uint64("height", height))
+		return
+	}
+	eepsOf := make(map[uint64]float64)
+	Cache.MinerRange(func(_, value interface{}) bool {
+		miner := value.(*Miner)
+		miner.Lock()
+		if len(miner.DeadlinesParams) != 0 {
+			eepsOf[miner.ID] = miner.CalculateEEPS()
+		}
+		miner.Unlock()
+		return true
+	})
+	modelx.newBlockMu.Unlock()
+
+	if err := modelx.storeShares(height, eepsOf); err != nil {
+		Logger.Error("storing shares failed", zap.Uint64("height", height), zap.Error(err))
+	}
+}
+
+// storeShares stores the EEPS of the miners on a closed block, which the rewards are
+// shared by, and their capacities
+func (modelx *Modelx) storeShares(height uint64, eepsOf map[uint64]float64) error {
+	minerIDs := make([]uint64, 0, len(eepsOf))
+	for minerID := range eepsOf {
+		minerIDs = append(minerIDs, minerID)
+	}
+
+	return modelx.health.retry("store shares", func() error {
+		tx, err := modelx.db.Begin()
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(minerIDs); start += shareBatchSize {
+			batch := minerIDs[start:int(math.Min(float64(start+shareBatchSize), float64(len(minerIDs))))]
+			if err := modelx.storeShareBatch(tx, height, batch, eepsOf); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+func (modelx *Modelx) storeShareBatch(tx storage.Tx, height uint64, minerIDs []uint64,
+	eepsOf map[uint64]float64) error {
+	shareArgs := make([]interface{}, 0, len(minerIDs)*len(blockShareColumns))
+	capacityArgs := make([]interface{}, 0, len(minerIDs)*3)
+	for _, minerID := range minerIDs {
+		shareArgs = append(shareArgs, height, minerID, eepsOf[minerID])
+		capacityArgs = append(capacityArgs, minerID, int32(eepsOf[minerID]*1000.0))
+	}
+	for _, minerID := range minerIDs {
+		capacityArgs = append(capacityArgs, minerID)
+	}
+
+	_, err := tx.Exec(modelx.db.Upsert("block_share", blockShareColumns, blockShareKeys, len(minerIDs)),
+		shareArgs...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE miner SET capacity = CASE id"+strings.Repeat(" WHEN ? THEN ?", len(minerIDs))+
+		" ELSE capacity END WHERE id IN (?"+strings.Repeat(", ?", len(minerIDs)-1)+")", capacityArgs...)
+	return err
+}
+
+// storedEEPSs yields the EEPS of the miners stored when the block was closed
+func (modelx *Modelx) storedEEPSs(height uint64) (map[uint64]float64, error) {
+	var shares []struct {
+		MinerID uint64  `db:"miner_id"`
+		EEPS    float64 `db:"eeps"`
+	}
+	err := modelx.db.Select(&shares, "SELECT miner_id, eeps FROM block_share WHERE block_height = ?", height)
+	if err != nil {
+		return nil, err
+	}
+
+	eepsOf := make(map[uint64]float64, len(shares))
+	for _, share := range shares {
+		eepsOf[share.MinerID] = share.EEPS
+	}
+	return eepsOf, nil
+}
+
+// verifyShares compares the EEPS from the cache with the ones computed from the
+// submissions in the db
+func (modelx *Modelx) verifyShares(height uint64, eepsOf map[uint64]float64) {
+	dbEEPSOf, _, err := modelx.GetEEPSsOnBlock(height, false)
+	if err != nil {
+		Logger.Error("computing shares for verification failed", zap.Uint64("height", height), zap.Error(err))
+		return
+	}
+
+	var mismatches int
+	for minerID := range dbEEPSOf {
+		if _, exists := eepsOf[minerID]; !exists && dbEEPSOf[minerID] != 0 {
+			mismatches++
+		}
+	}
+	for minerID, eeps := range eepsOf {
+		dbEEPS := dbEEPSOf[minerID]
+		if math.Abs(eeps-dbEEPS) > shareDelta*math.Max(eeps, dbEEPS) {
+			mismatches++
+			Logger.Warn("share of miner differs from db", zap.Uint64("height", height),
+				zap.Uint64("minerID", minerID), zap.Float64("eeps", eeps), zap.Float64("dbEEPS", dbEEPS))
+		}
+	}
+	if mismatches > 0 {
+		Logger.Warn("shares differ from db", zap.Uint64("height", height), zap.Int("mismatches", mismatches))
+	}
+}
@@ -0,0 +1,81 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreShares(t *testing.T) {
+	height := uint64(493725)
+	dbSharesOf, err := modelx.GetSharesOnBlock(height)
+	if !assert.Nil(t, err) {
+		return
+	}
+	dbEEPSOf, _, err := modelx.GetEEPSsOnBlock(height, false)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// shares from the cache are preferred over the submissions in the db
+	eepsOf := make(map[uint64]float64, len(dbEEPSOf))
+	for minerID, eeps := range dbEEPSOf {
+		eepsOf[minerID] = eeps
+	}
+	minerID := uint64(8686227335924170201)
+	eepsOf[minerID] = 2 * dbEEPSOf[minerID]
+	if !assert.Nil(t, modelx.storeShares(height, eepsOf)) {
+		return
+	}
+	defer modelx.db.MustExec("DELETE FROM block_share WHERE block_height = ?", height)
+
+	storedEEPSOf, err := modelx.storedEEPSs(height)
+	if assert.Nil(t, err) {
+		assert.Equal(t, len(eepsOf), len(storedEEPSOf))
+		assert.InDelta(t, eepsOf[minerID], storedEEPSOf[minerID], eepsOf[minerID]*shareDelta)
+	}
+
+	var capacity int32
+	if assert.Nil(t, modelx.db.Get(&capacity, "SELECT capacity FROM miner WHERE id = ?", minerID)) {
+		assert.Equal(t, int32(eepsOf[minerID]*1000.0), capacity)
+	}
+
+	sharesOf, err := modelx.GetSharesOnBlock(height)
+	if assert.Nil(t, err) {
+		assert.Equal(t, len(dbSharesOf), len(sharesOf))
+		assert.True(t, sharesOf[minerID] > dbSharesOf[minerID], "shares not taken from the cache")
+	}
+}
+
+func TestStoreCachedShares(t *testing.T) {
+	height := uint64(493725)
+	defer Cache.StoreCurrentBlock(Cache.CurrentBlock())
+	defer modelx.db.MustExec("DELETE FROM block_share WHERE block_height = ?", height)
+
+	// the cache moved on by more than one block
+	Cache.StoreCurrentBlock(Block{Height: height + 2})
+	modelx.storeCachedShares(height)
+	storedEEPSOf, err := modelx.storedEEPSs(height)
+	if !assert.Nil(t, err) || !assert.Empty(t, storedEEPSOf, "shares of a later window stored") {
+		return
+	}
+
+	Cache.StoreCurrentBlock(Block{Height: height + 1})
+	modelx.storeCachedShares(height)
+	storedEEPSOf, err = modelx.storedEEPSs(height)
+	if !assert.Nil(t, err) || !assert.NotEmpty(t, storedEEPSOf, "no shares stored") {
+		return
+	}
+	Cache.MinerRange(func(_, value interface{}) bool {
+		miner := value.(*Miner)
+		eeps, stored := storedEEPSOf[miner.ID]
+		if len(miner.DeadlinesParams) == 0 {
+			assert.False(t, stored, "share of a miner without deadlines stored")
+		} else if assert.True(t, stored, "share not stored") {
+			assert.InDelta(t, miner.CalculateEEPS(), eeps, eeps*shareDelta)
+		}
+		return true
+	})
+}
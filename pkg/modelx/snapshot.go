@@ -0,0 +1,357 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"encoding/gob"
+	"os"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// snapshotVersion changes with the layout of cacheSnapshot
+const snapshotVersion = 1
+
+type minerSnapshot struct {
+	ID                    uint64
+	Address               string
+	Name                  string
+	Pending               int64
+	PayoutDetail          string
+	CurrentDeadlineParams *DeadlineParams
+	DeadlinesParams       map[uint64]*DeadlineParams
+	WeightedDeadlineSum   float64
+}
+
+// cacheSnapshot is the state of the cache at a height, which is expensive to rebuild
+// from the db
+type cacheSnapshot struct {
+	Version             int
+	NAVG                int
+	TMin                int32
+	Height              uint64
+	GenerationSignature string
+	Miners              []minerSnapshot
+	SlowBlocks          []uint64
+	FastBlocks          []uint64
+	RewardRecipients    map[uint64]RewardRecipient
+	BestNonceSubmission NonceSubmission
+}
+
+func (modelx *Modelx) snapshotJob() {
+	for range time.Tick(Cfg.Snapshot.IntervalDur) {
+		if err := modelx.SaveSnapshot(); err != nil {
+			Logger.Error("saving cache snapshot failed", zap.Error(err))
+		}
+	}
+}
+
+// SaveSnapshot writes the cache to the snapshot file
+func (modelx *Modelx) SaveSnapshot() error {
+	// the cache must not move on to another block meanwhile
+	modelx.newBlockMu.Lock()
+	currentBlock := Cache.CurrentBlock()
+	snapshot := cacheSnapshot{
+		Version:             snapshotVersion,
+		NAVG:                Cfg.NAVG,
+		TMin:                Cfg.TMin,
+		Height:              currentBlock.Height,
+		GenerationSignature: currentBlock.GenerationSignature,
+		SlowBlocks:          Cache.slowBlocks.list(),
+		FastBlocks:          Cache.fastBlocks.list(),
+		RewardRecipients:    Cache.RewardRecipients(),
+		BestNonceSubmission: Cache.BestNonceSubmission()}
+	Cache.MinerRange(func(_, value interface{}) bool {
+		miner := value.(*Miner)
+		miner.Lock()
+		ms := minerSnapshot{
+			ID:                  miner.ID,
+			Address:             miner.Address,
+			Name:                miner.Name,
+			Pending:             miner.Pending,
+			PayoutDetail:        miner.PayoutDetail,
+			DeadlinesParams:     make(map[uint64]*DeadlineParams, len(miner.DeadlinesParams)),
+			WeightedDeadlineSum: miner.WeightedDeadlineSum}
+		if miner.CurrentDeadlineParams != nil {
+			dp := *miner.CurrentDeadlineParams
+			ms.CurrentDeadlineParams = &dp
+		}
+		for height, dp := range miner.DeadlinesParams {
+			dp := *dp
+			ms.DeadlinesParams[height] = &dp
+		}
+		miner.Unlock()
+		snapshot.Miners = append(snapshot.Miners, ms)
+		return true
+	})
+	modelx.newBlockMu.Unlock()
+
+	// a crash while writing must not destroy the previous snapshot
+	tmp := Cfg.Snapshot.File + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(&snapshot); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, Cfg.Snapshot.File); err != nil {
+		return err
+	}
+
+	Logger.Info("saved cache snapshot", zap.Uint64("height", snapshot.Height),
+		zap.Int("miners", len(snapshot.Miners)))
+	return nil
+}
+
+func readSnapshot(path string) (*cacheSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot cacheSnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// loadSnapshot restores the cache from the snapshot file if it fits the current block
+// of the db, the blocks and submissions that came after it are added from the db
+func (modelx *Modelx) loadSnapshot() bool {
+	snapshot, err := readSnapshot(Cfg.Snapshot.File)
+	if os.IsNotExist(err) {
+		return false
+	} else if err != nil {
+		Logger.Warn("reading cache snapshot failed", zap.Error(err))
+		return false
+	}
+
+	currentBlock := Cache.CurrentBlock()
+	switch {
+	case snapshot.Version != snapshotVersion || snapshot.NAVG != Cfg.NAVG || snapshot.TMin != Cfg.TMin:
+		Logger.Info("ignoring cache snapshot of other settings")
+		return false
+	case snapshot.Height > currentBlock.Height || currentBlock.Height-snapshot.Height > uint64(Cfg.NAVG):
+		Logger.Info("ignoring cache snapshot that doesn't fit the db", zap.Uint64("height", snapshot.Height),
+			zap.Uint64("dbHeight", currentBlock.Height))
+		return false
+	}
+
+	var genSig string
+	err = modelx.db.Get(&genSig, "SELECT generation_signature FROM block WHERE height = ?", snapshot.Height)
+	if err != nil || genSig != snapshot.GenerationSignature {
+		Logger.Info("ignoring cache snapshot of another fork", zap.Uint64("height", snapshot.Height))
+		return false
+	}
+
+	topUp, err := modelx.readTopUp(snapshot, currentBlock.Height)
+	if err != nil {
+		Logger.Warn("reading blocks and submissions after cache snapshot failed", zap.Error(err))
+		return false
+	}
+
+	for _, height := range snapshot.SlowBlocks {
+		Cache.slowBlocks.add(height)
+	}
+	for _, height := range snapshot.FastBlocks {
+		Cache.fastBlocks.add(height)
+	}
+	for i := range snapshot.Miners {
+		Cache.LoadOrStoreMiner(snapshot.Miners[i].miner())
+	}
+	if snapshot.RewardRecipients != nil {
+		Cache.StoreRewardRecipients(snapshot.RewardRecipients)
+	}
+	if snapshot.BestNonceSubmission.Height == currentBlock.Height {
+		Cache.StoreBestNonceSubmission(snapshot.BestNonceSubmission)
+	}
+
+	topUp.apply(snapshot.Height, currentBlock.Height)
+
+	Logger.Info("restored cache from snapshot", zap.Uint64("height", snapshot.Height),
+		zap.Int("miners", len(snapshot.Miners)), zap.Int("newSubmissions", len(topUp.submissions)))
+	return true
+}
+
+func (ms *minerSnapshot) miner() *Miner {
+	miner := &Miner{
+		ID:                    ms.ID,
+		Address:               ms.Address,
+		Name:                  ms.Name,
+		Pending:               ms.Pending,
+		PayoutDetail:          ms.PayoutDetail,
+		CurrentDeadlineParams: ms.CurrentDeadlineParams,
+		DeadlinesParams:       ms.DeadlinesParams,
+		WeightedDeadlineSum:   ms.WeightedDeadlineSum}
+	if miner.DeadlinesParams == nil {
+		miner.DeadlinesParams = make(map[uint64]*DeadlineParams)
+	}
+	// the current submission is shared with the ones of the slow blocks
+	if dp, exists := miner.DeadlinesParams[miner.CurrentBlockHeight()]; exists {
+		miner.CurrentDeadlineParams = dp
+	}
+	return miner
+}
+
+type topUpSubmission struct {
+	MinerID    uint64 `db:"miner_id"`
+	Height     uint64 `db:"block_height"`
+	Deadline   uint64 `db:"deadline"`
+	BaseTarget uint64 `db:"base_target"`
+}
+
+type topUpAccount struct {
+	ID             uint64     `db:"id"`
+	Name           string     `db:"name"`
+	Pending        int64      `db:"pending"`
+	MinPayoutValue *int64     `db:"min_payout_value"`
+	PayoutInterval *string    `db:"payout_interval"`
+	NextPayoutDate *time.Time `db:"next_payout_date"`
+}
+
+// cacheTopUp holds what happened in the db after a snapshot was taken
+type cacheTopUp struct {
+	generationTimes map[uint64]int32
+	submissions     []topUpSubmission
+	newMiners       map[uint64]*Miner
+	accounts        []topUpAccount
+}
+
+// readTopUp reads the generation times of the blocks from the snapshot's height on and
+// their submissions, so they can be applied to the cache without failing midway
+func (modelx *Modelx) readTopUp(snapshot *cacheSnapshot, height uint64) (*cacheTopUp, error) {
+	var blocks []struct {
+		Height         uint64 `db:"height"`
+		GenerationTime int32  `db:"generation_time"`
+	}
+	err := modelx.db.Select(&blocks, "SELECT height, generation_time FROM block WHERE height >= ? AND height < ?",
+		snapshot.Height, height)
+	if err != nil {
+		return nil, err
+	}
+
+	topUp := cacheTopUp{
+		generationTimes: make(map[uint64]int32),
+		newMiners:       make(map[uint64]*Miner)}
+	for _, block := range blocks {
+		topUp.generationTimes[block.Height] = block.GenerationTime
+	}
+
+	// submissions at the snapshot's height might have been improved after it was taken
+	err = modelx.db.Select(&topUp.submissions, `SELECT miner_id, block_height, deadline, block.base_target
+                FROM nonce_submission JOIN block ON block.height = nonce_submission.block_height
+                WHERE block_height >= ? ORDER BY block_height ASC`, snapshot.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	// payouts, rewards and settings changed the accounts since the snapshot was taken
+	err = modelx.db.Select(&topUp.accounts, `SELECT account.id, COALESCE(name, '') "name", pending,
+                  min_payout_value, payout_interval, next_payout_date
+                FROM account JOIN miner ON miner.id = account.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[uint64]bool, len(snapshot.Miners))
+	for _, ms := range snapshot.Miners {
+		known[ms.ID] = true
+	}
+	for _, submission := range topUp.submissions {
+		if known[submission.MinerID] || topUp.newMiners[submission.MinerID] != nil {
+			continue
+		}
+		if miner := modelx.getMinerFromDB(submission.MinerID); miner != nil {
+			topUp.newMiners[submission.MinerID] = miner
+		}
+	}
+	return &topUp, nil
+}
+
+// apply replays the blocks from the snapshot's height to the current one on the cache
+// like newBlock and UpdateOrCreateNonceSubmission did
+func (topUp *cacheTopUp) apply(from, to uint64) {
+	for _, miner := range topUp.newMiners {
+		Cache.LoadOrStoreMiner(miner)
+	}
+	for _, account := range topUp.accounts {
+		if miner := Cache.GetMiner(account.ID); miner != nil {
+			miner.Lock()
+			miner.Name = account.Name
+			miner.Pending = account.Pending
+			miner.PayoutDetail = payoutDetail(account.MinPayoutValue, account.PayoutInterval,
+				account.NextPayoutDate)
+			miner.Unlock()
+		}
+	}
+
+	i := 0
+	for height := from; height <= to; height++ {
+		for ; i < len(topUp.submissions) && topUp.submissions[i].Height == height; i++ {
+			submission := topUp.submissions[i]
+			if miner := Cache.GetMiner(submission.MinerID); miner != nil {
+				miner.Lock()
+				miner.setDeadline(submission.Height, submission.Deadline, submission.BaseTarget)
+				miner.Unlock()
+			}
+		}
+
+		generationTime, exists := topUp.generationTimes[height]
+		if height == to || !exists {
+			continue
+		}
+		removedHeight := Cache.AddBlock(height, generationTime)
+		Cache.MinerRange(func(key, value interface{}) bool {
+			miner := value.(*Miner)
+			miner.Lock()
+			if slow, _ := Cache.WasSlowBlock(miner.CurrentBlockHeight()); slow {
+				miner.addDeadlineParams()
+			}
+			if removedHeight != 0 {
+				miner.removeDeadlineParams(removedHeight)
+			}
+			if len(miner.DeadlinesParams) == 0 && miner.CurrentBlockHeight() < height+1-Cfg.InactiveAfterXBlocks {
+				Cache.DeleteMiner(key.(uint64))
+			}
+			miner.Unlock()
+			return true
+		})
+	}
+}
+
+// setDeadline sets the deadline of the miner at a height that isn't older than its
+// current one
+func (miner *Miner) setDeadline(height, deadline, baseTarget uint64) {
+	if dp, exists := miner.DeadlinesParams[height]; exists {
+		miner.WeightedDeadlineSum += weightDeadline(deadline, baseTarget) -
+			weightDeadline(dp.Deadline, dp.BaseTarget)
+	}
+	if miner.CurrentBlockHeight() == height {
+		miner.CurrentDeadlineParams.Deadline = deadline
+		miner.CurrentDeadlineParams.BaseTarget = baseTarget
+		return
+	}
+	if dp, exists := miner.DeadlinesParams[height]; exists {
+		dp.Deadline = deadline
+		dp.BaseTarget = baseTarget
+		return
+	}
+	if height > miner.CurrentBlockHeight() {
+		miner.CurrentDeadlineParams = &DeadlineParams{Height: height, Deadline: deadline, BaseTarget: baseTarget}
+	}
+}
@@ -0,0 +1,93 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	cached := Cache
+	defer func() {
+		Cache = cached
+	}()
+	defer os.Remove(Cfg.Snapshot.File)
+
+	// earlier tests leave blocks in the cache that aren't in the db anymore
+	assert.True(t, modelx.loadCurrentBlock())
+	if !assert.Nil(t, modelx.SaveSnapshot()) {
+		return
+	}
+
+	// a miner that submitted after the snapshot was taken
+	height := Cache.CurrentBlock().Height
+	minerID := uint64(42424250)
+	modelx.db.MustExec("INSERT INTO account (id, address) VALUES (?, ?)", minerID, minerID)
+	defer modelx.db.MustExec("DELETE FROM account WHERE id = ?", minerID)
+	modelx.db.MustExec("INSERT INTO miner (id) VALUES (?)", minerID)
+	modelx.db.MustExec("INSERT INTO nonce_submission (miner_id, block_height, deadline, nonce) VALUES (?, ?, 42, 1)",
+		minerID, height)
+
+	// an account that changed after the snapshot was taken
+	var changed *Miner
+	cached.MinerRange(func(_, value interface{}) bool {
+		changed = value.(*Miner)
+		return false
+	})
+	if !assert.NotNil(t, changed, "no cached miner") {
+		return
+	}
+	var pending int64
+	modelx.db.Get(&pending, "SELECT pending FROM account WHERE id = ?", changed.ID)
+	defer modelx.db.MustExec("UPDATE account SET pending = ?, min_payout_value = NULL WHERE id = ?",
+		pending, changed.ID)
+	modelx.db.MustExec("UPDATE account SET pending = ?, min_payout_value = 4242 WHERE id = ?",
+		pending+4242, changed.ID)
+
+	InitCache()
+	assert.True(t, modelx.loadCurrentBlock())
+	if !assert.True(t, modelx.loadSnapshot(), "snapshot not loaded") {
+		return
+	}
+
+	assert.Equal(t, cached.slowBlocks.list(), Cache.slowBlocks.list())
+	assert.Equal(t, cached.fastBlocks.list(), Cache.fastBlocks.list())
+	cached.MinerRange(func(key, value interface{}) bool {
+		miner := value.(*Miner)
+		restored := Cache.GetMiner(miner.ID)
+		if !assert.NotNil(t, restored, "miner not restored", miner.ID) {
+			return true
+		}
+		assert.Equal(t, miner.CurrentBlockHeight(), restored.CurrentBlockHeight())
+		assert.Equal(t, miner.CurrentDeadline(), restored.CurrentDeadline())
+		assert.Equal(t, len(miner.DeadlinesParams), len(restored.DeadlinesParams))
+		assert.InDelta(t, miner.WeightedDeadlineSum, restored.WeightedDeadlineSum,
+			miner.WeightedDeadlineSum*floatSumDelta)
+		return true
+	})
+
+	if restored := Cache.GetMiner(changed.ID); assert.NotNil(t, restored) {
+		assert.Equal(t, pending+4242, restored.Pending, "pending not refreshed")
+		assert.Equal(t, "4242", restored.PayoutDetail, "payout detail not refreshed")
+	}
+
+	miner := Cache.GetMiner(minerID)
+	if assert.NotNil(t, miner, "submission after snapshot not added") {
+		assert.Equal(t, uint64(42), miner.CurrentDeadline())
+		assert.Equal(t, height, miner.CurrentBlockHeight())
+	}
+
+	// snapshots of other settings are rebuilt from the db
+	defer func(nAvg int) {
+		Cfg.NAVG = nAvg
+	}(Cfg.NAVG)
+	Cfg.NAVG++
+	InitCache()
+	assert.True(t, modelx.loadCurrentBlock())
+	assert.False(t, modelx.loadSnapshot(), "snapshot of other settings loaded")
+}
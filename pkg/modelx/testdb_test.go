@@ -0,0 +1,60 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/storage"
+)
+
+// openTestDB creates the db of config.yaml, an in-memory one, with the schema of the
+// migrations and the rows of testdata.sql, so the tests don't need a database server
+func openTestDB() (storage.Storage, error) {
+	fixture, err := ioutil.ReadFile("testdata.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := storage.Open(Cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	// migrations are looked up relative to the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir("../.."); err != nil {
+		return nil, err
+	}
+	err = db.Migrate()
+	os.Chdir(wd)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// blocks and their best nonce submissions reference each other
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, stmt := range strings.Split(string(fixture), "\n") {
+		if !strings.HasPrefix(stmt, "INSERT") {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return db, tx.Commit()
+}
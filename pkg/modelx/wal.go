@@ -0,0 +1,189 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package modelx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// walRecordSize is the size of a logged submission: miner id, height, deadline and
+// nonce followed by their checksum
+const walRecordSize = 4*8 + 4
+
+var errWALFull = errors.New("nonce submission log is full")
+
+type walRecord struct {
+	key   nonceKey
+	write nonceWrite
+}
+
+type walSegment struct {
+	path string
+	size int64
+}
+
+// nonceWAL logs queued nonce submissions to disk, so they survive a restart while the
+// db is unavailable. Submissions are appended to the current segment, on every flush
+// the segment gets sealed and a new one is started. Sealed segments are removed once
+// their submissions are written or logged again.
+type nonceWAL struct {
+	dir     string
+	maxSize int64
+	sync    bool
+
+	file   *os.File
+	seq    uint64
+	size   int64
+	sealed []walSegment
+}
+
+// openNonceWAL opens the log in dir and returns the submissions of a previous run
+// that weren't written to the db
+func openNonceWAL(dir string, maxSize int64, sync bool) (*nonceWAL, []walRecord, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+
+	wal := &nonceWAL{dir: dir, maxSize: maxSize, sync: sync}
+	var records []walRecord
+	for _, path := range paths {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), ".wal"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segmentRecords, size, err := readWALSegment(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, segmentRecords...)
+		wal.sealed = append(wal.sealed, walSegment{path: path, size: size})
+		if seq >= wal.seq {
+			wal.seq = seq + 1
+		}
+	}
+
+	if err := wal.openSegment(); err != nil {
+		return nil, nil, err
+	}
+	return wal, records, nil
+}
+
+// readWALSegment reads the records of a segment up to the first incomplete or
+// corrupted one, which is left by a crash while writing
+func readWALSegment(path string) ([]walRecord, int64, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []walRecord
+	for offset := 0; offset+walRecordSize <= len(raw); offset += walRecordSize {
+		record := raw[offset : offset+walRecordSize]
+		if crc32.ChecksumIEEE(record[:32]) != binary.LittleEndian.Uint32(record[32:]) {
+			Logger.Warn("ignoring corrupted end of nonce submission log", zap.String("path", path),
+				zap.Int("offset", offset))
+			break
+		}
+		records = append(records, walRecord{
+			key: nonceKey{
+				minerID: binary.LittleEndian.Uint64(record[0:]),
+				height:  binary.LittleEndian.Uint64(record[8:])},
+			write: nonceWrite{
+				deadline: binary.LittleEndian.Uint64(record[16:]),
+				nonce:    binary.LittleEndian.Uint64(record[24:])}})
+	}
+	return records, int64(len(raw)), nil
+}
+
+func (wal *nonceWAL) openSegment() error {
+	path := filepath.Join(wal.dir, fmt.Sprintf("%020d.wal", wal.seq))
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	wal.file = file
+	wal.seq++
+	wal.size = 0
+	return nil
+}
+
+// totalSize is the size of all segments on disk
+func (wal *nonceWAL) totalSize() int64 {
+	size := wal.size
+	for _, segment := range wal.sealed {
+		size += segment.size
+	}
+	return size
+}
+
+// append logs a submission, unless this would exceed the size limit. Submissions that
+// are logged already, e.g. ones that failed to be written, are forced into the log.
+func (wal *nonceWAL) append(key nonceKey, deadline, nonce uint64, force bool) error {
+	if wal.file == nil {
+		if err := wal.openSegment(); err != nil {
+			return err
+		}
+	}
+	if !force && wal.totalSize()+walRecordSize > wal.maxSize {
+		return errWALFull
+	}
+
+	record := make([]byte, walRecordSize)
+	binary.LittleEndian.PutUint64(record[0:], key.minerID)
+	binary.LittleEndian.PutUint64(record[8:], key.height)
+	binary.LittleEndian.PutUint64(record[16:], deadline)
+	binary.LittleEndian.PutUint64(record[24:], nonce)
+	binary.LittleEndian.PutUint32(record[32:], crc32.ChecksumIEEE(record[:32]))
+
+	n, err := wal.file.Write(record)
+	wal.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if wal.sync {
+		return wal.file.Sync()
+	}
+	return nil
+}
+
+// rotate seals the current segment and returns the number of sealed segments
+func (wal *nonceWAL) rotate() (int, error) {
+	if wal.file != nil {
+		if err := wal.file.Close(); err != nil {
+			return 0, err
+		}
+		wal.sealed = append(wal.sealed, walSegment{path: wal.file.Name(), size: wal.size})
+		wal.file = nil
+	}
+	return len(wal.sealed), wal.openSegment()
+}
+
+// removeSealed removes the n oldest sealed segments
+func (wal *nonceWAL) removeSealed(n int) error {
+	for n > 0 {
+		if err := os.Remove(wal.sealed[0].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		wal.sealed = wal.sealed[1:]
+		n--
+	}
+	return nil
+}
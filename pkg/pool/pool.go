@@ -10,14 +10,17 @@ import (
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 	"math"
 
 	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
 	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
 	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
 	. "github.com/PoC-Consortium/Nogrod/pkg/modelx"
 	"github.com/PoC-Consortium/Nogrod/pkg/nodecom"
+	"github.com/PoC-Consortium/Nogrod/pkg/proxyproto"
 	"github.com/PoC-Consortium/Nogrod/pkg/wallethandler"
 
 	"github.com/throttled/throttled"
@@ -26,12 +29,18 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
 )
 
 const (
-	submitBefore           = 30 * time.Second
-	nonceSubmissionRetries = 3
+	// the outcome of a failing submission is stored after nonceSubmissionRetries
+	// tries already, retrying goes on until the round is over
+	nonceSubmissionRetries    = 3
+	nonceSubmissionRetryDelay = time.Second
+	clockOffsetInterval       = 10 * time.Minute
+
+	bestNonceSubmissionSyncInterval = time.Second
 )
 
 type Pool struct {
@@ -55,6 +64,8 @@ func NewPool(modelx *Modelx, walletHandler wallethandler.WalletHandler) *Pool {
 
 	currentBlock := Cache.CurrentBlock()
 
+	pool.updateClockOffset()
+	go pool.updateClockOffsetJob()
 	go pool.checkAndAddNewBlockJob()
 	go pool.forge(currentBlock)
 
@@ -65,12 +76,25 @@ func (pool *Pool) forge(currentBlock Block) {
 	var bestNonceSubmission *NonceSubmission
 	maxTime := time.Duration(1<<63 - 1)
 
+	// height on which the best nonce was already submitted, better deadlines
+	// arriving afterwards are submitted right away
+	var submittedHeight uint64
+
+	// a better deadline supersedes the submission still being retried
+	cancelSubmission := func() {}
+	submit := func(nonceSubmission *NonceSubmission) {
+		cancelSubmission()
+		var ctx context.Context
+		ctx, cancelSubmission = context.WithCancel(context.Background())
+		go pool.submitNonce(ctx, nonceSubmission)
+	}
+
 	var after <-chan time.Time
 	updateSubmitTimer := func(deadline uint64, roundStart time.Time) {
 		if Cfg.SodiumDeadlines && deadline > 0 {
 			deadline = uint64(math.Log(float64(deadline))*240/math.Log(240))
 		}
-		due := time.Duration(deadline)*time.Second - time.Since(roundStart) - submitBefore
+		due := time.Duration(deadline)*time.Second - time.Since(roundStart) - Cfg.SubmitBeforeDur
 		Logger.Info("planning submitNonce", zap.Duration("delay", due))
 		after = time.After(due)
 	}
@@ -89,44 +113,119 @@ func (pool *Pool) forge(currentBlock Block) {
 		after = time.After(maxTime)
 	}
 
+	newBestNonceSubmission := func(nonceSubmission *NonceSubmission) {
+		// ignore old blocks
+		if nonceSubmission.Height < bestNonceSubmission.Height {
+			return
+		}
+
+		// ignore worse deadlines
+		if nonceSubmission.GenerationSignature == bestNonceSubmission.GenerationSignature &&
+			nonceSubmission.Deadline >= bestNonceSubmission.Deadline {
+			return
+		}
+
+		Logger.Info("new best deadline", zap.Uint64("deadline", nonceSubmission.Deadline))
+		bestNonceSubmission = nonceSubmission
+		if err := pool.modelx.UpdateBestSubmission(nonceSubmission.MinerID, nonceSubmission.Height); err != nil {
+			Logger.Error("storing best deadline failed", zap.Error(err))
+		}
+		Cache.StoreBestNonceSubmission(*bestNonceSubmission)
+		pool.modelx.Events().Publish(events.NewBestDeadline{
+			Height:   nonceSubmission.Height,
+			MinerID:  nonceSubmission.MinerID,
+			Name:     nonceSubmission.Name,
+			Address:  nonceSubmission.Address,
+			Deadline: nonceSubmission.Deadline})
+
+		if submittedHeight == nonceSubmission.Height && pool.modelx.IsLeader() {
+			Logger.Info("resubmitting better deadline", zap.Uint64("deadline", nonceSubmission.Deadline))
+			after = time.After(maxTime)
+			submit(nonceSubmission)
+			return
+		}
+		updateSubmitTimer(nonceSubmission.Deadline, nonceSubmission.RoundStart)
+	}
+
+	// the leader picks up the deadlines other instances received from the db
+	var syncBest <-chan time.Time
+	if Cfg.LeaderElection.Enabled {
+		syncBest = time.Tick(bestNonceSubmissionSyncInterval)
+	}
+
 	for {
 		select {
 		case nonceSubmission := <-pool.nonceSubmissions:
-			// ignore old blocks
-			if nonceSubmission.Height < bestNonceSubmission.Height {
+			// in proxy mode the best deadline of every account goes upstream, with several
+			// instances it's forwarded by the one that received it
+			if Cfg.UpstreamPoolURL != "" {
+				go pool.forwardNonce(nonceSubmission)
+			}
+			newBestNonceSubmission(nonceSubmission)
+		case <-syncBest:
+			if !pool.modelx.IsLeader() {
 				continue
 			}
-
-			// ignore worse deadlines
-			if nonceSubmission.GenerationSignature == bestNonceSubmission.GenerationSignature &&
-				nonceSubmission.Deadline >= bestNonceSubmission.Deadline {
+			currentBlock := Cache.CurrentBlock()
+			nonceSubmission, err := pool.modelx.GetBestNonceSubmissionOnBlock(currentBlock.Height)
+			if err != nil {
 				continue
 			}
-
-			Logger.Info("new best deadline", zap.Uint64("deadline", nonceSubmission.Deadline))
-			bestNonceSubmission = nonceSubmission
-			pool.modelx.UpdateBestSubmission(nonceSubmission.MinerID, nonceSubmission.Height)
-			Cache.StoreBestNonceSubmission(*bestNonceSubmission)
-
-			updateSubmitTimer(nonceSubmission.Deadline, nonceSubmission.RoundStart)
+			nonceSubmission.GenerationSignature = currentBlock.GenerationSignature
+			newBestNonceSubmission(nonceSubmission)
 		case <-after:
-			pool.submitNonce(bestNonceSubmission)
+			if !pool.modelx.IsLeader() {
+				// a standby submits if it takes over before the round is over
+				if bestNonceSubmission.Height == Cache.CurrentBlock().Height {
+					after = time.After(bestNonceSubmissionSyncInterval)
+				} else {
+					after = time.After(maxTime)
+				}
+				continue
+			}
+			submittedHeight = bestNonceSubmission.Height
+			after = time.After(maxTime)
+			submit(bestNonceSubmission)
 		}
 	}
 }
 
-func (pool *Pool) submitNonce(nonceSubmission *NonceSubmission) {
+// submitNonce submits the nonce to all wallets and keeps trying until one of them
+// accepted it, the round is over or ctx is cancelled by a better nonce
+func (pool *Pool) submitNonce(ctx context.Context, nonceSubmission *NonceSubmission) {
 	Logger.Info("submitting best nonce")
-	for try := 0; try < nonceSubmissionRetries; try++ {
-		err := pool.walletHandler.SubmitNonce(nonceSubmission.Nonce, nonceSubmission.MinerID,
-			nonceSubmission.Deadline)
+	for try := 1; ; try++ {
+		results, err := pool.walletHandler.SubmitNonce(nonceSubmission.Nonce, nonceSubmission.MinerID,
+			nonceSubmission.Deadline, nonceSubmission.Height)
+		if err == nil || try == nonceSubmissionRetries {
+			pool.modelx.StoreSubmitOutcome(nonceSubmission.Height, results)
+		}
 		if err == nil {
 			return
 		}
-		Logger.Error("Submitting nonce failed", zap.Int("try", try),
-			zap.Int("max-tries", nonceSubmissionRetries))
+		Logger.Error("Submitting nonce failed", zap.Int("try", try), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nonceSubmissionRetryDelay):
+		}
+		if Cache.CurrentBlock().Height != nonceSubmission.Height {
+			Logger.Error("Submitting nonce failed, round is over", zap.Int("tries", try),
+				zap.Uint64("height", nonceSubmission.Height))
+			pool.modelx.StoreSubmitOutcome(nonceSubmission.Height, results)
+			return
+		}
+	}
+}
+
+func (pool *Pool) forwardNonce(nonceSubmission *NonceSubmission) {
+	_, err := pool.walletHandler.SubmitNonce(nonceSubmission.Nonce, nonceSubmission.MinerID,
+		nonceSubmission.Deadline, nonceSubmission.Height)
+	if err != nil {
+		Logger.Error("forwarding nonce to upstream pool failed", zap.Uint64("accountID", nonceSubmission.MinerID),
+			zap.Error(err))
 	}
-	Logger.Error("Submitting nonce failed", zap.Int("max-tries", nonceSubmissionRetries))
 }
 
 func (pool *Pool) checkAndAddNewBlock() {
@@ -137,6 +236,24 @@ func (pool *Pool) checkAndAddNewBlock() {
 	pool.modelx.MaybeSwitchOrNewBlock(miningInfo.BaseTarget, miningInfo.GenerationSignature, miningInfo.Height)
 }
 
+func (pool *Pool) updateClockOffset() {
+	offset, err := pool.walletHandler.GetClockOffset()
+	if err != nil {
+		Logger.Error("measuring clock offset to wallet", zap.Error(err))
+		return
+	}
+	Logger.Info("measured clock offset to wallet", zap.Duration("offset", offset))
+	Cache.StoreClockOffset(offset)
+}
+
+func (pool *Pool) updateClockOffsetJob() {
+	ticker := time.NewTicker(clockOffsetInterval)
+
+	for range ticker.C {
+		pool.updateClockOffset()
+	}
+}
+
 func (pool *Pool) checkAndAddNewBlockJob() {
 	pool.checkAndAddNewBlock()
 	ticker := time.NewTicker(time.Second)
@@ -150,16 +267,36 @@ func (pool *Pool) jobs() {
 	payTicker := time.NewTicker(Cfg.PayoutIntervalDur)
 	rereadMinerNamesTicker := time.NewTicker(12 * time.Hour)
 	cleanDBTicker := time.NewTicker(24 * time.Hour)
+	reloadBansTicker := time.NewTicker(time.Minute)
+	forgetOffendersTicker := time.NewTicker(time.Hour)
 
 	for {
 		select {
 		case <-payTicker.C:
+			if !pool.modelx.IsLeader() {
+				continue
+			}
+			if Cfg.UpstreamPoolURL != "" {
+				// the upstream pool pays the miners, we only keep track of won blocks
+				pool.modelx.ReportBlocks()
+				continue
+			}
 			pool.modelx.RewardBlocks()
 			pool.modelx.Payout()
 		case <-rereadMinerNamesTicker.C:
-			pool.modelx.RereadMinerNames()
+			if pool.modelx.IsLeader() {
+				pool.modelx.RereadMinerNames()
+			}
 		case <-cleanDBTicker.C:
-			pool.modelx.CleanDB()
+			if pool.modelx.IsLeader() {
+				pool.modelx.CleanDB()
+			}
+		case <-forgetOffendersTicker.C:
+			Cache.ForgetOffenders(time.Now().Add(-24 * time.Hour))
+		case <-reloadBansTicker.C:
+			if err := pool.modelx.LoadBans(); err != nil {
+				Logger.Error("reloading bans failed", zap.Error(err))
+			}
 		}
 	}
 }
@@ -171,17 +308,34 @@ func formatJSONError(errorCode int64, errorMsg string) []uint8 {
 	return bytes
 }
 
-func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Request) {
+func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Request, l *MiningListener) {
 	ri := Cache.GetRoundInfo()
 	requestLogger := RequestLogger(req)
 
+	// rejections are counted for the ip and the account, repeated offenders get banned
+	ip := clientIP(req)
+	formAccountID, _ := strconv.ParseUint(req.Form.Get("accountId"), 10, 64)
+	reject := func(status int, errorCode int64, errorMsg, reason string) {
+		w.WriteHeader(status)
+		w.Write(formatJSONError(errorCode, errorMsg))
+		pool.modelx.RecordReject(formAccountID, ip, reason)
+	}
+
+	// late submissions of the previous round still count for the historical share,
+	// but don't take part in forging
+	var late bool
 	if minerHeight, err := strconv.ParseUint(req.Form.Get("blockheight"), 10, 64); err == nil {
 		if minerHeight != ri.Height {
-			requestLogger.Warn("Miner submitted on invalid height",
-				zap.Uint64("got", minerHeight), zap.Uint64("expected", ri.Height))
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write(formatJSONError(1005, "Submitted on wrong height"))
-			return
+			prevRi, ok := Cache.GetPrevRoundInfo(Cfg.LateSubmissionGraceDur)
+			if !ok || minerHeight != prevRi.Height {
+				requestLogger.Warn("Miner submitted on invalid height",
+					zap.Uint64("got", minerHeight), zap.Uint64("expected", ri.Height))
+				reject(http.StatusBadRequest, 1005, "Submitted on wrong height", "wrong height")
+				return
+			}
+			requestLogger.Info("late submission on previous height", zap.Uint64("height", minerHeight))
+			ri = prevRi
+			late = true
 		}
 	}
 
@@ -190,8 +344,8 @@ func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Req
 	nonce, err := strconv.ParseUint(nonceStr, 10, 64)
 	if err != nil {
 		requestLogger.Warn("malformed nonce", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(formatJSONError(1012, "submitNonce request has bad 'nonce' parameter - should be uint64"))
+		reject(http.StatusBadRequest, 1012, "submitNonce request has bad 'nonce' parameter - should be uint64",
+			"malformed nonce")
 		return
 	}
 
@@ -199,22 +353,43 @@ func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Req
 	accountID, err := strconv.ParseUint(accountIDStr, 10, 64)
 	if err != nil || accountID == 0 {
 		requestLogger.Warn("malformed accountId", zap.Error(err))
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(formatJSONError(1013, "submitNonce request has bad 'accountId' parameter - should be uint64"))
+		reject(http.StatusBadRequest, 1013,
+			"submitNonce request has bad 'accountId' parameter - should be uint64", "malformed account id")
 		return
 	}
-	if _, blacklisted := Cfg.AccountIDBlacklist[accountID]; blacklisted {
+	if ban := Cache.BannedAccount(accountID); ban != nil {
+		writeBanned(w, req, ban)
 		return
 	}
 
 	requestLogger.Info("processing formal valid request", zap.Uint64("accountID", accountID),
-		zap.Uint64("nonce", nonce))
+		zap.Uint64("nonce", nonce), zap.String("ip", ip))
+
+	// Calculate deadline and check against limit
+	deadlineReq := burstmath.NewCalcDeadlineRequest(accountID, nonce, ri.BaseTarget, ri.Scoop, ri.GenSig)
+	deadline := pool.deadlineRequestHandler.CalcDeadline(deadlineReq)
+
+	deadlineLimit := listenerDeadlineLimit(l, Cache.SubmitDeadlineLimit(accountID, ri.Height))
+	if deadlineLimit != 0 && deadline > deadlineLimit {
+		requestLogger.Warn("calculated deadline exceeds pool limit", zap.Uint64("got", deadline),
+			zap.Uint64("expected-max", deadlineLimit))
+		reject(http.StatusBadRequest, 1008, "deadline exceeds deadline limit of the pool", "deadline over limit")
+		return
+	}
 
-	// Check if the reward recepient is correct and cache it for this round
-	correctRewardRecepient, _ := Cache.IsRewardRecipient(accountID)
-	if !correctRewardRecepient {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write(formatJSONError(1004, "Account's reward recipient doesn't match the pool's"))
+	// Check if the reward recepient is effective at the height of the submission, this
+	// might need a lookup in the wallet and is done after the cheap checks
+	isPool, err := pool.modelx.IsRewardRecipient(accountID, ri.Height, ip)
+	if err == ErrLookupThrottled {
+		// not an offence, the global budget might be used up by others
+		requestLogger.Warn("reward recipient lookup throttled", zap.Uint64("accountID", accountID))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(formatJSONError(1018, "reward recipient can't be checked right now, try again later"))
+		return
+	}
+	if !isPool {
+		reject(http.StatusForbidden, 1004, "Account's reward recipient doesn't match the pool's",
+			"wrong reward recipient")
 		requestLogger.Warn("reward recipient doesn't match pools", zap.Uint64("accountID", accountID))
 		return
 	}
@@ -225,36 +400,38 @@ func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Req
 	if miner == nil {
 		// most likely wrong reward recipient, can also be error in db
 		requestLogger.Warn("invalid reward recipient", zap.Error(err))
-		w.WriteHeader(http.StatusForbidden)
-		w.Write(formatJSONError(1004, "Account's reward recipient doesn't match the pool's"))
+		reject(http.StatusForbidden, 1004, "Account's reward recipient doesn't match the pool's",
+			"wrong reward recipient")
 		return
 	}
 
-	if ua := req.Header.Get("User-Agent"); ua == "" {
-		miner.UserAgent = req.Header.Get("X-Miner")
-	} else {
-		miner.UserAgent = ua
-	}
-
-	// Calculate deadline and check against limit
-	deadlineReq := burstmath.NewCalcDeadlineRequest(accountID, nonce, ri.BaseTarget, ri.Scoop, ri.GenSig)
-	deadline := pool.deadlineRequestHandler.CalcDeadline(deadlineReq)
+	miner.Lock()
+	miner.UserAgent = minerUserAgent(req)
+	miner.Listener = l
+	miner.Unlock()
 
-	if Cfg.DeadlineLimit != 0 && deadline > Cfg.DeadlineLimit {
-		requestLogger.Warn("calculated deadline exceeds pool limit", zap.Uint64("got", deadline),
-			zap.Uint64("expected-max", Cfg.DeadlineLimit))
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(formatJSONError(1008, "deadline exceeds deadline limit of the pool"))
+	if !late && !miner.AddRoundSubmit(ri.Height, l.MaxSubmitsPerRound) {
+		requestLogger.Warn("submission quota of round exceeded", zap.Uint64("accountID", accountID),
+			zap.Int("max", l.MaxSubmitsPerRound))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(formatJSONError(1015, "too many submissions in this round"))
 		return
 	}
 
 	requestLogger.Info("valid deadline", zap.Uint64("deadline", deadline))
 
-	w.Write([]byte(fmt.Sprintf("{\"deadline\":%d,\"result\":\"success\"}", deadline)))
-
+	// the deadline is only confirmed once it is logged
 	err = pool.modelx.UpdateOrCreateNonceSubmission(miner, ri.Height, deadline, nonce, ri.BaseTarget, "")
 	if err != nil {
 		requestLogger.Error("updating deadline failed", zap.Error(err))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(formatJSONError(1017, "deadline could not be stored"))
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf("{\"deadline\":%d,\"result\":\"success\"}", deadline)))
+
+	if late {
 		return
 	}
 
@@ -271,34 +448,90 @@ func (pool *Pool) processSubmitNonceRequest(w http.ResponseWriter, req *http.Req
 	pool.nonceSubmissions <- &nonceSubmission
 }
 
+// clientIP yields the miner's ip, also if the pool runs behind a proxy
+func clientIP(req *http.Request) string {
+	return proxyproto.ClientIP(req, Cfg.TrustedProxyNets)
+}
+
 func rateLimitDeniedHandler(w http.ResponseWriter, req *http.Request) {
 	logIncomingRequest(req)
-	ip, _, _ := net.SplitHostPort(req.RemoteAddr)
+	ip := clientIP(req)
 	RequestLogger(req).Info("rate limit exceeded", zap.String("ip", ip), zap.String("uri", req.RequestURI),
 		zap.String("user-agent", req.UserAgent()))
 	http.Error(w, "limit exceeded", 429)
 }
 
+// requestAccountID yields the account a miner identified itself with when asking for
+// mining info, either by the accountId parameter or the X-Account-Id header
+func requestAccountID(req *http.Request) (uint64, bool) {
+	accountIDStr := req.Form.Get("accountId")
+	if accountIDStr == "" {
+		accountIDStr = req.Header.Get("X-Account-Id")
+	}
+	accountID, err := strconv.ParseUint(accountIDStr, 10, 64)
+	return accountID, err == nil && accountID != 0
+}
+
 func generateLimiterKey(req *http.Request) string {
 	req.ParseForm()
-	ip, _, _ := net.SplitHostPort(req.RemoteAddr)
-	return ip + req.Form.Get("requestType")
+	return clientIP(req) + req.Form.Get("requestType")
+}
+
+// generateAccountLimiterKey keys submissions by account, so that submitting from several
+// ips doesn't multiply the limit, submissions without an account are keyed by ip
+func generateAccountLimiterKey(req *http.Request) string {
+	req.ParseForm()
+	if accountID := req.Form.Get("accountId"); accountID != "" {
+		return "account" + accountID
+	}
+	return clientIP(req) + req.Form.Get("requestType")
 }
 
 func logIncomingRequest(req *http.Request) {
-	// ip, _, _ := net.SplitHostPort(req.RemoteAddr)
+	// ip := clientIP(req)
 	// RequestLogger(req).Info("incoming request", zap.String("ip", ip), zap.String("uri", req.RequestURI),
 	// 	zap.String("user-agent", req.UserAgent()))
 }
 
-func (pool *Pool) serve() {
-	store, err := memstore.New(65536)
-	if err != nil {
-		Logger.Fatal("", zap.Error(err))
+// listenerDeadlineLimit lowers the deadline limit of an account to the one of a listener
+func listenerDeadlineLimit(l *MiningListener, deadlineLimit uint64) uint64 {
+	if l.DeadlineLimit != 0 && (deadlineLimit == 0 || l.DeadlineLimit < deadlineLimit) {
+		return l.DeadlineLimit
+	}
+	return deadlineLimit
+}
+
+func minerUserAgent(req *http.Request) string {
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		return ua
+	}
+	return req.Header.Get("X-Miner")
+}
+
+func writeBanned(w http.ResponseWriter, req *http.Request, ban *Ban) {
+	RequestLogger(req).Info("rejected banned request", zap.String("ip", clientIP(req)),
+		zap.String("banType", ban.Type), zap.String("banValue", ban.Value))
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(formatJSONError(1016, ban.Error()))
+}
+
+func userAgentAllowed(l *MiningListener, req *http.Request) bool {
+	if len(l.AllowedUserAgents) == 0 {
+		return true
+	}
+	ua := minerUserAgent(req)
+	for _, allowed := range l.AllowedUserAgents {
+		if strings.HasPrefix(ua, allowed) {
+			return true
+		}
 	}
+	return false
+}
 
+func newHTTPRateLimiter(store throttled.GCRAStore, perSec int,
+	limiterKey func(*http.Request) string) *throttled.HTTPRateLimiter {
 	quota := throttled.RateQuota{
-		MaxRate:  throttled.PerSec(Cfg.AllowRequestsPerSecond),
+		MaxRate:  throttled.PerSec(perSec),
 		MaxBurst: 2}
 
 	rateLimiter, err := throttled.NewGCRARateLimiter(store, quota)
@@ -306,27 +539,101 @@ func (pool *Pool) serve() {
 		Logger.Fatal("", zap.Error(err))
 	}
 
-	httpRateLimiter := throttled.HTTPRateLimiter{
+	return &throttled.HTTPRateLimiter{
 		RateLimiter:   rateLimiter,
-		VaryBy:        &throttled.VaryBy{Custom: generateLimiterKey},
+		VaryBy:        &throttled.VaryBy{Custom: limiterKey},
 		DeniedHandler: http.Handler(http.HandlerFunc(rateLimitDeniedHandler))}
+}
 
-	http.Handle("/burst", httpRateLimiter.RateLimit(http.HandlerFunc(
+func (pool *Pool) serveListener(l *MiningListener) {
+	store, err := memstore.New(65536)
+	if err != nil {
+		Logger.Fatal("", zap.Error(err))
+	}
+
+	// submissions are limited by account, so that farms behind one ip aren't
+	// throttled, everything else by ip
+	ipRateLimiter := newHTTPRateLimiter(store, l.AllowRequestsPerSecond, generateLimiterKey)
+	accountRateLimiter := newHTTPRateLimiter(store, l.AllowSubmitsPerSecond, generateAccountLimiterKey)
+
+	handler := http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
 			logIncomingRequest(req)
 
+			if !userAgentAllowed(l, req) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write(formatJSONError(1014, "miner software not allowed on this port"))
+				return
+			}
+
+			if ban := Cache.BannedClient(clientIP(req), minerUserAgent(req)); ban != nil {
+				writeBanned(w, req, ban)
+				return
+			}
+
 			switch req.Form.Get("requestType") {
 			case "getMiningInfo":
-				w.Write(Cache.GetMiningInfoJSON())
+				accountID, _ := requestAccountID(req)
+				if ban := Cache.BannedAccount(accountID); ban != nil {
+					writeBanned(w, req, ban)
+					return
+				}
+				ri := Cache.GetRoundInfo()
+				w.Write(Cache.GetMiningInfoJSONWith(listenerDeadlineLimit(l,
+					Cache.TargetDeadline(accountID, ri.BaseTarget, ri.Height))))
 			case "submitNonce":
-				pool.processSubmitNonceRequest(w, req)
+				pool.processSubmitNonceRequest(w, req, l)
 			}
-		})))
-	http.ListenAndServe(fmt.Sprintf("%s:%d", Cfg.PoolListenAddress, Cfg.PoolPort), nil)
+		})
+	ipLimited := ipRateLimiter.RateLimit(handler)
+	accountLimited := accountRateLimiter.RateLimit(handler)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/burst", func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		if req.Form.Get("requestType") == "submitNonce" {
+			accountLimited.ServeHTTP(w, req)
+		} else {
+			ipLimited.ServeHTTP(w, req)
+		}
+	})
+
+	addr := fmt.Sprintf("%s:%d", l.ListenAddress, l.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		Logger.Fatal("failed to listen", zap.String("address", addr), zap.Error(err))
+	}
+	if l.ProxyProtocol {
+		lis = proxyproto.NewListener(lis, Cfg.TrustedProxyNets)
+	}
+
+	server := &http.Server{Handler: mux}
+	if l.TLSCert != "" {
+		err = server.ServeTLS(lis, l.TLSCert, l.TLSKey)
+	} else {
+		err = server.Serve(lis)
+	}
+	Logger.Error("mining listener stopped", zap.String("address", addr), zap.Error(err))
+}
+
+func (pool *Pool) serve() {
+	for i := range Cfg.MiningListeners {
+		go pool.serveListener(&Cfg.MiningListeners[i])
+	}
 }
 
 func (s *nodeServer) SubmitNonce(ctx context.Context, msg *nodecom.SubmitNonceRequest) (*nodecom.SubmitNonceReply,
 	error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		ip, _, _ := net.SplitHostPort(p.Addr.String())
+		if ban := Cache.BannedClient(ip, ""); ban != nil {
+			return nil, grpc.Errorf(codes.PermissionDenied, "%s", ban.Error())
+		}
+	}
+	if ban := Cache.BannedAccount(msg.AccountID); ban != nil {
+		return nil, grpc.Errorf(codes.PermissionDenied, "%s", ban.Error())
+	}
+
 	ri := Cache.GetRoundInfo()
 	m := s.modelx.FirstOrCreateMiner(msg.AccountID)
 	err := s.modelx.UpdateOrCreateNonceSubmission(m, msg.BlockHeight, msg.Deadline, msg.Nonce, msg.BaseTarget,
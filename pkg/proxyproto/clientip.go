@@ -0,0 +1,84 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package proxyproto
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseNets parses a list of CIDRs, single IPs are treated as /32 or /128 networks
+func ParseNets(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Contains checks if the ip of addr lies in one of nets
+func Contains(nets []*net.IPNet, addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	return ContainsIP(nets, net.ParseIP(host))
+}
+
+// ContainsIP checks if ip lies in one of nets
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP yields the ip of the client that sent req. X-Forwarded-For and X-Real-IP
+// are only honoured if the request came from a trusted proxy.
+func ClientIP(req *http.Request, trusted []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	if !ContainsIP(trusted, net.ParseIP(ip)) {
+		return ip
+	}
+
+	// the right most address that isn't one of our proxies is the client
+	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			ip = hop.String()
+			if !ContainsIP(trusted, hop) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+	return ip
+}
@@ -0,0 +1,191 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+// Package proxyproto accepts connections relayed by a load balancer using the HAProxy
+// PROXY protocol (v1 and v2) and extracts the client address of http requests passed
+// through trusted proxies.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerTimeout = 5 * time.Second
+	v1MaxLen      = 107
+)
+
+var v2Signature = []byte("\x0D\x0A\x0D\x0A\x00\x0D\x0A\x51\x55\x49\x54\x0A")
+
+var errInvalidHeader = errors.New("invalid proxy protocol header")
+
+// Listener reads a PROXY protocol header from every connection it accepts from a trusted
+// peer and reports the client address found in it as RemoteAddr. Connections without
+// header are passed through unchanged.
+type Listener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+// NewListener wraps l, headers are only honoured from peers within trusted, so none
+// are if trusted is empty
+func NewListener(l net.Listener, trusted []*net.IPNet) *Listener {
+	return &Listener{Listener: l, trusted: trusted}
+}
+
+// Accept waits for the next connection, the header is read lazily on first use
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &conn{
+		Conn:    c,
+		reader:  bufio.NewReader(c),
+		remote:  c.RemoteAddr(),
+		trusted: Contains(l.trusted, c.RemoteAddr())}, nil
+}
+
+type conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	remote  net.Addr
+	trusted bool
+
+	once sync.Once
+	err  error
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remote
+}
+
+func (c *conn) readHeader() {
+	if !c.trusted {
+		return
+	}
+
+	c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	remote, err := parseHeader(c.reader)
+	if err != nil {
+		c.err = err
+		c.Conn.Close()
+		return
+	}
+	if remote != nil {
+		c.remote = remote
+	}
+}
+
+// parseHeader consumes a v1 or v2 header from r and yields the source address in it.
+// nil is returned if there is no header or it doesn't carry an address (LOCAL/UNKNOWN).
+func parseHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(1)
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	switch start[0] {
+	case 'P':
+		prefix, err := r.Peek(6)
+		if err != nil || string(prefix) != "PROXY " {
+			return nil, nil
+		}
+		return parseV1(r)
+	case v2Signature[0]:
+		prefix, err := r.Peek(len(v2Signature))
+		if err != nil || !bytes.Equal(prefix, v2Signature) {
+			return nil, nil
+		}
+		return parseV2(r)
+	}
+	return nil, nil
+}
+
+func parseV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errInvalidHeader
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errInvalidHeader
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, errInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func parseV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	verCmd, family := header[12], header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version %d", verCmd>>4)
+	}
+	// LOCAL connections are health checks of the proxy itself
+	if verCmd&0x0F == 0 {
+		return nil, nil
+	}
+
+	switch family >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, errInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, errInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHeader(t *testing.T) {
+	parse := func(data []byte) (net.Addr, string, error) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		addr, err := parseHeader(r)
+		rest, _ := ioutil.ReadAll(r)
+		return addr, string(rest), err
+	}
+
+	addr, rest, err := parse([]byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 8124\r\nGET /burst"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "192.168.0.1:56324", addr.String())
+		assert.Equal(t, "GET /burst", rest)
+	}
+
+	addr, rest, err = parse([]byte("PROXY UNKNOWN\r\nGET"))
+	assert.Nil(t, err)
+	assert.Nil(t, addr)
+	assert.Equal(t, "GET", rest)
+
+	_, _, err = parse([]byte("PROXY TCP4 nonsense\r\n"))
+	assert.NotNil(t, err, "invalid v1 header accepted")
+
+	addr, rest, err = parse([]byte("POST /burst"))
+	assert.Nil(t, err)
+	assert.Nil(t, addr, "plain request must be passed through")
+	assert.Equal(t, "POST /burst", rest)
+
+	v2 := append([]byte{}, v2Signature...)
+	v2 = append(v2, 0x21, 0x11, 0, 12, 1, 2, 3, 4, 10, 0, 0, 1)
+	v2 = binary.BigEndian.AppendUint16(v2, 4242)
+	v2 = binary.BigEndian.AppendUint16(v2, 8124)
+	addr, rest, err = parse(append(v2, []byte("GET")...))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "1.2.3.4:4242", addr.String())
+		assert.Equal(t, "GET", rest)
+	}
+
+	local := append([]byte{}, v2Signature...)
+	local = append(local, 0x20, 0x00, 0, 0)
+	addr, _, err = parse(local)
+	assert.Nil(t, err)
+	assert.Nil(t, addr, "LOCAL command carries no address")
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNets([]string{"10.0.0.0/8", "192.168.1.1"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	req := &http.Request{RemoteAddr: "10.1.2.3:5555", Header: http.Header{}}
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4, 192.168.1.1")
+	assert.Equal(t, "1.2.3.4", ClientIP(req, trusted))
+
+	req.Header.Del("X-Forwarded-For")
+	req.Header.Set("X-Real-IP", "5.6.7.8")
+	assert.Equal(t, "5.6.7.8", ClientIP(req, trusted))
+
+	req.Header.Del("X-Real-IP")
+	assert.Equal(t, "10.1.2.3", ClientIP(req, trusted))
+
+	untrusted := &http.Request{RemoteAddr: "8.8.8.8:5555", Header: http.Header{}}
+	untrusted.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.Equal(t, "8.8.8.8", ClientIP(untrusted, trusted), "header of untrusted peer honoured")
+}
+
+func TestListenerTrust(t *testing.T) {
+	remoteAddr := func(trusted []*net.IPNet) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		defer lis.Close()
+
+		go func() {
+			c, err := net.Dial("tcp", lis.Addr().String())
+			if err == nil {
+				c.Write([]byte("PROXY TCP4 1.2.3.4 10.0.0.1 4242 8124\r\n"))
+				c.Close()
+			}
+		}()
+
+		c, err := NewListener(lis, trusted).Accept()
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		defer c.Close()
+		host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+		return host
+	}
+
+	local, _ := ParseNets([]string{"127.0.0.1"})
+	assert.Equal(t, "1.2.3.4", remoteAddr(local))
+	assert.Equal(t, "127.0.0.1", remoteAddr(nil), "header honoured without trusted proxies")
+}
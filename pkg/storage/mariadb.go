@@ -0,0 +1,95 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/database"
+	"github.com/golang-migrate/migrate/database/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// mariaDB is the dialect the queries are written in
+type mariaDB struct{}
+
+func openMariaDB(config DBConfig, create bool) (backend, *sqlx.DB, error) {
+	if create {
+		tmpdb, err := sqlx.Connect("mysql", config.DataSourceName(false))
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = tmpdb.Exec("CREATE SCHEMA IF NOT EXISTS `" + config.Name + "` DEFAULT CHARACTER SET utf8;")
+		tmpdb.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	db, err := sqlx.Connect("mysql", config.DataSourceName(true))
+	if err != nil {
+		return nil, nil, err
+	}
+	SetPoolLimits(db, config)
+	return mariaDB{}, db, nil
+}
+
+func (mariaDB) translate(query string) string {
+	return query
+}
+
+func (mariaDB) convert(arg interface{}) interface{} {
+	return arg
+}
+
+func (mariaDB) insertIgnore(table, rest string) string {
+	return "INSERT IGNORE INTO " + table + " " + rest
+}
+
+// onConflictUpdate assigns lower last, the other columns are assigned while it still
+// has its old value
+func (mariaDB) onConflictUpdate(table string, keys, updates []string, lower string) string {
+	var assignments []string
+	for _, column := range updates {
+		switch {
+		case lower == "":
+			assignments = append(assignments, column+" = VALUES("+column+")")
+		case column != lower:
+			assignments = append(assignments,
+				column+" = IF(VALUES("+lower+") < "+lower+", VALUES("+column+"), "+column+")")
+		}
+	}
+	if lower != "" {
+		assignments = append(assignments, lower+" = LEAST("+lower+", VALUES("+lower+"))")
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+func (mariaDB) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (mariaDB) float(expr string) string {
+	return "CAST(" + expr + " AS DOUBLE)"
+}
+
+func (mariaDB) unixMs() string {
+	return "CAST(UNIX_TIMESTAMP(NOW(3)) * 1000 AS SIGNED)"
+}
+
+func (mariaDB) insertID(ext sqlx.Ext, query string, args []interface{}) (int64, error) {
+	res, err := ext.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (mariaDB) migrations(db *sql.DB) (string, database.Driver, error) {
+	driver, err := mysql.WithInstance(db, &mysql.Config{})
+	return DriverMariaDB, driver, err
+}
@@ -0,0 +1,118 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/golang-migrate/migrate/database"
+	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// postgreSQL keeps account ids, nonces and deadlines in NUMERIC(20) columns because it
+// has no unsigned integers, flags are SMALLINTs like the TINYINTs of MariaDB
+type postgreSQL struct{}
+
+func openPostgres(config DBConfig) (backend, *sqlx.DB, error) {
+	sslMode := config.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Path:     config.Name,
+		RawQuery: url.Values{"sslmode": {sslMode}}.Encode()}
+
+	db, err := sqlx.Connect("postgres", dsn.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	SetPoolLimits(db, config)
+	return postgreSQL{}, db, nil
+}
+
+func (postgreSQL) translate(query string) string {
+	return sqlx.Rebind(sqlx.DOLLAR, strings.Replace(query, "`", `"`, -1))
+}
+
+func (postgreSQL) convert(arg interface{}) interface{} {
+	if b, ok := arg.(bool); ok {
+		if b {
+			return int64(1)
+		}
+		return int64(0)
+	}
+	return convertUnsigned(arg)
+}
+
+func (postgreSQL) insertIgnore(table, rest string) string {
+	return "INSERT INTO " + table + " " + rest + " ON CONFLICT DO NOTHING"
+}
+
+func (postgreSQL) onConflictUpdate(table string, keys, updates []string, lower string) string {
+	return onConflictUpdate(table, keys, updates, lower)
+}
+
+func (postgreSQL) forUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (postgreSQL) float(expr string) string {
+	return "CAST(" + expr + " AS DOUBLE PRECISION)"
+}
+
+func (postgreSQL) unixMs() string {
+	return "CAST(EXTRACT(EPOCH FROM clock_timestamp()) * 1000 AS BIGINT)"
+}
+
+func (postgreSQL) insertID(ext sqlx.Ext, query string, args []interface{}) (int64, error) {
+	var id int64
+	err := ext.QueryRowx(query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+func (postgreSQL) migrations(db *sql.DB) (string, database.Driver, error) {
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	return DriverPostgres, driver, err
+}
+
+// onConflictUpdate yields the upsert clause PostgreSQL and SQLite share
+func onConflictUpdate(table string, keys, updates []string, lower string) string {
+	assignments := make([]string, len(updates))
+	for i, column := range updates {
+		assignments[i] = column + " = excluded." + column
+	}
+	clause := "ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(assignments, ", ")
+	if lower != "" {
+		clause += " WHERE excluded." + lower + " < " + table + "." + lower
+	}
+	return clause
+}
+
+// convertUnsigned passes unsigned integers as int64 or, if they don't fit, as decimal
+// string since database/sql only supports the first
+func convertUnsigned(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return int64(v)
+	case uint:
+		return convertUnsigned(uint64(v))
+	case uint32:
+		return int64(v)
+	}
+	return arg
+}
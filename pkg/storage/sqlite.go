@@ -0,0 +1,92 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/golang-migrate/migrate/database"
+	"github.com/golang-migrate/migrate/database/sqlite3"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// memory is the name of an SQLite db that only lives as long as the pool runs
+const memory = ":memory:"
+
+// sqLite keeps account ids and nonces in TEXT columns because its integers are signed.
+// Times are stored as text, they are passed in UTC so that they compare correctly.
+type sqLite struct{}
+
+func openSQLite(config DBConfig) (backend, *sqlx.DB, error) {
+	// writers wait for each other and take their lock at the start of a transaction,
+	// upgrading a read lock later on might fail right away
+	params := "?_foreign_keys=1&_busy_timeout=5000&_txlock=immediate"
+	if config.Name != memory {
+		params += "&_journal_mode=WAL"
+	}
+
+	db, err := sqlx.Connect("sqlite3", "file:"+config.Name+params)
+	if err != nil {
+		return nil, nil, err
+	}
+	SetPoolLimits(db, config)
+	if config.Name == memory {
+		// every connection would get a db of its own
+		db.SetMaxOpenConns(1)
+	}
+	return sqLite{}, db, nil
+}
+
+func (sqLite) translate(query string) string {
+	return query
+}
+
+func (sqLite) convert(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case time.Time:
+		return v.UTC()
+	case *time.Time:
+		if v != nil {
+			return v.UTC()
+		}
+	}
+	return convertUnsigned(arg)
+}
+
+func (sqLite) insertIgnore(table, rest string) string {
+	return "INSERT OR IGNORE INTO " + table + " " + rest
+}
+
+func (sqLite) onConflictUpdate(table string, keys, updates []string, lower string) string {
+	return onConflictUpdate(table, keys, updates, lower)
+}
+
+// forUpdate yields nothing, the transaction already holds the write lock on the db
+func (sqLite) forUpdate() string {
+	return ""
+}
+
+func (sqLite) float(expr string) string {
+	return "CAST(" + expr + " AS REAL)"
+}
+
+func (sqLite) unixMs() string {
+	return "CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)"
+}
+
+func (sqLite) insertID(ext sqlx.Ext, query string, args []interface{}) (int64, error) {
+	res, err := ext.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (sqLite) migrations(db *sql.DB) (string, database.Driver, error) {
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	return DriverSQLite, driver, err
+}
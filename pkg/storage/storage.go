@@ -0,0 +1,285 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+// Package storage is the layer between the pool and its SQL database. Queries are
+// written with ? placeholders and backtick quoted identifiers, the backends for
+// MariaDB, PostgreSQL and SQLite translate them into their dialect.
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database"
+	_ "github.com/golang-migrate/migrate/source/file"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Querier runs queries on the database or inside of a transaction
+type Querier interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	MustExec(query string, args ...interface{}) sql.Result
+	QueryRow(query string, args ...interface{}) *sqlx.Row
+	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
+
+	// InsertID runs an INSERT and yields the id of the new row
+	InsertID(query string, args ...interface{}) (int64, error)
+}
+
+// Tx is a transaction
+type Tx interface {
+	Querier
+	Commit() error
+	Rollback() error
+}
+
+// Storage is the database of the pool
+type Storage interface {
+	Querier
+	Begin() (Tx, error)
+
+	// InsertIgnore yields an INSERT into table that skips rows which violate a unique
+	// key, rest are the columns with their values or a SELECT
+	InsertIgnore(table, rest string) string
+	// Upsert yields a multi-row INSERT into table of rows rows, rows which violate the
+	// unique key of the keys columns update the other columns instead
+	Upsert(table string, columns, keys []string, rows int) string
+	// UpsertLower yields an Upsert that only updates rows whose lower column decreases
+	UpsertLower(table string, columns, keys []string, lower string, rows int) string
+	// ForUpdate yields the clause that locks selected rows until the transaction ends
+	ForUpdate() string
+	// Float yields expr cast to a double precision float
+	Float(expr string) string
+	// UnixMs yields the db's current time in ms since the epoch, so that instances with
+	// skewed clocks agree on it
+	UnixMs() string
+
+	// Migrate brings the schema to the latest version of the backend's migrations
+	Migrate() error
+	// Ping checks that the database is reachable
+	Ping() error
+	Close() error
+}
+
+// backend is what differs between the databases
+type backend interface {
+	translate(query string) string
+	convert(arg interface{}) interface{}
+	insertIgnore(table, rest string) string
+	// onConflictUpdate yields the clause that updates the updates columns of rows which
+	// violate the keys, only if lower decreases unless it's empty
+	onConflictUpdate(table string, keys, updates []string, lower string) string
+	forUpdate() string
+	float(expr string) string
+	unixMs() string
+	insertID(ext sqlx.Ext, query string, args []interface{}) (int64, error)
+
+	// migrations yields the directory below migrations/ and the driver to apply them
+	migrations(db *sql.DB) (string, database.Driver, error)
+}
+
+// Open connects to the database configured in config and creates it if needed
+func Open(config DBConfig) (Storage, error) {
+	return open(config, true)
+}
+
+// Connect connects to the existing database configured in config without changing
+// anything, e.g. to a read replica
+func Connect(config DBConfig) (Storage, error) {
+	return open(config, false)
+}
+
+func open(config DBConfig, create bool) (Storage, error) {
+	var b backend
+	var db *sqlx.DB
+	var err error
+	switch config.Driver {
+	case DriverMariaDB, "":
+		b, db, err = openMariaDB(config, create)
+	case DriverPostgres:
+		b, db, err = openPostgres(config)
+	case DriverSQLite:
+		b, db, err = openSQLite(config)
+	default:
+		return nil, fmt.Errorf("unknown db driver %s", config.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &storage{querier: querier{ext: db, backend: b}, db: db}, nil
+}
+
+// SetPoolLimits applies the connection pool limits of config to db
+func SetPoolLimits(db *sqlx.DB, config DBConfig) {
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetimeDur > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetimeDur)
+	}
+}
+
+type ext interface {
+	sqlx.Ext
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+}
+
+type querier struct {
+	ext     ext
+	backend backend
+}
+
+func (q querier) args(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		converted[i] = q.backend.convert(arg)
+	}
+	return converted
+}
+
+func (q querier) Get(dest interface{}, query string, args ...interface{}) error {
+	return q.ext.Get(dest, q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) Select(dest interface{}, query string, args ...interface{}) error {
+	return q.ext.Select(dest, q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return q.ext.Exec(q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) MustExec(query string, args ...interface{}) sql.Result {
+	return sqlx.MustExec(q.ext, q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) QueryRow(query string, args ...interface{}) *sqlx.Row {
+	return q.ext.QueryRowx(q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return q.ext.Queryx(q.backend.translate(query), q.args(args)...)
+}
+
+func (q querier) InsertID(query string, args ...interface{}) (int64, error) {
+	return q.backend.insertID(q.ext, q.backend.translate(query), q.args(args))
+}
+
+type tx struct {
+	querier
+	tx *sqlx.Tx
+}
+
+func (tx *tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+type storage struct {
+	querier
+	db *sqlx.DB
+}
+
+func (s *storage) Begin() (Tx, error) {
+	sqlxTx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{querier: querier{ext: sqlxTx, backend: s.backend}, tx: sqlxTx}, nil
+}
+
+func (s *storage) InsertIgnore(table, rest string) string {
+	return s.backend.insertIgnore(table, rest)
+}
+
+func (s *storage) Upsert(table string, columns, keys []string, rows int) string {
+	return s.upsert(table, columns, keys, "", rows)
+}
+
+func (s *storage) UpsertLower(table string, columns, keys []string, lower string, rows int) string {
+	return s.upsert(table, columns, keys, lower, rows)
+}
+
+func (s *storage) upsert(table string, columns, keys []string, lower string, rows int) string {
+	isKey := make(map[string]bool)
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	var updates []string
+	for _, column := range columns {
+		if !isKey[column] {
+			updates = append(updates, column)
+		}
+	}
+
+	row := "(?" + strings.Repeat(", ?", len(columns)-1) + ")"
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = row
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES " +
+		strings.Join(values, ", ") + " " + s.backend.onConflictUpdate(table, keys, updates, lower)
+}
+
+func (s *storage) ForUpdate() string {
+	return s.backend.forUpdate()
+}
+
+func (s *storage) Float(expr string) string {
+	return s.backend.float(expr)
+}
+
+func (s *storage) UnixMs() string {
+	return s.backend.unixMs()
+}
+
+func (s *storage) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *storage) Migrate() error {
+	dir, driver, err := s.backend.migrations(s.db.DB)
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://migrations/"+dir, dir, driver)
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return err
+	}
+
+	if dirty {
+		Logger.Warn("Forcing migration", zap.Uint("version", version))
+		if err := m.Force(int(version)); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package storage
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresTranslate(t *testing.T) {
+	assert.Equal(t, `SELECT id FROM "transaction" WHERE block_height = $1 AND id > $2`,
+		postgreSQL{}.translate("SELECT id FROM `transaction` WHERE block_height = ? AND id > ?"))
+}
+
+func TestSetPoolLimits(t *testing.T) {
+	s, err := Open(DBConfig{Driver: DriverSQLite, Name: ":memory:", MaxOpenConns: 3})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer s.Close()
+	// an in-memory db must not be spread over several connections
+	assert.Equal(t, 1, s.(*storage).db.Stats().MaxOpenConnections)
+
+	path := os.TempDir() + "/nogrod-pool-limits.db"
+	defer os.Remove(path)
+	s, err = Open(DBConfig{Driver: DriverSQLite, Name: path, MaxOpenConns: 3})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer s.Close()
+	assert.Equal(t, 3, s.(*storage).db.Stats().MaxOpenConnections)
+}
+
+func TestUpsert(t *testing.T) {
+	s := &storage{querier: querier{backend: mariaDB{}}}
+	assert.Equal(t, "INSERT INTO nonce_submission (miner_id, block_height, deadline) VALUES (?, ?, ?), (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE deadline = VALUES(deadline)",
+		s.Upsert("nonce_submission", []string{"miner_id", "block_height", "deadline"},
+			[]string{"miner_id", "block_height"}, 2))
+
+	s.backend = postgreSQL{}
+	assert.Equal(t, "INSERT INTO nonce_submission (miner_id, block_height, deadline) VALUES (?, ?, ?) "+
+		"ON CONFLICT (miner_id, block_height) DO UPDATE SET deadline = excluded.deadline",
+		s.Upsert("nonce_submission", []string{"miner_id", "block_height", "deadline"},
+			[]string{"miner_id", "block_height"}, 1))
+}
+
+func TestUpsertLower(t *testing.T) {
+	columns := []string{"miner_id", "block_height", "deadline", "nonce"}
+	keys := []string{"miner_id", "block_height"}
+	s := &storage{querier: querier{backend: mariaDB{}}}
+	assert.Equal(t, "INSERT INTO nonce_submission (miner_id, block_height, deadline, nonce) VALUES (?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE nonce = IF(VALUES(deadline) < deadline, VALUES(nonce), nonce), "+
+		"deadline = LEAST(deadline, VALUES(deadline))",
+		s.UpsertLower("nonce_submission", columns, keys, "deadline", 1))
+
+	s.backend = postgreSQL{}
+	assert.Equal(t, "INSERT INTO nonce_submission (miner_id, block_height, deadline, nonce) VALUES (?, ?, ?, ?) "+
+		"ON CONFLICT (miner_id, block_height) DO UPDATE SET deadline = excluded.deadline, nonce = excluded.nonce "+
+		"WHERE excluded.deadline < nonce_submission.deadline",
+		s.UpsertLower("nonce_submission", columns, keys, "deadline", 1))
+}
+
+func TestConvert(t *testing.T) {
+	assert.Equal(t, int64(42), convertUnsigned(uint64(42)))
+	assert.Equal(t, "18446744073709551615", convertUnsigned(uint64(math.MaxUint64)))
+	assert.Equal(t, int64(7), convertUnsigned(uint32(7)))
+	assert.Equal(t, "abc", convertUnsigned("abc"))
+
+	assert.Equal(t, int64(1), postgreSQL{}.convert(true))
+	assert.Equal(t, int64(0), postgreSQL{}.convert(false))
+
+	local := time.Date(2018, 11, 20, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.UTC, sqLite{}.convert(local).(time.Time).Location())
+	assert.Equal(t, true, mariaDB{}.convert(true))
+}
+
+func TestSQLite(t *testing.T) {
+	// migrations are looked up relative to the working directory
+	wd, _ := os.Getwd()
+	if !assert.Nil(t, os.Chdir("../..")) {
+		return
+	}
+	defer os.Chdir(wd)
+
+	db, err := Open(DBConfig{Driver: DriverSQLite, Name: memory})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer db.Close()
+	if !assert.Nil(t, db.Migrate()) {
+		return
+	}
+
+	var accountID uint64 = math.MaxUint64
+	for i := 0; i < 2; i++ {
+		_, err := db.Exec(db.InsertIgnore("account", "(id, address) VALUES (?, ?)"), accountID, "BURST-1")
+		assert.Nil(t, err)
+	}
+
+	var ids []uint64
+	assert.Nil(t, db.Select(&ids, "SELECT id FROM account WHERE id = ?", accountID))
+	assert.Equal(t, []uint64{accountID}, ids)
+
+	upsert := db.Upsert("miner", []string{"id", "capacity"}, []string{"id"}, 1)
+	for _, capacity := range []int64{5, 7} {
+		_, err = db.Exec(upsert, accountID, capacity)
+		assert.Nil(t, err)
+	}
+	var capacities []int64
+	assert.Nil(t, db.Select(&capacities, "SELECT capacity FROM miner"))
+	assert.Equal(t, []int64{7}, capacities)
+
+	upsert = db.UpsertLower("miner", []string{"id", "capacity"}, []string{"id"}, "capacity", 1)
+	for _, capacity := range []int64{9, 3} {
+		_, err = db.Exec(upsert, accountID, capacity)
+		assert.Nil(t, err)
+	}
+	capacities = nil
+	assert.Nil(t, db.Select(&capacities, "SELECT capacity FROM miner"))
+	assert.Equal(t, []int64{3}, capacities, "raised by a conditional upsert")
+
+	tx, err := db.Begin()
+	if !assert.Nil(t, err) {
+		return
+	}
+	first, err := tx.InsertID("INSERT INTO `transaction` (block_height) VALUES (NULL)")
+	assert.Nil(t, err)
+	second, err := tx.InsertID("INSERT INTO `transaction` (block_height) VALUES (NULL)")
+	assert.Nil(t, err)
+	assert.Equal(t, first+1, second)
+	assert.Nil(t, tx.Commit())
+
+	var share float64
+	assert.Nil(t, db.Get(&share, "SELECT "+db.Float("SUM(id)")+" / 2 FROM `transaction`"))
+	assert.Equal(t, float64(first+second)/2, share)
+
+	var now int64
+	assert.Nil(t, db.Get(&now, "SELECT "+db.UnixMs()))
+	assert.InDelta(t, time.Now().UnixNano()/int64(time.Millisecond), now, 5000, "db time off")
+}
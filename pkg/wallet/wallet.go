@@ -68,14 +68,36 @@ type GetMiningInfoReply struct {
 	GenerationSignature string `json:"generationSignature"`
 	BaseTarget          uint64 `json:"baseTarget,string"`
 	Height              uint64 `json:"height,string"`
+	TargetDeadline      uint64 `json:"targetDeadline,string,omitempty"`
 	errorDescriptionField
 }
 
+// UnmarshalJSON accepts numeric fields as strings (wallets) and as numbers (pools)
+func (r *GetMiningInfoReply) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		GenerationSignature string    `json:"generationSignature"`
+		BaseTarget          Uint64Str `json:"baseTarget"`
+		Height              Uint64Str `json:"height"`
+		TargetDeadline      Uint64Str `json:"targetDeadline"`
+		ErrorDescription    string    `json:"errorDescription"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	r.GenerationSignature = raw.GenerationSignature
+	r.BaseTarget = uint64(raw.BaseTarget)
+	r.Height = uint64(raw.Height)
+	r.TargetDeadline = uint64(raw.TargetDeadline)
+	r.ErrorDescription = raw.ErrorDescription
+	return nil
+}
+
 type SubmitNonceRequest struct {
 	requestTypeField
 	AccountID    uint64 `url:"accountId"`
 	Nonce        uint64 `url:"nonce"`
-	SecretPhrase string `url:"secretPhrase"`
+	SecretPhrase string `url:"secretPhrase,omitempty"`
+	BlockHeight  uint64 `url:"blockheight,omitempty"`
 	res          SubmitNonceReply
 }
 
@@ -120,6 +142,16 @@ type GetBlockReply struct {
 	errorDescriptionField
 }
 
+type GetTimeRequest struct {
+	requestTypeField
+	res GetTimeReply `url:"-"`
+}
+
+type GetTimeReply struct {
+	Time int64 `json:"time"`
+	errorDescriptionField
+}
+
 type GetAccountsWithRewardRecipientRequest struct {
 	requestTypeField
 	AccountID uint64 `url:"account"`
@@ -131,6 +163,17 @@ type GetAccountsWithRewardRecipientReply struct {
 	errorDescriptionField
 }
 
+type GetRewardRecipientRequest struct {
+	requestTypeField
+	Account uint64 `url:"account"`
+	res     GetRewardRecipientReply
+}
+
+type GetRewardRecipientReply struct {
+	RewardRecipient uint64 `json:"rewardRecipient,string"`
+	errorDescriptionField
+}
+
 type SendMoneyRequest struct {
 	requestTypeField
 	Recipient                     uint64 `url:"recipient,string"`
@@ -143,6 +186,24 @@ type SendMoneyRequest struct {
 	res                           SendMoneyReply
 }
 
+type SendMessageRequest struct {
+	requestTypeField
+	Recipient     uint64 `url:"recipient,string"`
+	Message       string `url:"message"`
+	MessageIsText bool   `url:"messageIsText"`
+	FeeNQT        int64  `url:"feeNQT,string"`
+	Deadline      uint   `url:"deadline"`
+	Broadcast     bool   `url:"broadcast"`
+	SecretPhrase  string `url:"secretPhrase"`
+	res           SendMessageReply
+}
+
+type SendMessageReply struct {
+	TxID uint64 `json:"transaction,string"`
+	transactionData
+	errorDescriptionField
+}
+
 type BroadcastTransactionRequest struct {
 	requestTypeField
 	TransactionBytes string `url:"transactionBytes,omitempty"`
@@ -358,11 +419,11 @@ type Wallet interface {
 	// GetMyInfo() (*GetMyInfoReply, error)
 	// GetPeer() (*GetPeerReply, error)
 	// GetPeers() (*GetPeersReply, error)
-	// GetRewardRecipient() (*GetRewardRecipientReply, error)
+	GetRewardRecipient(*GetRewardRecipientRequest) (*GetRewardRecipientReply, error)
 	// GetState() (*GetStateReply, error)
 	// GetSubscription() (*GetSubscriptionReply, error)
 	// GetSubscriptionsToAccount() (*GetSubscriptionsToAccountReply, error)
-	// GetTime() (*GetTimeReply, error)
+	GetTime() (*GetTimeReply, error)
 	// GetTrades() (*GetTradesReply, error)
 	GetTransaction(*GetTransactionRequest) (*GetTransactionReply, error)
 	// GetTransactionBytes() (*GetTransactionBytesReply, error)
@@ -378,7 +439,7 @@ type Wallet interface {
 	// ReadMessage() (*ReadMessageReply, error)
 	// RsConvert() (*RsConvertReply, error)
 	// SellAlias() (*SellAliasReply, error)
-	// SendMessage() (*SendMessageReply, error)
+	SendMessage(*SendMessageRequest) (*SendMessageReply, error)
 	SendMoney(*SendMoneyRequest) (*SendMoneyReply, error)
 	SendMoneyMulti(*SendMoneyMultiRequest) (*SendMoneyMultiReply, error)
 	// SendMoneyEscrow() (*SendMoneyEscrowReply, error)
@@ -496,12 +557,28 @@ func (w *wallet) GetBlock(req *GetBlockRequest) (*GetBlockReply, error) {
 	return &req.res, w.processJSONRequest("GET", req, &req.res)
 }
 
+func (w *wallet) GetTime() (*GetTimeReply, error) {
+	req := GetTimeRequest{}
+	req.RequestType = "getTime"
+	return &req.res, w.processJSONRequest("GET", req, &req.res)
+}
+
 func (w *wallet) GetAccountsWithRewardRecipient(req *GetAccountsWithRewardRecipientRequest) (
 	*GetAccountsWithRewardRecipientReply, error) {
 	req.RequestType = "getAccountsWithRewardRecipient"
 	return &req.res, w.processJSONRequest("POST", &req, &req.res)
 }
 
+func (w *wallet) GetRewardRecipient(req *GetRewardRecipientRequest) (*GetRewardRecipientReply, error) {
+	req.RequestType = "getRewardRecipient"
+	return &req.res, w.processJSONRequest("GET", req, &req.res)
+}
+
+func (w *wallet) SendMessage(req *SendMessageRequest) (*SendMessageReply, error) {
+	req.RequestType = "sendMessage"
+	return &req.res, w.processJSONRequest("POST", req, &req.res)
+}
+
 func (w *wallet) SendMoney(req *SendMoneyRequest) (*SendMoneyReply, error) {
 	req.RequestType = "sendMoney"
 	return &req.res, w.processJSONRequest("POST", req, &req.res)
@@ -49,6 +49,13 @@ func TestGetAccountsWithRewardRecipient(t *testing.T) {
 	}
 }
 
+func TestGetRewardRecipient(t *testing.T) {
+	res, err := w.GetRewardRecipient(&GetRewardRecipientRequest{Account: 6854086812727909295})
+	if assert.Nil(t, err) {
+		assert.NotEmpty(t, res.RewardRecipient)
+	}
+}
+
 func TestGetBlock(t *testing.T) {
 	res, err := rw.GetBlock(&GetBlockRequest{Height: 471696})
 	if !assert.Nil(t, err) {
@@ -107,6 +114,19 @@ func TestSendMoney(t *testing.T) {
 	}
 }
 
+func TestSendMessage(t *testing.T) {
+	res, err := w.SendMessage(&SendMessageRequest{
+		Recipient:     6418289488649374107,
+		Message:       "test",
+		MessageIsText: true,
+		FeeNQT:        10000000,
+		Deadline:      1440,
+		SecretPhrase:  "glad suffer red during single glow shut slam hill death lust although"})
+	if assert.Nil(t, err) {
+		assert.NotEmpty(t, res.TxID)
+	}
+}
+
 func TestBroadcastTransaction(t *testing.T) {
 	res1, err := w.SendMoney(&SendMoneyRequest{
 		Recipient:    6418289488649374107,
@@ -0,0 +1,124 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package wallethandler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
+	"github.com/PoC-Consortium/Nogrod/pkg/wallet"
+
+	"go.uber.org/zap"
+)
+
+var errProxyMode = errors.New("payments are disabled in proxy mode")
+
+// upstreamHandler lets the pool run in front of another pool. Mining info comes from
+// the upstream pool and every account's best deadline is forwarded to it. Chain queries
+// are still answered by the wallets, payments are disabled since the upstream pool
+// pays the miners directly.
+type upstreamHandler struct {
+	WalletHandler
+	upstream wallet.Wallet
+	url      string
+
+	mu             sync.Mutex
+	height         uint64
+	targetDeadline uint64
+	bestDeadlines  map[uint64]uint64
+}
+
+func NewUpstreamHandler(walletHandler WalletHandler, upstreamURL string, timeout time.Duration,
+	trustAll bool) WalletHandler {
+	return &upstreamHandler{
+		WalletHandler: walletHandler,
+		upstream:      wallet.NewWallet(upstreamURL, timeout, trustAll),
+		url:           upstreamURL,
+		bestDeadlines: make(map[uint64]uint64)}
+}
+
+func (uh *upstreamHandler) GetMiningInfo() (*wallet.GetMiningInfoReply, error) {
+	miningInfo, err := uh.upstream.GetMiningInfo()
+	if err != nil {
+		return nil, fmt.Errorf("getting upstream mining info: %v", err)
+	}
+
+	uh.mu.Lock()
+	if miningInfo.Height != uh.height {
+		uh.height = miningInfo.Height
+		uh.bestDeadlines = make(map[uint64]uint64)
+	}
+	uh.targetDeadline = miningInfo.TargetDeadline
+	uh.mu.Unlock()
+
+	return miningInfo, nil
+}
+
+// SubmitNonce forwards a deadline to the upstream pool if it is the best one of the
+// account in the upstream's current round, everything else is dropped silently
+func (uh *upstreamHandler) SubmitNonce(nonce, accountID, deadline, height uint64) ([]SubmitNonceResult, error) {
+	uh.mu.Lock()
+	if height != uh.height {
+		// late submissions of the previous round would be checked against the next one
+		uh.mu.Unlock()
+		return nil, nil
+	}
+	if best, exists := uh.bestDeadlines[accountID]; exists && best <= deadline {
+		uh.mu.Unlock()
+		return nil, nil
+	}
+	if uh.targetDeadline != 0 && deadline > uh.targetDeadline {
+		uh.mu.Unlock()
+		return nil, nil
+	}
+	uh.bestDeadlines[accountID] = deadline
+	uh.mu.Unlock()
+
+	start := time.Now()
+	res, err := uh.upstream.SubmitNonce(&wallet.SubmitNonceRequest{
+		AccountID:   accountID,
+		Nonce:       nonce,
+		BlockHeight: height})
+	result := SubmitNonceResult{URL: uh.url, Latency: time.Since(start), Err: err}
+	if err != nil {
+		uh.forgetDeadline(height, accountID, deadline)
+		return []SubmitNonceResult{result}, err
+	}
+
+	result.Deadline = res.Deadline
+	if res.Deadline != deadline {
+		result.Mismatch = true
+		result.Err = fmt.Errorf("pool deadline %d doesn't match upstream deadline %d", deadline, res.Deadline)
+		uh.forgetDeadline(height, accountID, deadline)
+		return []SubmitNonceResult{result}, result.Err
+	}
+
+	Logger.Info("forwarded deadline to upstream pool", zap.Uint64("accountID", accountID),
+		zap.Uint64("deadline", deadline), zap.Uint64("height", height))
+
+	return []SubmitNonceResult{result}, nil
+}
+
+// forgetDeadline allows to retry a failed forward with the next submission of the account
+func (uh *upstreamHandler) forgetDeadline(height, accountID, deadline uint64) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if uh.height == height && uh.bestDeadlines[accountID] == deadline {
+		delete(uh.bestDeadlines, accountID)
+	}
+}
+
+func (uh *upstreamHandler) SendPayment(uint64, int64) (uint64, error) {
+	return 0, errProxyMode
+}
+
+func (uh *upstreamHandler) SendPayments(map[uint64]int64) (uint64, error) {
+	return 0, errProxyMode
+}
+
+func (uh *upstreamHandler) GetIncomingMsgsSince(time.Time) (map[uint64]string, error) {
+	return nil, errProxyMode
+}
@@ -0,0 +1,71 @@
+// (c) 2018 PoC Consortium ALL RIGHTS RESERVED
+
+package wallethandler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamHandler(t *testing.T) {
+	var mu sync.Mutex
+	var submissions []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		switch req.Form.Get("requestType") {
+		case "getMiningInfo":
+			w.Write([]byte(`{"baseTarget":48450,"generationSignature":"gensig","height":1337,` +
+				`"targetDeadline":1000}`))
+		case "submitNonce":
+			mu.Lock()
+			submissions = append(submissions, req.Form.Get("accountId")+":"+req.Form.Get("nonce")+"@"+
+				req.Form.Get("blockheight"))
+			mu.Unlock()
+			assert.Empty(t, req.Form.Get("secretPhrase"), "secret phrase sent upstream")
+			fmt.Fprintf(w, `{"deadline":%s,"result":"success"}`, req.Form.Get("nonce"))
+		}
+	}))
+	defer upstream.Close()
+
+	uh := NewUpstreamHandler(nil, upstream.URL, time.Second, false)
+
+	miningInfo, err := uh.GetMiningInfo()
+	if assert.Nil(t, err) {
+		assert.Equal(t, uint64(1337), miningInfo.Height)
+		assert.Equal(t, uint64(48450), miningInfo.BaseTarget)
+		assert.Equal(t, uint64(1000), miningInfo.TargetDeadline)
+	}
+
+	// the upstream echoes the nonce as deadline
+	submit := func(nonce, accountID, deadline uint64) error {
+		_, err := uh.SubmitNonce(nonce, accountID, deadline, 1337)
+		return err
+	}
+	assert.Nil(t, submit(500, 1, 500))
+	assert.Nil(t, submit(600, 1, 600), "worse deadline must be dropped")
+	assert.Nil(t, submit(400, 1, 400))
+	assert.Nil(t, submit(2000, 2, 2000), "deadline above upstream target must be dropped")
+
+	_, err = uh.SubmitNonce(100, 3, 100, 1336)
+	assert.Nil(t, err, "submission of another round must be dropped")
+
+	results, err := uh.SubmitNonce(300, 2, 301, 1337)
+	assert.NotNil(t, err, "mismatching deadline not detected")
+	if assert.Len(t, results, 1) {
+		assert.True(t, results[0].Mismatch)
+		assert.Equal(t, upstream.URL, results[0].URL)
+	}
+	// a mismatch doesn't block the next submission of the account
+	assert.Nil(t, submit(350, 2, 350))
+
+	assert.Equal(t, []string{"1:500@1337", "1:400@1337", "2:300@1337", "2:350@1337"}, submissions)
+
+	_, err = uh.SendPayment(1, 1)
+	assert.Equal(t, errProxyMode, err)
+}
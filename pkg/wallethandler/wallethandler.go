@@ -20,14 +20,17 @@ import (
 type WalletHandler interface {
 	GetMiningInfo() (*wallet.GetMiningInfoReply, error)
 	GetBlockInfo(uint64) (*wallet.GetBlockReply, error)
-	SubmitNonce(uint64, uint64, uint64) error
+	SubmitNonce(uint64, uint64, uint64, uint64) ([]SubmitNonceResult, error)
 	SendPayment(uint64, int64) (uint64, error)
 	SendPayments(map[uint64]int64) (uint64, error)
+	SendMessage(uint64, string) (uint64, error)
 	GetAccountInfo(uint64) (*wallet.GetAccountReply, error)
 	WonBlock(uint64, uint64, uint64) (bool, *wallet.GetBlockReply, error)
 	GetGenerationTime(height uint64) (int32, error)
+	GetClockOffset() (time.Duration, error)
 	GetIncomingMsgsSince(date time.Time) (map[uint64]string, error)
 	GetRewardRecipients() (map[uint64]bool, error)
+	GetRewardRecipient(uint64) (uint64, error)
 	GetTransaction(uint64) (*wallet.GetTransactionReply, bool, error)
 	CalcOptimalTxFee(uint64) (int64, error)
 }
@@ -37,6 +40,15 @@ type walletHandler struct {
 	secretPhrase string
 }
 
+// SubmitNonceResult is the outcome of submitting a nonce to a single wallet
+type SubmitNonceResult struct {
+	URL      string
+	Latency  time.Duration
+	Deadline uint64
+	Mismatch bool
+	Err      error
+}
+
 type reqRes struct {
 	obj interface{}
 	url string
@@ -122,24 +134,48 @@ func (wh *walletHandler) GetBlockInfo(height uint64) (*wallet.GetBlockReply, err
 	return res.(*wallet.GetBlockReply), nil
 }
 
-func (wh *walletHandler) SubmitNonce(nonce uint64, accountID uint64, deadline uint64) error {
-	_, err := wh.reqRandom(func(w wallet.Wallet) (interface{}, error) {
-		res, err := w.SubmitNonce(&wallet.SubmitNonceRequest{
-			AccountID:    accountID,
-			Nonce:        nonce,
-			SecretPhrase: wh.secretPhrase})
-		if err != nil {
-			return nil, err
-		}
-		walletDeadline := res.Deadline
-		if walletDeadline != deadline {
-			return nil, fmt.Errorf(
-				"pool deadline %d doesn't match wallet deadline %d",
-				deadline, walletDeadline)
+// SubmitNonce submits a nonce of the block at height to all wallets in parallel and
+// verifies the deadline each of them returns, it fails if not a single wallet accepted
+// the nonce
+func (wh *walletHandler) SubmitNonce(nonce, accountID, deadline, height uint64) ([]SubmitNonceResult, error) {
+	results := make([]SubmitNonceResult, 0, len(wh.wallets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for u, w := range wh.wallets {
+		wg.Add(1)
+		go func(u string, w wallet.Wallet) {
+			defer wg.Done()
+			start := time.Now()
+			res, err := w.SubmitNonce(&wallet.SubmitNonceRequest{
+				AccountID:    accountID,
+				Nonce:        nonce,
+				SecretPhrase: wh.secretPhrase,
+				BlockHeight:  height})
+			result := SubmitNonceResult{URL: u, Latency: time.Since(start), Err: err}
+			if err == nil {
+				result.Deadline = res.Deadline
+				if res.Deadline != deadline {
+					result.Mismatch = true
+					result.Err = fmt.Errorf("pool deadline %d doesn't match wallet deadline %d",
+						deadline, res.Deadline)
+				}
+			}
+			if result.Err != nil {
+				Logger.Error("submitting nonce to wallet", zap.String("url", u), zap.Error(result.Err))
+			}
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(u, w)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Err == nil {
+			return results, nil
 		}
-		return res, nil
-	})
-	return err
+	}
+	return results, errors.New("no wallet accepted the nonce")
 }
 
 func (wh *walletHandler) broadcastTransaction(txBs string) (uint64, error) {
@@ -195,6 +231,26 @@ func (wh *walletHandler) SendPayments(idToAmount map[uint64]int64) (uint64, erro
 	return sendMoneyReply.TxID, nil
 }
 
+func (wh *walletHandler) SendMessage(recipient uint64, msg string) (uint64, error) {
+	results, err := wh.reqAll(func(w wallet.Wallet) (interface{}, error) {
+		return w.SendMessage(&wallet.SendMessageRequest{
+			Recipient:     recipient,
+			Message:       msg,
+			MessageIsText: true,
+			Deadline:      1440,
+			FeeNQT:        Cfg.PoolTxFee,
+			SecretPhrase:  wh.secretPhrase,
+			Broadcast:     false})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sendMessageReply := results[0].obj.(*wallet.SendMessageReply)
+	wh.broadcastTransaction(sendMessageReply.TransactionBytes)
+	return sendMessageReply.TxID, nil
+}
+
 func (wh *walletHandler) GetAccountInfo(accountID uint64) (*wallet.GetAccountReply, error) {
 	obj, err := wh.reqRandom(func(w wallet.Wallet) (interface{}, error) {
 		return w.GetAccount(&wallet.GetAccountRequest{
@@ -237,6 +293,23 @@ func (wh *walletHandler) GetGenerationTime(height uint64) (int32, error) {
 	return b2.Timestamp - b1.Timestamp, nil
 }
 
+// GetClockOffset measures how much the local clock is ahead of the wallet's clock
+func (wh *walletHandler) GetClockOffset() (time.Duration, error) {
+	var offset time.Duration
+	_, err := wh.reqRandom(func(w wallet.Wallet) (interface{}, error) {
+		before := time.Now()
+		res, err := w.GetTime()
+		if err != nil {
+			return nil, err
+		}
+		rtt := time.Since(before)
+		walletTime := burstmath.TimeStampToDate(res.Time)
+		offset = before.Add(rtt / 2).Sub(walletTime)
+		return res, nil
+	})
+	return offset, err
+}
+
 func (wh *walletHandler) GetIncomingMsgsSince(date time.Time) (map[uint64]string, error) {
 	obj, err := wh.reqRandom(func(w wallet.Wallet) (interface{}, error) {
 		return w.GetAccountTransactions(&wallet.GetAccountTransactionsRequest{
@@ -275,6 +348,18 @@ func (wh *walletHandler) GetRewardRecipients() (map[uint64]bool, error) {
 	return recips, nil
 }
 
+// GetRewardRecipient yields the reward recipient that is effective for an account at
+// the current height, pending assignments are ignored by the wallet
+func (wh *walletHandler) GetRewardRecipient(accountID uint64) (uint64, error) {
+	res, err := wh.reqRandom(func(w wallet.Wallet) (interface{}, error) {
+		return w.GetRewardRecipient(&wallet.GetRewardRecipientRequest{Account: accountID})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.(*wallet.GetRewardRecipientReply).RewardRecipient, nil
+}
+
 func (wh *walletHandler) GetTransaction(txID uint64) (*wallet.GetTransactionReply, bool, error) {
 	var querySuccessful bool
 	var mu sync.Mutex
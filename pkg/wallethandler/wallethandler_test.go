@@ -3,6 +3,8 @@
 package wallethandler
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -129,3 +131,28 @@ func TestWalletSuite(t *testing.T) {
 	tests := new(walletTestSuite)
 	suite.Run(t, tests)
 }
+
+func TestSubmitNonceAllWallets(t *testing.T) {
+	newWallet := func(deadline string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"deadline":` + deadline + `,"result":"success"}`))
+		}))
+	}
+	good := newWallet("42")
+	defer good.Close()
+	bad := newWallet("43")
+	defer bad.Close()
+
+	wh := NewWalletHandler([]string{good.URL, bad.URL}, secretPhrase, time.Second, false)
+
+	results, err := wh.SubmitNonce(1, 2, 42, 1337)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		for _, result := range results {
+			assert.Equal(t, result.URL == bad.URL, result.Mismatch, result.URL)
+		}
+	}
+
+	_, err = wh.SubmitNonce(1, 2, 44, 1337)
+	assert.NotNil(t, err, "nonce accepted although all deadlines mismatch")
+}
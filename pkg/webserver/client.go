@@ -4,12 +4,10 @@ package webserver
 
 import (
 	"encoding/json"
-	"strconv"
-	"strings"
 	"time"
 
 	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
-	"github.com/PoC-Consortium/Nogrod/pkg/rsencoding"
+	"github.com/PoC-Consortium/Nogrod/pkg/modelx"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -135,16 +133,9 @@ func (client *Client) subscribe() {
 }
 
 func (client *Client) addSubscription(msg []byte) {
-	var accountID uint64
-	var err error
-
-	trimmed := strings.TrimPrefix(strings.ToUpper(strings.Trim(string(msg), " ")), "BURST-")
-	accountID, err = strconv.ParseUint(trimmed, 10, 64)
+	accountID, err := modelx.ParseAccount(string(msg))
 	if err != nil {
-		accountID, err = rsencoding.Decode(trimmed)
-		if err != nil {
-			return
-		}
+		return
 	}
 
 	if accountID == 0 {
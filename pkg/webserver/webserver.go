@@ -3,6 +3,7 @@
 package webserver
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,8 @@ import (
 	"net"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"text/template"
@@ -18,8 +21,11 @@ import (
 	"github.com/PoC-Consortium/Nogrod/pkg/api"
 	"github.com/PoC-Consortium/Nogrod/pkg/burstmath"
 	. "github.com/PoC-Consortium/Nogrod/pkg/config"
+	"github.com/PoC-Consortium/Nogrod/pkg/events"
 	. "github.com/PoC-Consortium/Nogrod/pkg/logger"
 	"github.com/PoC-Consortium/Nogrod/pkg/modelx"
+	"github.com/PoC-Consortium/Nogrod/pkg/proxyproto"
+	"github.com/PoC-Consortium/Nogrod/pkg/rsencoding"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -36,10 +42,16 @@ const (
 
 	bestShares        = 10
 	netDiffBlockRange = 360
+
+	// blocks and best deadlines are announced by events, polling only catches the
+	// dropped ones
+	safetyNetInterval = 30 * time.Second
 )
 
 type WebServer struct {
-	modelx *modelx.Modelx
+	modelx  *modelx.Modelx
+	events  *events.Subscription
+	metrics *events.Metrics
 
 	clients         map[*Client]bool
 	newClients      chan *Client
@@ -78,22 +90,38 @@ type MinerInfo struct {
 	Capacity              float64
 	Deadline              uint64
 	UserAgent             string
+	RoundSubmits          int
+	RejectedSubmits       int
+	SubmitsPerSecond      int
+	MaxSubmitsPerRound    int
 }
 
 type IndexInfo struct {
-	Cfg     *Config
-	NetDiff float64
+	Cfg           *Config
+	NetDiff       float64
+	DeadlineLimit uint64
+}
+
+type CheckInfo struct {
+	Cfg         *Config
+	PoolAddress string
+	Account     string
+	Check       *api.AccountCheck
+	Error       string
 }
 
 func NewWebServer(m *modelx.Modelx) *WebServer {
 	webServer := &WebServer{
 		modelx:          m,
+		metrics:         events.NewMetrics(m.Events()),
 		newClients:      make(chan *Client),
 		finishedClients: make(chan *Client),
 		clients:         make(map[*Client]bool),
 		templates:       &template.Template{},
 		blockUpdates:    make(chan *api.BlockInfo),
 		shareUpdates:    make(chan []*Share)}
+	webServer.events = m.Events().Subscribe(16, events.NewBlock{}, events.BlockSwitched{},
+		events.NewBestDeadline{}, events.BlockWon{})
 
 	currentBlock := modelx.Cache.CurrentBlock()
 	created, _ := currentBlock.Created.MarshalText()
@@ -140,6 +168,11 @@ func (webServer *WebServer) Run() {
 }
 
 func (webServer *WebServer) webSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if ban := modelx.Cache.BannedClient(proxyproto.ClientIP(r, Cfg.TrustedProxyNets), r.UserAgent()); ban != nil {
+		http.Error(w, ban.Error(), http.StatusForbidden)
+		return
+	}
+
 	c, err := webServer.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		Logger.Error("upgrading connection failed", zap.Error(err))
@@ -194,20 +227,78 @@ func GenMinerInfo(accountID uint64) *api.MinerInfo {
 
 func (webServer *WebServer) indexHandler(w http.ResponseWriter, r *http.Request) {
 	indexInfo := &IndexInfo{
-		Cfg:     &Cfg,
-		NetDiff: webServer.netDiff.Load().(float64)}
+		Cfg:           &Cfg,
+		NetDiff:       webServer.netDiff.Load().(float64),
+		DeadlineLimit: modelx.Cache.DeadlineLimit()}
 	template := webServer.templates.Lookup("index.tmpl")
 	template.ExecuteTemplate(w, "index", indexInfo)
 }
 
 func (webServer *WebServer) infoHandler(w http.ResponseWriter, r *http.Request) {
 	indexInfo := &IndexInfo{
-		Cfg:     &Cfg,
-		NetDiff: webServer.netDiff.Load().(float64)}
+		Cfg:           &Cfg,
+		NetDiff:       webServer.netDiff.Load().(float64),
+		DeadlineLimit: modelx.Cache.DeadlineLimit()}
 	template := webServer.templates.Lookup("info.tmpl")
 	template.ExecuteTemplate(w, "info", indexInfo)
 }
 
+func (webServer *WebServer) checkHandler(w http.ResponseWriter, r *http.Request) {
+	checkInfo := &CheckInfo{
+		Cfg:         &Cfg,
+		PoolAddress: rsencoding.Encode(Cfg.PoolPublicID),
+		Account:     r.FormValue("account")}
+	if checkInfo.Account != "" {
+		check, err := webServer.genAccountCheck(checkInfo.Account)
+		if err != nil {
+			checkInfo.Error = err.Error()
+		}
+		checkInfo.Check = check
+	}
+	template := webServer.templates.Lookup("check.tmpl")
+	template.ExecuteTemplate(w, "check", checkInfo)
+}
+
+// genAccountCheck answers the most common questions of miners that can't join the pool
+func (webServer *WebServer) genAccountCheck(account string) (*api.AccountCheck, error) {
+	accountID, err := modelx.ParseAccount(account)
+	if err != nil || accountID == 0 {
+		return nil, errors.New("malformed numeric id or burst address")
+	}
+
+	check, err := webServer.modelx.CheckAccount(accountID)
+	if err != nil {
+		Logger.Error("checking account failed", zap.Uint64("accountID", accountID), zap.Error(err))
+		return nil, errors.New("wallet not reachable, try again later")
+	}
+
+	ac := &api.AccountCheck{
+		ID:                     check.ID,
+		Address:                check.Address,
+		RewardRecipient:        check.RewardRecipient,
+		RewardRecipientAddress: check.RewardRecipientAddress,
+		AssignedToPool:         check.AssignedToPool || check.Effective,
+		Effective:              check.Effective,
+		LastSubmitHeight:       check.LastSubmitHeight,
+		NConf:                  int32(check.NConf),
+		NMin:                   int32(Cfg.NMin),
+		SecondsUntilNMin:       int64(check.UntilNMin / time.Second)}
+	if check.Ban != nil {
+		ac.Banned = true
+		ac.BanReason = check.Ban.Error()
+	}
+	if !check.LastSubmit.IsZero() {
+		lastSubmit, _ := check.LastSubmit.MarshalText()
+		ac.LastSubmit = string(lastSubmit)
+	}
+	if check.LastReject != nil {
+		lastReject, _ := check.LastReject.Time.MarshalText()
+		ac.LastReject = string(lastReject)
+		ac.LastRejectReason = check.LastReject.Reason
+	}
+	return ac, nil
+}
+
 func (webServer *WebServer) updateRecentlyWonBlocks() {
 	webServer.wonBlocksMu.Lock()
 	defer webServer.wonBlocksMu.Unlock()
@@ -242,6 +333,11 @@ func (webServer *WebServer) updateMinerInfos() {
 			Deadline:              miner.CurrentDeadline(),
 			LastActiveBlockHeight: miner.CurrentBlockHeight(),
 			UserAgent:             miner.UserAgent}
+		mi.RoundSubmits, mi.RejectedSubmits = miner.RoundSubmits(currentBlock.Height)
+		if miner.Listener != nil {
+			mi.SubmitsPerSecond = miner.Listener.AllowSubmitsPerSecond
+			mi.MaxSubmitsPerRound = miner.Listener.MaxSubmitsPerRound
+		}
 		miner.Unlock()
 		mis = append(mis, mi)
 
@@ -327,16 +423,118 @@ func (webServer *WebServer) wonBlocksHandler(w http.ResponseWriter, r *http.Requ
 	template.ExecuteTemplate(w, "wonBlocks", webServer.wonBlocks)
 }
 
+// offendersHandler lists the accounts and ips with rejected submissions, it's only
+// reachable from Cfg.DiagnosticsAllowedFrom
+func (webServer *WebServer) offendersHandler(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(proxyproto.ClientIP(r, Cfg.TrustedProxyNets))
+	if !proxyproto.ContainsIP(Cfg.DiagnosticsNets, ip) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	template := webServer.templates.Lookup("offenders.tmpl")
+	template.ExecuteTemplate(w, "offenders", modelx.Cache.Offenders())
+}
+
+// bansHandler lists the active bans, bans are added by POST with the form values type,
+// value, reason and duration (in s, 0 = forever) and lifted by DELETE with id (404 if it's
+// unknown). It's only reachable from Cfg.DiagnosticsAllowedFrom, changes require
+// Cfg.AdminToken as bearer token.
+func (webServer *WebServer) bansHandler(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(proxyproto.ClientIP(r, Cfg.TrustedProxyNets))
+	if !proxyproto.ContainsIP(Cfg.DiagnosticsNets, ip) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if Cfg.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(Cfg.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		r.ParseForm()
+		var duration int64
+		if d := r.Form.Get("duration"); d != "" {
+			if duration, err = strconv.ParseInt(d, 10, 64); err != nil || duration < 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+		}
+		err = webServer.modelx.AddBan(r.Form.Get("type"), r.Form.Get("value"), r.Form.Get("reason"),
+			time.Duration(duration)*time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err = webServer.modelx.RemoveBan(id); err == modelx.ErrBanNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			Logger.Error("lifting ban failed", zap.Uint64("id", id), zap.Error(err))
+			http.Error(w, "lifting ban failed", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bans, err := webServer.modelx.GetBans()
+	if err != nil {
+		Logger.Error("reading bans failed", zap.Error(err))
+		http.Error(w, "reading bans failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bans)
+}
+
+// statusHandler reports whether the pool runs degraded because the db is unavailable,
+// whether this instance is the leader and how many events were published
+func (webServer *WebServer) statusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		DB     modelx.DBStatus   `json:"db"`
+		Leader bool              `json:"leader"`
+		Events map[string]uint64 `json:"events"`
+	}{DB: webServer.modelx.DBStatus(), Leader: webServer.modelx.IsLeader(), Events: webServer.metrics.Counts()})
+}
+
 func (webServer *WebServer) listen() {
 	http.HandleFunc("/ws", webServer.webSocketHandler)
 	http.HandleFunc("/", webServer.indexHandler)
 	http.HandleFunc("/miners", webServer.minersHandler)
 	http.HandleFunc("/info", webServer.infoHandler)
+	http.HandleFunc("/check", webServer.checkHandler)
 	http.HandleFunc("/wonblocks", webServer.wonBlocksHandler)
+	http.HandleFunc("/offenders", webServer.offendersHandler)
+	http.HandleFunc("/bans", webServer.bansHandler)
+	http.HandleFunc("/status", webServer.statusHandler)
 
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static"))))
 
-	err := http.ListenAndServe(fmt.Sprintf("%s:%d", Cfg.WebServerListenAddress, Cfg.WebServerPort), nil)
+	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", Cfg.WebServerListenAddress, Cfg.WebServerPort))
+	if err != nil {
+		Logger.Fatal("ListenAndServer failed", zap.Error(err))
+	}
+	if Cfg.WebServerProxyProtocol {
+		lis = proxyproto.NewListener(lis, Cfg.TrustedProxyNets)
+	}
+
+	err = http.Serve(lis, nil)
 	if err != nil {
 		Logger.Fatal("ListenAndServer failed", zap.Error(err))
 	}
@@ -371,7 +569,7 @@ func (webServer *WebServer) GetPoolStatsInfo(ctx context.Context, req *api.Void)
 func (webServer *WebServer) GetPoolConfigInfo(ctx context.Context, req *api.Void) (*api.PoolConfigInfo, error) {
 	return &api.PoolConfigInfo{
 		PoolFeeShare:    Cfg.PoolFeeShare,
-		DeadlineLimit:   Cfg.DeadlineLimit,
+		DeadlineLimit:   modelx.Cache.DeadlineLimit(),
 		MinimumPayout:   Cfg.MinimumPayout,
 		TxFee:           Cfg.PoolTxFee,
 		WinnerShare:     Cfg.WinnerShare,
@@ -386,6 +584,10 @@ func (webServer *WebServer) GetPoolConfigInfo(ctx context.Context, req *api.Void
 		PoolPublicID:    Cfg.PoolPublicID}, nil
 }
 
+func (webServer *WebServer) CheckAccount(ctx context.Context, req *api.AccountCheckRequest) (*api.AccountCheck, error) {
+	return webServer.genAccountCheck(req.Account)
+}
+
 func (webServer *WebServer) GetBlockInfo(ctx context.Context, req *api.Void) (*api.BlockInfo, error) {
 	blockInfo := webServer.getBlockInfo()
 	return &blockInfo, nil
@@ -411,7 +613,9 @@ func (webServer *WebServer) checkForBlockUpdate() {
 	blockInfo := webServer.getBlockInfo()
 	newBlock := modelx.Cache.CurrentBlock()
 
-	if newBlock.Height > blockInfo.Height {
+	// a block of another fork replaces the one at the same height
+	if newBlock.Height > blockInfo.Height ||
+		newBlock.Height == blockInfo.Height && newBlock.GenerationSignature != blockInfo.GenerationSignature {
 		created, _ := newBlock.Created.MarshalText()
 		blockInfo := api.BlockInfo{
 			Height:              newBlock.Height,
@@ -463,11 +667,21 @@ func (webServer *WebServer) cacheUpdateJobs() {
 	minerInfoUpdateTicker := time.NewTicker(20 * time.Second)
 	wonBlocksUpdateTicker := time.NewTicker(10 * time.Minute)
 	netDiffUpdateTicker := time.NewTicker(30 * time.Minute)
-	bestNonceSubmissionTicker := time.NewTicker(5 * time.Second)
-	newBlockTicker := time.NewTicker(5 * time.Second)
+	bestNonceSubmissionTicker := time.NewTicker(safetyNetInterval)
+	newBlockTicker := time.NewTicker(safetyNetInterval)
 
 	for {
 		select {
+		case event := <-webServer.events.C:
+			switch event.(type) {
+			case events.NewBlock, events.BlockSwitched:
+				webServer.checkForBlockUpdate()
+				webServer.updateMinerInfos()
+			case events.NewBestDeadline:
+				webServer.checkForNewBestSubmission()
+			case events.BlockWon:
+				webServer.updateRecentlyWonBlocks()
+			}
 		case <-minerInfoUpdateTicker.C:
 			webServer.updateMinerInfos()
 		case <-newBlockTicker.C: